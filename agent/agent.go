@@ -15,6 +15,10 @@ import (
 	"github.com/influxdata/telegraf/plugins/serializers/influx"
 )
 
+// processorReleaseInterval is how often metrics held back by processors are
+// checked to be ready.
+const processorReleaseInterval = 100 * time.Millisecond
+
 // Agent runs a set of plugins.
 type Agent struct {
 	Config *config.Config
@@ -134,6 +138,9 @@ func (a *Agent) Run(ctx context.Context) error {
 
 	wg.Wait()
 
+	log.Printf("D! [agent] Closing processors")
+	a.closeProcessors()
+
 	log.Printf("D! [agent] Closing outputs")
 	a.closeOutputs()
 
@@ -187,6 +194,7 @@ func (a *Agent) Test(ctx context.Context, waitDuration time.Duration) error {
 	if err != nil {
 		return err
 	}
+	defer a.closeProcessors()
 
 	if hasServiceInputs {
 		log.Printf("D! [agent] Starting service inputs")
@@ -345,15 +353,60 @@ func (a *Agent) runProcessors(
 	src <-chan telegraf.Metric,
 	agg chan<- telegraf.Metric,
 ) error {
-	for metric := range src {
-		metrics := a.applyProcessors(metric)
+	// Metrics held back by processors are passed on once ready.
+	var release <-chan time.Time
+	for _, processor := range a.Config.Processors {
+		if processor.Holding() {
+			ticker := time.NewTicker(processorReleaseInterval)
+			defer ticker.Stop()
+			release = ticker.C
+			break
+		}
+	}
 
+	for {
+		select {
+		case metric, ok := <-src:
+			if !ok {
+				a.releaseProcessors(agg, true)
+				return nil
+			}
+
+			metrics := a.holdProcessors(0, metric)
+			for _, metric := range metrics {
+				agg <- metric
+			}
+		case <-release:
+			a.releaseProcessors(agg, false)
+		}
+	}
+}
+
+// holdProcessors applies the processors, starting with the processor at
+// index start, to the metrics of the inputs.
+func (a *Agent) holdProcessors(start int, metrics ...telegraf.Metric) []telegraf.Metric {
+	for _, processor := range a.Config.Processors[start:] {
+		metrics = processor.Hold(metrics...)
+	}
+
+	return metrics
+}
+
+// releaseProcessors passes the metrics released by each processor through
+// the following processors, all held metrics are released when flush is
+// true.
+func (a *Agent) releaseProcessors(agg chan<- telegraf.Metric, flush bool) {
+	for i, processor := range a.Config.Processors {
+		metrics := processor.Release(flush)
+		if len(metrics) == 0 {
+			continue
+		}
+
+		metrics = a.holdProcessors(i+1, metrics...)
 		for _, metric := range metrics {
 			agg <- metric
 		}
 	}
-
-	return nil
 }
 
 // applyProcessors applies all processors to a metric.
@@ -665,6 +718,13 @@ func (a *Agent) connectOutputs(ctx context.Context) error {
 	return nil
 }
 
+// closeProcessors closes all processors.
+func (a *Agent) closeProcessors() {
+	for _, processor := range a.Config.Processors {
+		processor.Close()
+	}
+}
+
 // closeOutputs closes all outputs.
 func (a *Agent) closeOutputs() {
 	for _, output := range a.Config.Outputs {
@@ -25,6 +25,7 @@ import (
 	"github.com/influxdata/telegraf/plugins/inputs"
 	"github.com/influxdata/telegraf/plugins/outputs"
 	"github.com/influxdata/telegraf/plugins/parsers"
+	"github.com/influxdata/telegraf/plugins/parsers/json"
 	"github.com/influxdata/telegraf/plugins/processors"
 	"github.com/influxdata/telegraf/plugins/serializers"
 	"github.com/influxdata/toml"
@@ -1469,6 +1470,18 @@ func getParserConfig(name string, tbl *ast.Table) (*parsers.Config, error) {
 		}
 	}
 
+	if node, ok := tbl.Fields["json_object"]; ok {
+		if subtbls, ok := node.([]*ast.Table); ok {
+			for _, subtbl := range subtbls {
+				var object json.Object
+				if err := toml.UnmarshalTable(subtbl, &object); err != nil {
+					return nil, fmt.Errorf("Error parsing json_object, %s", err)
+				}
+				c.JSONObjects = append(c.JSONObjects, object)
+			}
+		}
+	}
+
 	if node, ok := tbl.Fields["data_type"]; ok {
 		if kv, ok := node.(*ast.KeyValue); ok {
 			if str, ok := kv.Value.(*ast.String); ok {
@@ -1753,6 +1766,136 @@ func getParserConfig(name string, tbl *ast.Table) (*parsers.Config, error) {
 		}
 	}
 
+	//for avro parser
+	if node, ok := tbl.Fields["avro_schema_file"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.AvroSchemaFile = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["avro_schema_registry"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.AvroSchemaRegistry = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["avro_measurement_field"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.AvroMeasurementField = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["avro_tags"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if ary, ok := kv.Value.(*ast.Array); ok {
+				for _, elem := range ary.Value {
+					if str, ok := elem.(*ast.String); ok {
+						c.AvroTags = append(c.AvroTags, str.Value)
+					}
+				}
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["avro_fields"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if ary, ok := kv.Value.(*ast.Array); ok {
+				for _, elem := range ary.Value {
+					if str, ok := elem.(*ast.String); ok {
+						c.AvroFields = append(c.AvroFields, str.Value)
+					}
+				}
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["avro_timestamp"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.AvroTimestamp = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["avro_timestamp_format"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.AvroTimestampFormat = str.Value
+			}
+		}
+	}
+
+	//for protobuf parser
+	if node, ok := tbl.Fields["protobuf_descriptor_file"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.ProtobufDescriptorFile = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["protobuf_message_type"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.ProtobufMessageType = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["protobuf_measurement_field"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.ProtobufMeasurementField = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["protobuf_tags"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if ary, ok := kv.Value.(*ast.Array); ok {
+				for _, elem := range ary.Value {
+					if str, ok := elem.(*ast.String); ok {
+						c.ProtobufTags = append(c.ProtobufTags, str.Value)
+					}
+				}
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["protobuf_fields"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if ary, ok := kv.Value.(*ast.Array); ok {
+				for _, elem := range ary.Value {
+					if str, ok := elem.(*ast.String); ok {
+						c.ProtobufFields = append(c.ProtobufFields, str.Value)
+					}
+				}
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["protobuf_timestamp"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.ProtobufTimestamp = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["protobuf_timestamp_format"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.ProtobufTimestampFormat = str.Value
+			}
+		}
+	}
+
 	c.MetricName = name
 
 	delete(tbl.Fields, "data_format")
@@ -1765,6 +1908,7 @@ func getParserConfig(name string, tbl *ast.Table) (*parsers.Config, error) {
 	delete(tbl.Fields, "json_time_format")
 	delete(tbl.Fields, "json_time_key")
 	delete(tbl.Fields, "json_timezone")
+	delete(tbl.Fields, "json_object")
 	delete(tbl.Fields, "data_type")
 	delete(tbl.Fields, "collectd_auth_file")
 	delete(tbl.Fields, "collectd_security_level")
@@ -1795,6 +1939,20 @@ func getParserConfig(name string, tbl *ast.Table) (*parsers.Config, error) {
 	delete(tbl.Fields, "csv_timestamp_format")
 	delete(tbl.Fields, "csv_trim_space")
 	delete(tbl.Fields, "form_urlencoded_tag_keys")
+	delete(tbl.Fields, "avro_schema_file")
+	delete(tbl.Fields, "avro_schema_registry")
+	delete(tbl.Fields, "avro_measurement_field")
+	delete(tbl.Fields, "avro_tags")
+	delete(tbl.Fields, "avro_fields")
+	delete(tbl.Fields, "avro_timestamp")
+	delete(tbl.Fields, "avro_timestamp_format")
+	delete(tbl.Fields, "protobuf_descriptor_file")
+	delete(tbl.Fields, "protobuf_message_type")
+	delete(tbl.Fields, "protobuf_measurement_field")
+	delete(tbl.Fields, "protobuf_tags")
+	delete(tbl.Fields, "protobuf_fields")
+	delete(tbl.Fields, "protobuf_timestamp")
+	delete(tbl.Fields, "protobuf_timestamp_format")
 
 	return c, nil
 }
@@ -1933,6 +2091,70 @@ func buildSerializer(name string, tbl *ast.Table) (serializers.Serializer, error
 		}
 	}
 
+	if node, ok := tbl.Fields["csv_header"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if b, ok := kv.Value.(*ast.Boolean); ok {
+				var err error
+				c.CSVHeader, err = b.Boolean()
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["csv_delimiter"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.CSVDelimiter = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["csv_timestamp_format"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.CSVTimestampFormat = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["csv_column_order"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.CSVColumnOrder = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["csv_columns"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if ary, ok := kv.Value.(*ast.Array); ok {
+				for _, elem := range ary.Value {
+					if str, ok := elem.(*ast.String); ok {
+						c.CSVColumns = append(c.CSVColumns, str.Value)
+					}
+				}
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["csv_tag_prefix"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.CSVTagPrefix = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["csv_field_prefix"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.CSVFieldPrefix = str.Value
+			}
+		}
+	}
+
 	delete(tbl.Fields, "influx_max_line_bytes")
 	delete(tbl.Fields, "influx_sort_fields")
 	delete(tbl.Fields, "influx_uint_support")
@@ -1944,6 +2166,13 @@ func buildSerializer(name string, tbl *ast.Table) (serializers.Serializer, error
 	delete(tbl.Fields, "splunkmetric_hec_routing")
 	delete(tbl.Fields, "wavefront_source_override")
 	delete(tbl.Fields, "wavefront_use_strict")
+	delete(tbl.Fields, "csv_header")
+	delete(tbl.Fields, "csv_delimiter")
+	delete(tbl.Fields, "csv_timestamp_format")
+	delete(tbl.Fields, "csv_column_order")
+	delete(tbl.Fields, "csv_columns")
+	delete(tbl.Fields, "csv_tag_prefix")
+	delete(tbl.Fields, "csv_field_prefix")
 	return serializers.NewSerializer(c)
 }
 
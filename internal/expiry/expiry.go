@@ -0,0 +1,43 @@
+package expiry
+
+import "time"
+
+// Tracker records the last update of series, identified by the metric
+// HashID, and reports the series that have not been updated for longer than
+// a timeout.
+type Tracker struct {
+	Timeout time.Duration
+
+	updated map[uint64]time.Time
+}
+
+// NewTracker returns a Tracker expiring series after timeout.
+func NewTracker(timeout time.Duration) *Tracker {
+	return &Tracker{
+		Timeout: timeout,
+		updated: make(map[uint64]time.Time),
+	}
+}
+
+// Update records that the series id was updated at t.
+func (t *Tracker) Update(id uint64, ts time.Time) {
+	t.updated[id] = ts
+}
+
+// Remove stops tracking the series id.
+func (t *Tracker) Remove(id uint64) {
+	delete(t.updated, id)
+}
+
+// Expire returns the series last updated more than the timeout before now
+// and stops tracking them.
+func (t *Tracker) Expire(now time.Time) []uint64 {
+	var expired []uint64
+	for id, ts := range t.updated {
+		if now.Sub(ts) > t.Timeout {
+			expired = append(expired, id)
+			delete(t.updated, id)
+		}
+	}
+	return expired
+}
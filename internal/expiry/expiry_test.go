@@ -0,0 +1,24 @@
+package expiry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpire(t *testing.T) {
+	now := time.Unix(1000, 0)
+	tracker := NewTracker(time.Minute)
+
+	tracker.Update(1, now.Add(-2*time.Minute))
+	tracker.Update(2, now.Add(-30*time.Second))
+	tracker.Update(3, now.Add(-2*time.Minute))
+	tracker.Update(3, now)
+	tracker.Update(4, now.Add(-time.Hour))
+	tracker.Remove(4)
+
+	require.Equal(t, []uint64{1}, tracker.Expire(now))
+	require.Empty(t, tracker.Expire(now))
+	require.ElementsMatch(t, []uint64{2, 3}, tracker.Expire(now.Add(time.Hour)))
+}
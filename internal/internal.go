@@ -52,6 +52,20 @@ type Number struct {
 	Value float64
 }
 
+// PartialWriteError is returned by an output when only some of the metrics of
+// a write have failed.  The metrics at the Retry indexes are returned to the
+// buffer to be written again, the metrics at the Drop indexes are discarded,
+// and all other metrics are accepted as written.
+type PartialWriteError struct {
+	Err   error
+	Retry []int
+	Drop  []int
+}
+
+func (e *PartialWriteError) Error() string {
+	return e.Err.Error()
+}
+
 // SetVersion sets the telegraf agent version
 func SetVersion(v string) error {
 	if version != "" {
@@ -200,6 +214,24 @@ func SnakeCase(in string) string {
 	return string(out)
 }
 
+// FormatValue returns the raw value of a field as text, floats are formatted
+// without an exponent.
+func FormatValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return fmt.Sprint(value)
+}
+
 // CombinedOutputTimeout runs the given command with the given timeout and
 // returns the combined output of stdout and stderr.
 // If the command times out, it attempts to kill the process.
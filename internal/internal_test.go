@@ -335,3 +335,11 @@ func TestParseTimestampWithLocation(t *testing.T) {
 	time, err = ParseTimestampWithLocation("2019-02-20 21:50:34.029665", "2006-01-02 15:04:05.000000", "InvalidTimeZone")
 	assert.NotNil(t, err)
 }
+
+func TestFormatValue(t *testing.T) {
+	assert.Equal(t, "0.000001", FormatValue(1e-6))
+	assert.Equal(t, "-42", FormatValue(int64(-42)))
+	assert.Equal(t, "18446744073709551615", FormatValue(uint64(18446744073709551615)))
+	assert.Equal(t, "true", FormatValue(true))
+	assert.Equal(t, "text", FormatValue("text"))
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"log"
 	"sync"
 
 	"github.com/influxdata/telegraf"
@@ -15,6 +16,7 @@ var (
 // Buffer stores metrics in a circular buffer.
 type Buffer struct {
 	sync.Mutex
+	name  string // name of the output
 	buf   []telegraf.Metric
 	first int // index of the first/oldest metric
 	last  int // one after the index of the last/newest metric
@@ -34,6 +36,7 @@ type Buffer struct {
 // NewBuffer returns a new empty Buffer with the given capacity.
 func NewBuffer(name string, capacity int) *Buffer {
 	b := &Buffer{
+		name:  name,
 		buf:   make([]telegraf.Metric, capacity),
 		first: 0,
 		last:  0,
@@ -189,7 +192,53 @@ func (b *Buffer) Reject(batch []telegraf.Metric) {
 	b.Lock()
 	defer b.Unlock()
 
+	b.reject(batch)
+}
+
+// Partial marks the batch, acquired from Batch(), as partially written.  The
+// metrics at the retry indexes are returned to the buffer, the metrics at the
+// drop indexes are discarded and the others are marked as written.  The
+// whole batch is returned to the buffer if an index is out of range.
+func (b *Buffer) Partial(batch []telegraf.Metric, retry, drop []int) {
+	b.Lock()
+	defer b.Unlock()
+
+	status := make([]byte, len(batch))
+	for _, indexes := range [][]int{retry, drop} {
+		for _, i := range indexes {
+			if i < 0 || i >= len(batch) {
+				log.Printf("E! [outputs.%s] Index %d of partial write out of range, retrying the batch", b.name, i)
+				b.reject(batch)
+				return
+			}
+		}
+	}
+	for _, i := range retry {
+		status[i] = 'r'
+	}
+	for _, i := range drop {
+		status[i] = 'd'
+	}
+
+	rejected := make([]telegraf.Metric, 0, len(retry))
+	for i, m := range batch {
+		switch status[i] {
+		case 'r':
+			rejected = append(rejected, m)
+		case 'd':
+			b.metricDropped(m)
+		default:
+			b.metricWritten(m)
+		}
+	}
+
+	b.reject(rejected)
+}
+
+func (b *Buffer) reject(batch []telegraf.Metric) {
 	if len(batch) == 0 {
+		b.resetBatch()
+		b.BufferSize.Set(int64(b.length()))
 		return
 	}
 
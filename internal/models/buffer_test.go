@@ -726,3 +726,64 @@ func TestBuffer_RejectEmptyBatch(t *testing.T) {
 		require.NotNil(t, m)
 	}
 }
+
+func TestBuffer_Partial(t *testing.T) {
+	b := setup(NewBuffer("test", 5))
+	b.Add(MetricTime(1))
+	b.Add(MetricTime(2))
+	b.Add(MetricTime(3))
+	b.Add(MetricTime(4))
+	batch := b.Batch(3)
+	b.Add(MetricTime(5))
+	b.Partial(batch, []int{0}, []int{1})
+
+	require.Equal(t, int64(1), b.MetricsWritten.Get())
+	require.Equal(t, int64(1), b.MetricsDropped.Get())
+	require.Equal(t, 3, b.Len())
+
+	batch = b.Batch(5)
+	testutil.RequireMetricsEqual(t,
+		[]telegraf.Metric{
+			MetricTime(5),
+			MetricTime(4),
+			MetricTime(1),
+		}, batch)
+}
+
+func TestBuffer_PartialNothingToRetry(t *testing.T) {
+	b := setup(NewBuffer("test", 5))
+	b.Add(MetricTime(1))
+	b.Add(MetricTime(2))
+	batch := b.Batch(2)
+	b.Partial(batch, nil, []int{1})
+
+	require.Equal(t, int64(1), b.MetricsWritten.Get())
+	require.Equal(t, int64(1), b.MetricsDropped.Get())
+	require.Equal(t, 0, b.Len())
+
+	b.Add(MetricTime(3))
+	batch = b.Batch(5)
+	testutil.RequireMetricsEqual(t,
+		[]telegraf.Metric{
+			MetricTime(3),
+		}, batch)
+}
+
+func TestBuffer_PartialOutOfRange(t *testing.T) {
+	b := setup(NewBuffer("test", 5))
+	b.Add(MetricTime(1))
+	b.Add(MetricTime(2))
+	batch := b.Batch(2)
+	b.Partial(batch, []int{2}, []int{-1})
+
+	require.Equal(t, int64(0), b.MetricsWritten.Get())
+	require.Equal(t, int64(0), b.MetricsDropped.Get())
+	require.Equal(t, 2, b.Len())
+
+	batch = b.Batch(5)
+	testutil.RequireMetricsEqual(t,
+		[]telegraf.Metric{
+			MetricTime(2),
+			MetricTime(1),
+		}, batch)
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
 	"github.com/influxdata/telegraf/selfstat"
 )
 
@@ -167,7 +168,7 @@ func (ro *RunningOutput) Write() error {
 
 		err := ro.write(batch)
 		if err != nil {
-			ro.buffer.Reject(batch)
+			ro.reject(batch, err)
 			return err
 		}
 		ro.buffer.Accept(batch)
@@ -184,7 +185,7 @@ func (ro *RunningOutput) WriteBatch() error {
 
 	err := ro.write(batch)
 	if err != nil {
-		ro.buffer.Reject(batch)
+		ro.reject(batch, err)
 		return err
 	}
 	ro.buffer.Accept(batch)
@@ -192,6 +193,16 @@ func (ro *RunningOutput) WriteBatch() error {
 	return nil
 }
 
+// reject returns the failed metrics of the batch to the buffer; when the
+// output reports a partial write only the metrics to retry are kept.
+func (ro *RunningOutput) reject(batch []telegraf.Metric, err error) {
+	if perr, ok := err.(*internal.PartialWriteError); ok {
+		ro.buffer.Partial(batch, perr.Retry, perr.Drop)
+		return
+	}
+	ro.buffer.Reject(batch)
+}
+
 func (ro *RunningOutput) Close() {
 	err := ro.Output.Close()
 	if err != nil {
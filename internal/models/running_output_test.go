@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
 	"github.com/influxdata/telegraf/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -412,6 +413,36 @@ func TestRunningOutputWriteFailOrder3(t *testing.T) {
 	assert.Equal(t, expected, m.Metrics())
 }
 
+func TestRunningOutputPartialWrite(t *testing.T) {
+	conf := &OutputConfig{
+		Filter: Filter{},
+	}
+
+	m := &mockOutput{}
+	ro := NewRunningOutput("test", m, conf, 5, 10)
+
+	for _, metric := range first5 {
+		ro.AddMetric(metric)
+	}
+
+	// the newest metric is retried, the next one dropped
+	m.partialWrite = &internal.PartialWriteError{
+		Err:   fmt.Errorf("partial write"),
+		Retry: []int{0},
+		Drop:  []int{1},
+	}
+	err := ro.Write()
+	require.Error(t, err)
+	assert.Len(t, m.Metrics(), 3)
+
+	m.partialWrite = nil
+	err = ro.Write()
+	require.NoError(t, err)
+
+	expected := []telegraf.Metric{first5[2], first5[1], first5[0], first5[4]}
+	assert.Equal(t, expected, m.Metrics())
+}
+
 type mockOutput struct {
 	sync.Mutex
 
@@ -419,6 +450,9 @@ type mockOutput struct {
 
 	// if true, mock a write failure
 	failWrite bool
+
+	// if set, mock a partial write failure
+	partialWrite *internal.PartialWriteError
 }
 
 func (m *mockOutput) Connect() error {
@@ -448,6 +482,19 @@ func (m *mockOutput) Write(metrics []telegraf.Metric) error {
 		m.metrics = []telegraf.Metric{}
 	}
 
+	if m.partialWrite != nil {
+		failed := make(map[int]bool)
+		for _, i := range append(m.partialWrite.Retry, m.partialWrite.Drop...) {
+			failed[i] = true
+		}
+		for i, metric := range metrics {
+			if !failed[i] {
+				m.metrics = append(m.metrics, metric)
+			}
+		}
+		return m.partialWrite
+	}
+
 	for _, metric := range metrics {
 		m.metrics = append(m.metrics, metric)
 	}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"io"
+	"log"
 	"sync"
 
 	"github.com/influxdata/telegraf"
@@ -50,10 +52,58 @@ func (rp *RunningProcessor) Init() error {
 	return nil
 }
 
+// Close stops a processor with background work, processors implementing
+// io.Closer are closed after the last metric is applied.
+func (rp *RunningProcessor) Close() {
+	if p, ok := rp.Processor.(io.Closer); ok {
+		err := p.Close()
+		if err != nil {
+			log.Printf("E! [processors.%s] Error closing processor: %v", rp.Name, err)
+		}
+	}
+}
+
 func (rp *RunningProcessor) Apply(in ...telegraf.Metric) []telegraf.Metric {
 	rp.Lock()
 	defer rp.Unlock()
 
+	return rp.apply(rp.Processor.Apply, in)
+}
+
+// Hold applies the processor like Apply, a telegraf.HoldingProcessor may
+// hold back the selected metrics until they are returned by Release.
+func (rp *RunningProcessor) Hold(in ...telegraf.Metric) []telegraf.Metric {
+	rp.Lock()
+	defer rp.Unlock()
+
+	if p, ok := rp.Processor.(telegraf.HoldingProcessor); ok {
+		return rp.apply(p.Hold, in)
+	}
+	return rp.apply(rp.Processor.Apply, in)
+}
+
+// Release returns the metrics held back by the processor that are ready, or
+// all of them when flush is true.
+func (rp *RunningProcessor) Release(flush bool) []telegraf.Metric {
+	rp.Lock()
+	defer rp.Unlock()
+
+	if p, ok := rp.Processor.(telegraf.HoldingProcessor); ok {
+		return p.Release(flush)
+	}
+	return nil
+}
+
+// Holding returns true if the processor may hold back metrics.
+func (rp *RunningProcessor) Holding() bool {
+	_, ok := rp.Processor.(telegraf.HoldingProcessor)
+	return ok
+}
+
+func (rp *RunningProcessor) apply(
+	fn func(in ...telegraf.Metric) []telegraf.Metric,
+	in []telegraf.Metric,
+) []telegraf.Metric {
 	ret := []telegraf.Metric{}
 
 	for _, metric := range in {
@@ -72,7 +122,7 @@ func (rp *RunningProcessor) Apply(in ...telegraf.Metric) []telegraf.Metric {
 
 		// This metric should pass through the filter, so call the filter Apply
 		// function and append results to the output slice.
-		ret = append(ret, rp.Processor.Apply(metric)...)
+		ret = append(ret, fn(metric)...)
 	}
 
 	return ret
@@ -2,6 +2,7 @@ package rotate
 
 // Rotating things
 import (
+	"compress/gzip"
 	"fmt"
 	"io"
 	"os"
@@ -34,11 +35,33 @@ type FileWriter struct {
 	maxArchives              int
 	expireTime               time.Time
 	bytesWritten             int64
+	compression              string
 	sync.Mutex
+
+	// archives are compressed and purged in the background, one at a time
+	archiving sync.Mutex
+	archives  sync.WaitGroup
 }
 
 // NewFileWriter creates a new file writer.
 func NewFileWriter(filename string, interval time.Duration, maxSizeInBytes int64, maxArchives int) (io.WriteCloser, error) {
+	return NewCompressingFileWriter(filename, interval, maxSizeInBytes, maxArchives, "")
+}
+
+// NewCompressingFileWriter creates a new file writer that compresses the
+// rotated archives with "gzip" or "zstd", adding the ".gz" or ".zst"
+// extension.  An empty compression or "none" leaves the archives as is.
+func NewCompressingFileWriter(filename string, interval time.Duration, maxSizeInBytes int64, maxArchives int, compression string) (io.WriteCloser, error) {
+	switch compression {
+	case "", "none", "gzip":
+	case "zstd":
+		if !zstdSupported {
+			return nil, fmt.Errorf("zstd compression requires a build with cgo")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported compression %q", compression)
+	}
+
 	if interval == 0 && maxSizeInBytes <= 0 {
 		// No rotation needed so a basic io.Writer will do the trick
 		return openFile(filename)
@@ -50,6 +73,7 @@ func NewFileWriter(filename string, interval time.Duration, maxSizeInBytes int64
 		maxSizeInBytes:           maxSizeInBytes,
 		maxArchives:              maxArchives,
 		filenameRotationTemplate: getFilenameRotationTemplate(filename),
+		compression:              compression,
 	}
 
 	if err := w.openCurrent(); err != nil {
@@ -88,11 +112,12 @@ func (w *FileWriter) Write(p []byte) (n int, err error) {
 	return n, nil
 }
 
-// Close closes the current file.  Writer is unusable after this
-// is called.
+// Close closes the current file and waits for the archives to be
+// compressed.  Writer is unusable after this is called.
 func (w *FileWriter) Close() (err error) {
 	w.Lock()
 	defer w.Unlock()
+	defer w.archives.Wait()
 
 	// Rotate before closing
 	if err = w.rotate(); err != nil {
@@ -103,6 +128,26 @@ func (w *FileWriter) Close() (err error) {
 	return nil
 }
 
+// Sync commits the current contents of the file to stable storage.
+func (w *FileWriter) Sync() error {
+	w.Lock()
+	defer w.Unlock()
+
+	return w.current.Sync()
+}
+
+// Release closes the current file without rotating it.  Writer is unusable
+// after this is called; a new writer for the same file continues the
+// rotation from the modification time of the file.
+func (w *FileWriter) Release() error {
+	w.Lock()
+	defer w.Unlock()
+
+	err := w.current.Close()
+	w.current = nil
+	return err
+}
+
 func (w *FileWriter) openCurrent() (err error) {
 	// In case ModTime() fails, we use time.Now()
 	w.expireTime = time.Now().Add(w.interval)
@@ -148,10 +193,25 @@ func (w *FileWriter) rotate() (err error) {
 		return err
 	}
 
-	if err = w.purgeArchivesIfNeeded(); err != nil {
-		return err
+	if compressionExt(w.compression) == "" {
+		return w.purgeArchivesIfNeeded()
 	}
 
+	// Compressing may take a while, so it is done outside of the writes.
+	w.archives.Add(1)
+	go func() {
+		defer w.archives.Done()
+		w.archiving.Lock()
+		defer w.archiving.Unlock()
+
+		if err := compressFile(rotatedFilename, w.compression); err != nil {
+			fmt.Printf("unable to compress the file '%s', %s", rotatedFilename, err.Error())
+			return
+		}
+		if err := w.purgeArchivesIfNeeded(); err != nil {
+			fmt.Printf("unable to purge the archives of '%s', %s", w.filename, err.Error())
+		}
+	}()
 	return nil
 }
 
@@ -162,7 +222,7 @@ func (w *FileWriter) purgeArchivesIfNeeded() (err error) {
 	}
 
 	var matches []string
-	if matches, err = filepath.Glob(fmt.Sprintf(w.filenameRotationTemplate, "*", "*")); err != nil {
+	if matches, err = filepath.Glob(fmt.Sprintf(w.filenameRotationTemplate, "*", "*") + compressionExt(w.compression)); err != nil {
 		return err
 	}
 
@@ -178,3 +238,55 @@ func (w *FileWriter) purgeArchivesIfNeeded() (err error) {
 	}
 	return nil
 }
+
+func compressionExt(compression string) string {
+	switch compression {
+	case "gzip":
+		return ".gz"
+	case "zstd":
+		return ".zst"
+	default:
+		return ""
+	}
+}
+
+// compressFile replaces the file with its compressed version.
+func compressFile(filename, compression string) (err error) {
+	if compressionExt(compression) == "" {
+		return nil
+	}
+
+	in, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(filename+compressionExt(compression), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, FilePerm)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if errClose := out.Close(); err == nil {
+			err = errClose
+		}
+		if err != nil {
+			os.Remove(out.Name())
+		}
+	}()
+
+	var w io.WriteCloser
+	if compression == "gzip" {
+		w = gzip.NewWriter(out)
+	} else {
+		w = newZstdWriter(out)
+	}
+
+	if _, err = io.Copy(w, in); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	return os.Remove(filename)
+}
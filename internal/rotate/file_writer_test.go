@@ -1,6 +1,8 @@
 package rotate
 
 import (
+	"compress/gzip"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -113,3 +115,77 @@ func TestFileWriter_CloseRotates(t *testing.T) {
 	assert.Equal(t, 1, len(files))
 	assert.Regexp(t, "^test\\.[^\\.]+\\.log$", files[0].Name())
 }
+
+func TestFileWriter_CompressArchives(t *testing.T) {
+	testCompressArchives(t, "gzip", "gz", func(r io.Reader) (io.Reader, error) {
+		return gzip.NewReader(r)
+	})
+}
+
+func testCompressArchives(t *testing.T, compression, ext string, newReader func(io.Reader) (io.Reader, error)) {
+	tempDir, err := ioutil.TempDir("", "RotationCompress")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+	maxSize := int64(100)
+	writer, err := NewCompressingFileWriter(filepath.Join(tempDir, "test.log"), 0, maxSize, 1, compression)
+	require.NoError(t, err)
+
+	_, err = writer.Write([]byte("Hello World"))
+	require.NoError(t, err)
+	writer.Close()
+
+	files, _ := ioutil.ReadDir(tempDir)
+	require.Equal(t, 1, len(files))
+	assert.Regexp(t, "^test\\.[^\\.]+\\.log\\."+ext+"$", files[0].Name())
+
+	f, err := os.Open(filepath.Join(tempDir, files[0].Name()))
+	require.NoError(t, err)
+	defer f.Close()
+	r, err := newReader(f)
+	require.NoError(t, err)
+	content, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello World", string(content))
+}
+
+func TestFileWriter_CompressPurgesArchives(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "RotationCompressPurge")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+	writer, err := NewCompressingFileWriter(filepath.Join(tempDir, "test.log"), 0, 5, 2, "gzip")
+	require.NoError(t, err)
+
+	for i := 0; i < 4; i++ {
+		_, err = writer.Write([]byte("Hello World"))
+		require.NoError(t, err)
+		// The archives are named with second precision.
+		time.Sleep(time.Second)
+	}
+	require.NoError(t, writer.Close())
+
+	archives, err := filepath.Glob(filepath.Join(tempDir, "test.*.log.gz"))
+	require.NoError(t, err)
+	assert.Equal(t, 2, len(archives))
+}
+
+func TestFileWriter_InvalidCompression(t *testing.T) {
+	_, err := NewCompressingFileWriter("test.log", 0, 9, 1, "lz4")
+	require.Error(t, err)
+}
+
+func TestFileWriter_Release(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "RotationRelease")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+	writer, err := NewFileWriter(filepath.Join(tempDir, "test.log"), time.Hour, 0, -1)
+	require.NoError(t, err)
+
+	_, err = writer.Write([]byte("Hello World"))
+	require.NoError(t, err)
+	require.NoError(t, writer.(*FileWriter).Sync())
+	require.NoError(t, writer.(*FileWriter).Release())
+
+	files, _ := ioutil.ReadDir(tempDir)
+	assert.Equal(t, 1, len(files))
+	assert.Equal(t, "test.log", files[0].Name())
+}
@@ -0,0 +1,15 @@
+// +build cgo
+
+package rotate
+
+import (
+	"io"
+
+	"github.com/DataDog/zstd"
+)
+
+const zstdSupported = true
+
+func newZstdWriter(w io.Writer) io.WriteCloser {
+	return zstd.NewWriter(w)
+}
@@ -0,0 +1,13 @@
+// +build !cgo
+
+package rotate
+
+import "io"
+
+// The zstd library requires cgo, NewCompressingFileWriter rejects zstd
+// compression without it.
+const zstdSupported = false
+
+func newZstdWriter(w io.Writer) io.WriteCloser {
+	panic("zstd compression requires a build with cgo")
+}
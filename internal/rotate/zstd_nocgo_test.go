@@ -0,0 +1,14 @@
+// +build !cgo
+
+package rotate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileWriter_ZstdRequiresCgo(t *testing.T) {
+	_, err := NewCompressingFileWriter("test.log", 0, 9, 1, "zstd")
+	require.Error(t, err)
+}
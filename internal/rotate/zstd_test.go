@@ -0,0 +1,16 @@
+// +build cgo
+
+package rotate
+
+import (
+	"io"
+	"testing"
+
+	"github.com/DataDog/zstd"
+)
+
+func TestFileWriter_CompressArchivesZstd(t *testing.T) {
+	testCompressArchives(t, "zstd", "zst", func(r io.Reader) (io.Reader, error) {
+		return zstd.NewReader(r), nil
+	})
+}
@@ -42,7 +42,14 @@ func (g *SeriesGrouper) Add(
 	fieldValue interface{},
 ) error {
 	var err error
-	id := groupID(measurement, tags, tm)
+	taglist := make([]*telegraf.Tag, 0, len(tags))
+	for k, v := range tags {
+		taglist = append(taglist,
+			&telegraf.Tag{Key: k, Value: v})
+	}
+	sort.Slice(taglist, func(i, j int) bool { return taglist[i].Key < taglist[j].Key })
+
+	id := groupID(measurement, taglist, tm)
 	metric := g.metrics[id]
 	if metric == nil {
 		metric, err = New(measurement, tags, map[string]interface{}{field: fieldValue}, tm)
@@ -57,22 +64,36 @@ func (g *SeriesGrouper) Add(
 	return nil
 }
 
+// AddMetric adds a metric to the series, its fields are merged into any
+// previously added metric with the same series and time.
+func (g *SeriesGrouper) AddMetric(
+	metric telegraf.Metric,
+) {
+	id := groupID(metric.Name(), metric.TagList(), metric.Time())
+	m := g.metrics[id]
+	if m == nil {
+		m = metric.Copy()
+		g.metrics[id] = m
+		g.ordered = append(g.ordered, m)
+	} else {
+		for _, f := range metric.FieldList() {
+			m.AddField(f.Key, f.Value)
+		}
+	}
+}
+
 // Metrics returns the metrics grouped by series and time.
 func (g *SeriesGrouper) Metrics() []telegraf.Metric {
 	return g.ordered
 }
 
-func groupID(measurement string, tags map[string]string, tm time.Time) uint64 {
+// groupID returns the id of the series and time, taglist must be sorted by
+// key.
+func groupID(measurement string, taglist []*telegraf.Tag, tm time.Time) uint64 {
 	h := fnv.New64a()
 	h.Write([]byte(measurement))
 	h.Write([]byte("\n"))
 
-	taglist := make([]*telegraf.Tag, 0, len(tags))
-	for k, v := range tags {
-		taglist = append(taglist,
-			&telegraf.Tag{Key: k, Value: v})
-	}
-	sort.Slice(taglist, func(i, j int) bool { return taglist[i].Key < taglist[j].Key })
 	for _, tag := range taglist {
 		h.Write([]byte(tag.Key))
 		h.Write([]byte("\n"))
@@ -0,0 +1,342 @@
+package anomaly
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/filter"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/expiry"
+	"github.com/influxdata/telegraf/plugins/aggregators"
+)
+
+var sampleConfig = `
+  ## The period on which to flush & clear the aggregator.
+  period = "30s"
+  ## If true, the original metric will be dropped by the
+  ## aggregator and will not get sent to the output plugins.
+  drop_original = false
+
+  ## Fields to check for anomalies, accepts glob patterns.
+  # fields = ["*"]
+
+  ## Model of the expected values, either "ewma" for the exponentially
+  ## weighted moving average and standard deviation, or "zscore" for the
+  ## mean and standard deviation of the last values.
+  # algorithm = "ewma"
+
+  ## Weight of a new value in the "ewma" model, between 0 and 1.
+  # alpha = 0.1
+
+  ## Number of values in the "zscore" model.
+  # window = 30
+
+  ## Number of values of a field used to build the model before values are
+  ## scored.
+  # warmup = 10
+
+  ## Score, in standard deviations from the expected value, at and above
+  ## which a value is an anomaly.
+  # threshold = 3.0
+
+  ## Tag added to the emitted metrics, set to "true" if any field is an
+  ## anomaly and "false" otherwise.  No tag is added if empty.
+  # anomaly_tag = ""
+
+  ## The time a series is kept after its last value, its model is discarded
+  ## afterwards.
+  # series_timeout = "1h"
+`
+
+const (
+	algorithmEWMA   = "ewma"
+	algorithmZScore = "zscore"
+
+	// maxScore is reported for a value different from a model without
+	// variance.
+	maxScore = 1e6
+)
+
+type Anomaly struct {
+	Fields        []string          `toml:"fields"`
+	Algorithm     string            `toml:"algorithm"`
+	Alpha         float64           `toml:"alpha"`
+	Window        int               `toml:"window"`
+	Warmup        int               `toml:"warmup"`
+	Threshold     float64           `toml:"threshold"`
+	AnomalyTag    string            `toml:"anomaly_tag"`
+	SeriesTimeout internal.Duration `toml:"series_timeout"`
+
+	fieldFilter filter.Filter
+	cache       map[uint64]*aggregate
+	series      *expiry.Tracker
+	now         func() time.Time
+}
+
+type aggregate struct {
+	name   string
+	tags   map[string]string
+	fields map[string]*field
+}
+
+// field holds the model of a field and the largest score in the current
+// period.
+type field struct {
+	model model
+	count int
+
+	scored   bool
+	score    float64
+	lastTime time.Time
+}
+
+// model of the expected values of a field.
+type model interface {
+	// score returns the distance of v from the expected value in standard
+	// deviations.
+	score(v float64) float64
+	// add updates the model with v.
+	add(v float64)
+}
+
+func NewAnomaly() *Anomaly {
+	return &Anomaly{
+		Fields:        []string{"*"},
+		Algorithm:     algorithmEWMA,
+		Alpha:         0.1,
+		Window:        30,
+		Warmup:        10,
+		Threshold:     3.0,
+		SeriesTimeout: internal.Duration{Duration: time.Hour},
+		cache:         make(map[uint64]*aggregate),
+		series:        expiry.NewTracker(time.Hour),
+		now:           time.Now,
+	}
+}
+
+func (a *Anomaly) SampleConfig() string {
+	return sampleConfig
+}
+
+func (a *Anomaly) Description() string {
+	return "Score values by their deviation from a moving model to detect anomalies"
+}
+
+func (a *Anomaly) Init() error {
+	a.series.Timeout = a.SeriesTimeout.Duration
+
+	switch a.Algorithm {
+	case algorithmEWMA:
+		if a.Alpha <= 0 || a.Alpha > 1 {
+			return fmt.Errorf("alpha must be greater than 0 and at most 1")
+		}
+	case algorithmZScore:
+		if a.Window < 2 {
+			return fmt.Errorf("window must be at least 2")
+		}
+	default:
+		return fmt.Errorf("invalid algorithm: %s", a.Algorithm)
+	}
+
+	if a.Warmup < 1 {
+		return fmt.Errorf("warmup must be at least 1")
+	}
+
+	var err error
+	a.fieldFilter, err = filter.Compile(a.Fields)
+	return err
+}
+
+func (a *Anomaly) newModel() model {
+	if a.Algorithm == algorithmZScore {
+		return &window{values: make([]float64, 0, a.Window)}
+	}
+	return &ewma{alpha: a.Alpha}
+}
+
+func (a *Anomaly) Add(in telegraf.Metric) {
+	id := in.HashID()
+	agg, ok := a.cache[id]
+	if !ok {
+		agg = &aggregate{
+			name:   in.Name(),
+			tags:   in.Tags(),
+			fields: make(map[string]*field),
+		}
+		a.cache[id] = agg
+	}
+	a.series.Update(id, in.Time())
+
+	for _, f := range in.FieldList() {
+		if a.fieldFilter != nil && !a.fieldFilter.Match(f.Key) {
+			continue
+		}
+		v, ok := toFloat(f.Value)
+		if !ok {
+			continue
+		}
+
+		fld, ok := agg.fields[f.Key]
+		if !ok {
+			fld = &field{model: a.newModel()}
+			agg.fields[f.Key] = fld
+		}
+
+		if fld.count >= a.Warmup {
+			score := fld.model.score(v)
+			if !fld.scored || math.Abs(score) > math.Abs(fld.score) {
+				fld.score = score
+			}
+			fld.scored = true
+			if in.Time().After(fld.lastTime) {
+				fld.lastTime = in.Time()
+			}
+		}
+
+		fld.model.add(v)
+		fld.count++
+	}
+}
+
+func (a *Anomaly) Push(acc telegraf.Accumulator) {
+	// Preserve timestamp of original metric
+	acc.SetPrecision(time.Nanosecond)
+
+	for _, id := range a.series.Expire(a.now()) {
+		delete(a.cache, id)
+	}
+
+	for _, agg := range a.cache {
+		fields := map[string]interface{}{}
+		var anomaly bool
+		var ts time.Time
+		for key, fld := range agg.fields {
+			if !fld.scored {
+				continue
+			}
+
+			isAnomaly := math.Abs(fld.score) >= a.Threshold
+			anomaly = anomaly || isAnomaly
+			fields[key+"_anomaly_score"] = fld.score
+			fields[key+"_is_anomaly"] = isAnomaly
+			if fld.lastTime.After(ts) {
+				ts = fld.lastTime
+			}
+		}
+		if len(fields) == 0 {
+			continue
+		}
+
+		tags := agg.tags
+		if a.AnomalyTag != "" {
+			tags = make(map[string]string, len(agg.tags)+1)
+			for k, v := range agg.tags {
+				tags[k] = v
+			}
+			tags[a.AnomalyTag] = fmt.Sprint(anomaly)
+		}
+		acc.AddFields(agg.name, fields, tags, ts)
+	}
+}
+
+func (a *Anomaly) Reset() {
+	for _, agg := range a.cache {
+		for _, fld := range agg.fields {
+			fld.scored = false
+			fld.score = 0
+		}
+	}
+}
+
+// score returns the number of standard deviations v is from mean.
+func score(v, mean, variance float64) float64 {
+	diff := v - mean
+	if variance <= 0 {
+		if diff == 0 {
+			return 0
+		}
+		return math.Copysign(maxScore, diff)
+	}
+
+	s := diff / math.Sqrt(variance)
+	return math.Max(-maxScore, math.Min(maxScore, s))
+}
+
+// ewma is an exponentially weighted moving average and variance.
+type ewma struct {
+	alpha    float64
+	mean     float64
+	variance float64
+	started  bool
+}
+
+func (e *ewma) score(v float64) float64 {
+	return score(v, e.mean, e.variance)
+}
+
+func (e *ewma) add(v float64) {
+	if !e.started {
+		e.mean = v
+		e.started = true
+		return
+	}
+	diff := v - e.mean
+	e.mean += e.alpha * diff
+	e.variance = (1 - e.alpha) * (e.variance + e.alpha*diff*diff)
+}
+
+// window is the mean and variance of the last values.
+type window struct {
+	values []float64
+	next   int
+}
+
+func (w *window) score(v float64) float64 {
+	if len(w.values) == 0 {
+		return 0
+	}
+
+	var sum float64
+	for _, x := range w.values {
+		sum += x
+	}
+	mean := sum / float64(len(w.values))
+
+	var variance float64
+	for _, x := range w.values {
+		variance += (x - mean) * (x - mean)
+	}
+	if len(w.values) > 1 {
+		variance /= float64(len(w.values) - 1)
+	}
+	return score(v, mean, variance)
+}
+
+func (w *window) add(v float64) {
+	if len(w.values) < cap(w.values) {
+		w.values = append(w.values, v)
+		return
+	}
+	w.values[w.next] = v
+	w.next = (w.next + 1) % len(w.values)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+func init() {
+	aggregators.Add("anomaly", func() telegraf.Aggregator {
+		return NewAnomaly()
+	})
+}
@@ -0,0 +1,162 @@
+package anomaly
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func addValues(a *Anomaly, start time.Time, values ...float64) {
+	for i, v := range values {
+		a.Add(testutil.MustMetric("cpu",
+			map[string]string{"host": "a"},
+			map[string]interface{}{"usage": v, "name": "x"},
+			start.Add(time.Duration(i)*time.Second)))
+	}
+}
+
+func TestEWMAModel(t *testing.T) {
+	e := &ewma{alpha: 0.5}
+	e.add(10)
+	require.Equal(t, 10.0, e.mean)
+	require.Equal(t, 0.0, e.variance)
+	require.Equal(t, maxScore, e.score(11))
+	require.Equal(t, 0.0, e.score(10))
+
+	e.add(12)
+	require.InDelta(t, 11.0, e.mean, 1e-9)
+	require.InDelta(t, 1.0, e.variance, 1e-9)
+	require.InDelta(t, -2.0, e.score(9), 1e-9)
+}
+
+func TestWindowModel(t *testing.T) {
+	w := &window{values: make([]float64, 0, 3)}
+	require.Equal(t, 0.0, w.score(5))
+
+	for _, v := range []float64{100, 1, 2, 3} {
+		w.add(v)
+	}
+	// 100 was replaced, mean 2 and standard deviation 1
+	require.InDelta(t, 3.0, w.score(5), 1e-9)
+	require.InDelta(t, -1.0, w.score(1), 1e-9)
+}
+
+func TestWarmup(t *testing.T) {
+	acc := testutil.Accumulator{}
+	a := NewAnomaly()
+	a.Warmup = 3
+	require.NoError(t, a.Init())
+
+	start := time.Now()
+	addValues(a, start, 1, 2, 1000)
+	a.Push(&acc)
+	a.Reset()
+	require.Empty(t, acc.GetTelegrafMetrics())
+
+	addValues(a, start.Add(10*time.Second), 2)
+	a.Push(&acc)
+	require.Len(t, acc.GetTelegrafMetrics(), 1)
+}
+
+func TestAnomaly(t *testing.T) {
+	for _, algorithm := range []string{algorithmEWMA, algorithmZScore} {
+		t.Run(algorithm, func(t *testing.T) {
+			acc := testutil.Accumulator{}
+			a := NewAnomaly()
+			a.Algorithm = algorithm
+			a.AnomalyTag = "anomaly"
+			require.NoError(t, a.Init())
+
+			start := time.Now()
+			var values []float64
+			for i := 0; i < 20; i++ {
+				values = append(values, 10+float64(i%3))
+			}
+			addValues(a, start, values...)
+			a.Push(&acc)
+			a.Reset()
+
+			metrics := acc.GetTelegrafMetrics()
+			require.Len(t, metrics, 1)
+			require.Equal(t, "false", metrics[0].Tags()["anomaly"])
+			isAnomaly, _ := metrics[0].GetField("usage_is_anomaly")
+			require.Equal(t, false, isAnomaly)
+			require.False(t, metrics[0].HasField("name_anomaly_score"))
+
+			acc.ClearMetrics()
+			addValues(a, start.Add(time.Minute), 11, 50, 10)
+			a.Push(&acc)
+
+			metrics = acc.GetTelegrafMetrics()
+			require.Len(t, metrics, 1)
+			require.Equal(t, "true", metrics[0].Tags()["anomaly"])
+			isAnomaly, _ = metrics[0].GetField("usage_is_anomaly")
+			require.Equal(t, true, isAnomaly)
+			score, _ := metrics[0].GetField("usage_anomaly_score")
+			require.True(t, score.(float64) > 3)
+			require.Equal(t, start.Add(time.Minute+2*time.Second), metrics[0].Time())
+		})
+	}
+}
+
+func TestNegativeScore(t *testing.T) {
+	acc := testutil.Accumulator{}
+	a := NewAnomaly()
+	a.Warmup = 5
+	require.NoError(t, a.Init())
+
+	start := time.Now()
+	addValues(a, start, 10, 11, 10, 11, 10, -40)
+	a.Push(&acc)
+
+	metrics := acc.GetTelegrafMetrics()
+	require.Len(t, metrics, 1)
+	require.Equal(t, start.Add(5*time.Second), metrics[0].Time())
+	isAnomaly, _ := metrics[0].GetField("usage_is_anomaly")
+	require.Equal(t, true, isAnomaly)
+	score, _ := metrics[0].GetField("usage_anomaly_score")
+	require.True(t, score.(float64) < -3)
+}
+
+func TestSeriesTimeout(t *testing.T) {
+	acc := testutil.Accumulator{}
+	a := NewAnomaly()
+	a.Warmup = 1
+	a.SeriesTimeout = internal.Duration{Duration: time.Minute}
+	require.NoError(t, a.Init())
+
+	start := time.Unix(1000, 0)
+	a.now = func() time.Time { return start.Add(10 * time.Second) }
+	addValues(a, start, 1, 1)
+	a.Push(&acc)
+	a.Reset()
+	require.Len(t, acc.GetTelegrafMetrics(), 1)
+	require.Len(t, a.cache, 1)
+
+	acc.ClearMetrics()
+	a.now = func() time.Time { return start.Add(2 * time.Minute) }
+	a.Push(&acc)
+	require.Empty(t, acc.GetTelegrafMetrics())
+	require.Empty(t, a.cache)
+}
+
+func TestInvalidConfig(t *testing.T) {
+	a := NewAnomaly()
+	a.Algorithm = "magic"
+	require.Error(t, a.Init())
+
+	a = NewAnomaly()
+	a.Alpha = 0
+	require.Error(t, a.Init())
+
+	a = NewAnomaly()
+	a.Algorithm = algorithmZScore
+	a.Window = 1
+	require.Error(t, a.Init())
+
+	require.False(t, math.IsNaN(score(1, 1, 0)))
+}
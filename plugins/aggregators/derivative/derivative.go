@@ -0,0 +1,265 @@
+package derivative
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/filter"
+	"github.com/influxdata/telegraf/plugins/aggregators"
+)
+
+var sampleConfig = `
+  ## The period on which to flush & clear the aggregator.
+  period = "30s"
+  ## If true, the original metric will be dropped by the
+  ## aggregator and will not get sent to the output plugins.
+  drop_original = false
+
+  ## Fields to compute the derivative of, accepts glob patterns.
+  # fields = ["*"]
+
+  ## Either "rate" for the change per second or "delta" for the change of
+  ## the field during the period.
+  # mode = "rate"
+
+  ## Suffix appended to the field names, defaults to "_rate" or "_delta"
+  ## depending on the mode.
+  # suffix = "_rate"
+
+  ## If true the fields are monotonic counters; a decreasing value is a
+  ## counter reset or, for unsigned integers, a wraparound.
+  # counter = true
+
+  ## Size in bits of unsigned counters, either 32 or 64.
+  # wraparound_bits = 64
+
+  ## Number of periods a series without new values is kept, its last value
+  ## is used to compute the derivative once it is updated again.
+  # max_roll_over = 10
+`
+
+const (
+	modeRate  = "rate"
+	modeDelta = "delta"
+)
+
+type Derivative struct {
+	Fields         []string `toml:"fields"`
+	Mode           string   `toml:"mode"`
+	Suffix         string   `toml:"suffix"`
+	Counter        bool     `toml:"counter"`
+	WraparoundBits int      `toml:"wraparound_bits"`
+	MaxRollOver    int      `toml:"max_roll_over"`
+
+	fieldFilter filter.Filter
+	cache       map[uint64]*aggregate
+}
+
+type aggregate struct {
+	name     string
+	tags     map[string]string
+	fields   map[string]*derivative
+	rollOver int
+	// true if values were added during the current period
+	seen bool
+}
+
+// derivative tracks the change of a field since the end of the previous
+// period.
+type derivative struct {
+	// last value and its time
+	last     interface{}
+	lastTime time.Time
+	// time of the last value of the previous period
+	start time.Time
+	// accumulated change since start
+	delta   float64
+	updated bool
+}
+
+func NewDerivative() *Derivative {
+	return &Derivative{
+		Fields:         []string{"*"},
+		Mode:           modeRate,
+		Counter:        true,
+		WraparoundBits: 64,
+		MaxRollOver:    10,
+		cache:          make(map[uint64]*aggregate),
+	}
+}
+
+func (d *Derivative) SampleConfig() string {
+	return sampleConfig
+}
+
+func (d *Derivative) Description() string {
+	return "Compute the rate or delta of fields between consecutive values"
+}
+
+func (d *Derivative) Init() error {
+	if d.Suffix == "" {
+		d.Suffix = "_" + d.Mode
+	}
+
+	switch d.Mode {
+	case modeRate, modeDelta:
+	default:
+		return fmt.Errorf("invalid mode: %s", d.Mode)
+	}
+
+	if d.WraparoundBits != 32 && d.WraparoundBits != 64 {
+		return fmt.Errorf("wraparound_bits must be 32 or 64")
+	}
+
+	var err error
+	d.fieldFilter, err = filter.Compile(d.Fields)
+	return err
+}
+
+func (d *Derivative) Add(in telegraf.Metric) {
+	id := in.HashID()
+	agg, ok := d.cache[id]
+	if !ok {
+		agg = &aggregate{
+			name:   in.Name(),
+			tags:   in.Tags(),
+			fields: make(map[string]*derivative),
+		}
+		d.cache[id] = agg
+	}
+	agg.seen = true
+
+	for _, field := range in.FieldList() {
+		if d.fieldFilter != nil && !d.fieldFilter.Match(field.Key) {
+			continue
+		}
+		if _, ok := toFloat(field.Value); !ok {
+			continue
+		}
+
+		deriv, ok := agg.fields[field.Key]
+		if !ok {
+			agg.fields[field.Key] = &derivative{
+				last:     field.Value,
+				lastTime: in.Time(),
+				start:    in.Time(),
+			}
+			continue
+		}
+
+		// values older than the last one are out of order and ignored
+		if !in.Time().After(deriv.lastTime) {
+			continue
+		}
+
+		deriv.delta += d.step(deriv.last, field.Value)
+		deriv.last = field.Value
+		deriv.lastTime = in.Time()
+		deriv.updated = true
+	}
+}
+
+// step returns the change between two consecutive values.
+func (d *Derivative) step(prev, cur interface{}) float64 {
+	p, pok := prev.(uint64)
+	c, cok := cur.(uint64)
+	if pok && cok {
+		if c >= p {
+			return float64(c - p)
+		}
+		if !d.Counter {
+			return -float64(p - c)
+		}
+
+		max := uint64(math.MaxUint64)
+		if d.WraparoundBits == 32 {
+			max = math.MaxUint32
+		}
+		// a counter near its maximum that restarts near zero wrapped around,
+		// any other decrease is a reset
+		if p <= max && p >= max-max/4 && c <= max/4 {
+			return float64(max-p) + float64(c) + 1
+		}
+		return float64(c)
+	}
+
+	pf, _ := toFloat(prev)
+	cf, _ := toFloat(cur)
+	delta := cf - pf
+	if delta < 0 && d.Counter {
+		// the counter was reset and started over from zero
+		return cf
+	}
+	return delta
+}
+
+func (d *Derivative) Push(acc telegraf.Accumulator) {
+	// Preserve timestamp of original metric
+	acc.SetPrecision(time.Nanosecond)
+
+	for id, agg := range d.cache {
+		fields := make(map[string]interface{})
+		var tm time.Time
+		for key, deriv := range agg.fields {
+			if !deriv.updated {
+				continue
+			}
+
+			if deriv.lastTime.After(tm) {
+				tm = deriv.lastTime
+			}
+
+			switch d.Mode {
+			case modeDelta:
+				fields[key+d.Suffix] = deriv.delta
+			default:
+				elapsed := deriv.lastTime.Sub(deriv.start).Seconds()
+				fields[key+d.Suffix] = deriv.delta / elapsed
+			}
+
+			deriv.start = deriv.lastTime
+			deriv.delta = 0
+			deriv.updated = false
+		}
+
+		if agg.seen {
+			agg.rollOver = 0
+		} else {
+			agg.rollOver++
+			if agg.rollOver > d.MaxRollOver {
+				delete(d.cache, id)
+			}
+		}
+		agg.seen = false
+
+		if len(fields) > 0 {
+			acc.AddFields(agg.name, fields, agg.tags, tm)
+		}
+	}
+}
+
+// Reset keeps the last values of each series to compute the derivative
+// across periods, series are expired by max_roll_over.
+func (d *Derivative) Reset() {
+}
+
+func toFloat(in interface{}) (float64, bool) {
+	switch v := in.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
+
+func init() {
+	aggregators.Add("derivative", func() telegraf.Aggregator {
+		return NewDerivative()
+	})
+}
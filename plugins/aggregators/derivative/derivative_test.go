@@ -0,0 +1,158 @@
+package derivative
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Unix(1560540000, 0)
+
+func counter(fields map[string]interface{}, offset time.Duration) telegraf.Metric {
+	return testutil.MustMetric(
+		"net",
+		map[string]string{"interface": "eth0"},
+		fields,
+		start.Add(offset),
+	)
+}
+
+func push(d *Derivative) []telegraf.Metric {
+	acc := testutil.Accumulator{}
+	d.Push(&acc)
+	d.Reset()
+	return acc.GetTelegrafMetrics()
+}
+
+func TestRate(t *testing.T) {
+	d := NewDerivative()
+	require.NoError(t, d.Init())
+	d.Add(counter(map[string]interface{}{"bytes_recv": int64(100), "name": "x"}, 0))
+	d.Add(counter(map[string]interface{}{"bytes_recv": int64(200)}, 10*time.Second))
+	d.Add(counter(map[string]interface{}{"bytes_recv": int64(400)}, 20*time.Second))
+
+	expected := []telegraf.Metric{
+		counter(map[string]interface{}{"bytes_recv_rate": 15.0}, 20*time.Second),
+	}
+	testutil.RequireMetricsEqual(t, expected, push(d))
+}
+
+func TestAcrossPeriods(t *testing.T) {
+	d := NewDerivative()
+	d.Mode = "delta"
+	require.NoError(t, d.Init())
+
+	// a single value has no derivative
+	d.Add(counter(map[string]interface{}{"packets": uint64(10)}, 0))
+	require.Len(t, push(d), 0)
+
+	// the first value of a period is compared to the last of the previous
+	d.Add(counter(map[string]interface{}{"packets": uint64(15)}, 10*time.Second))
+	expected := []telegraf.Metric{
+		counter(map[string]interface{}{"packets_delta": 5.0}, 10*time.Second),
+	}
+	testutil.RequireMetricsEqual(t, expected, push(d))
+
+	d.Add(counter(map[string]interface{}{"packets": uint64(18)}, 20*time.Second))
+	d.Add(counter(map[string]interface{}{"packets": uint64(20)}, 30*time.Second))
+	expected = []telegraf.Metric{
+		counter(map[string]interface{}{"packets_delta": 5.0}, 30*time.Second),
+	}
+	testutil.RequireMetricsEqual(t, expected, push(d))
+}
+
+func TestCounterReset(t *testing.T) {
+	d := NewDerivative()
+	d.Mode = "delta"
+	require.NoError(t, d.Init())
+
+	d.Add(counter(map[string]interface{}{"i": int64(100), "u": uint64(100)}, 0))
+	d.Add(counter(map[string]interface{}{"i": int64(150), "u": uint64(150)}, 10*time.Second))
+	d.Add(counter(map[string]interface{}{"i": int64(20), "u": uint64(20)}, 20*time.Second))
+
+	expected := []telegraf.Metric{
+		counter(map[string]interface{}{"i_delta": 70.0, "u_delta": 70.0}, 20*time.Second),
+	}
+	testutil.RequireMetricsEqual(t, expected, push(d))
+}
+
+func TestWraparound(t *testing.T) {
+	tests := []struct {
+		name     string
+		bits     int
+		prev     uint64
+		cur      uint64
+		expected float64
+	}{
+		{"64 bit", 64, math.MaxUint64 - 9, 10, 20},
+		{"32 bit", 32, math.MaxUint32 - 9, 10, 20},
+		{"32 bit reset", 32, 1000, 10, 10},
+		{"larger than 32 bit", 32, math.MaxUint64 - 9, 10, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDerivative()
+			d.WraparoundBits = tt.bits
+			require.Equal(t, tt.expected, d.step(tt.prev, tt.cur))
+		})
+	}
+}
+
+func TestNotCounter(t *testing.T) {
+	d := NewDerivative()
+	d.Counter = false
+	d.Mode = "delta"
+	d.Suffix = "_diff"
+	d.Fields = []string{"temp*"}
+	require.NoError(t, d.Init())
+
+	d.Add(counter(map[string]interface{}{"temperature": 20.0, "other": 1.0}, 0))
+	d.Add(counter(map[string]interface{}{"temperature": 18.5, "other": 2.0}, 10*time.Second))
+
+	expected := []telegraf.Metric{
+		counter(map[string]interface{}{"temperature_diff": -1.5}, 10*time.Second),
+	}
+	testutil.RequireMetricsEqual(t, expected, push(d))
+}
+
+func TestMaxRollOver(t *testing.T) {
+	d := NewDerivative()
+	d.MaxRollOver = 1
+	require.NoError(t, d.Init())
+
+	d.Add(counter(map[string]interface{}{"bytes": int64(100)}, 0))
+	push(d)
+	require.Len(t, d.cache, 1)
+
+	// the series is kept for one period without values
+	push(d)
+	require.Len(t, d.cache, 1)
+	d.Add(counter(map[string]interface{}{"bytes": int64(200)}, 20*time.Second))
+	require.Len(t, push(d), 1)
+
+	push(d)
+	push(d)
+	require.Len(t, d.cache, 0)
+}
+
+func TestOutOfOrder(t *testing.T) {
+	d := NewDerivative()
+	require.NoError(t, d.Init())
+	d.Add(counter(map[string]interface{}{"bytes": int64(100)}, 10*time.Second))
+	d.Add(counter(map[string]interface{}{"bytes": int64(50)}, 0))
+	require.Len(t, push(d), 0)
+}
+
+func TestInitErrors(t *testing.T) {
+	d := NewDerivative()
+	d.Mode = "ratio"
+	require.Error(t, d.Init())
+
+	d = NewDerivative()
+	d.WraparoundBits = 16
+	require.Error(t, d.Init())
+}
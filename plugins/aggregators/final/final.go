@@ -5,6 +5,7 @@ import (
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/expiry"
 	"github.com/influxdata/telegraf/plugins/aggregators"
 )
 
@@ -24,12 +25,14 @@ type Final struct {
 
 	// The last metric for all series which are active
 	metricCache map[uint64]telegraf.Metric
+	series      *expiry.Tracker
 }
 
 func NewFinal() *Final {
 	return &Final{
 		SeriesTimeout: internal.Duration{Duration: 5 * time.Minute},
 		metricCache:   make(map[uint64]telegraf.Metric),
+		series:        expiry.NewTracker(5 * time.Minute),
 	}
 }
 
@@ -44,21 +47,22 @@ func (m *Final) Description() string {
 func (m *Final) Add(in telegraf.Metric) {
 	id := in.HashID()
 	m.metricCache[id] = in
+	m.series.Update(id, in.Time())
 }
 
 func (m *Final) Push(acc telegraf.Accumulator) {
 	// Preserve timestamp of original metric
 	acc.SetPrecision(time.Nanosecond)
 
-	for id, metric := range m.metricCache {
-		if time.Since(metric.Time()) > m.SeriesTimeout.Duration {
-			fields := map[string]interface{}{}
-			for _, field := range metric.FieldList() {
-				fields[field.Key+"_final"] = field.Value
-			}
-			acc.AddFields(metric.Name(), fields, metric.Tags(), metric.Time())
-			delete(m.metricCache, id)
+	m.series.Timeout = m.SeriesTimeout.Duration
+	for _, id := range m.series.Expire(time.Now()) {
+		metric := m.metricCache[id]
+		fields := map[string]interface{}{}
+		for _, field := range metric.FieldList() {
+			fields[field.Key+"_final"] = field.Value
 		}
+		acc.AddFields(metric.Name(), fields, metric.Tags(), metric.Time())
+		delete(m.metricCache, id)
 	}
 }
 
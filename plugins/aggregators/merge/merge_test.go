@@ -0,0 +1,104 @@
+package merge
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func newMerge() *Merge {
+	return &Merge{grouper: metric.NewSeriesGrouper()}
+}
+
+func TestSimple(t *testing.T) {
+	plugin := newMerge()
+
+	plugin.Add(testutil.MustMetric(
+		"cpu",
+		map[string]string{"cpu": "cpu0"},
+		map[string]interface{}{"time_idle": 42},
+		time.Unix(0, 0),
+	))
+	plugin.Add(testutil.MustMetric(
+		"cpu",
+		map[string]string{"cpu": "cpu0"},
+		map[string]interface{}{"time_guest": 42},
+		time.Unix(0, 0),
+	))
+
+	var acc testutil.Accumulator
+	plugin.Push(&acc)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric(
+			"cpu",
+			map[string]string{"cpu": "cpu0"},
+			map[string]interface{}{
+				"time_idle":  42,
+				"time_guest": 42,
+			},
+			time.Unix(0, 0),
+		),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics())
+}
+
+func TestReset(t *testing.T) {
+	plugin := newMerge()
+
+	plugin.Add(testutil.MustMetric(
+		"cpu",
+		map[string]string{"cpu": "cpu0"},
+		map[string]interface{}{"time_idle": 42},
+		time.Unix(0, 0),
+	))
+
+	var acc testutil.Accumulator
+	plugin.Push(&acc)
+
+	plugin.Reset()
+
+	plugin.Add(testutil.MustMetric(
+		"cpu",
+		map[string]string{"cpu": "cpu0"},
+		map[string]interface{}{"time_guest": 42},
+		time.Unix(0, 0),
+	))
+
+	plugin.Push(&acc)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric(
+			"cpu",
+			map[string]string{"cpu": "cpu0"},
+			map[string]interface{}{"time_idle": 42},
+			time.Unix(0, 0),
+		),
+		testutil.MustMetric(
+			"cpu",
+			map[string]string{"cpu": "cpu0"},
+			map[string]interface{}{"time_guest": 42},
+			time.Unix(0, 0),
+		),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics())
+}
+
+func TestSeparateSeriesAndTimes(t *testing.T) {
+	plugin := newMerge()
+
+	plugin.Add(testutil.MustMetric("cpu", map[string]string{"cpu": "cpu0"},
+		map[string]interface{}{"a": 1}, time.Unix(0, 0)))
+	plugin.Add(testutil.MustMetric("cpu", map[string]string{"cpu": "cpu1"},
+		map[string]interface{}{"a": 2}, time.Unix(0, 0)))
+	plugin.Add(testutil.MustMetric("cpu", map[string]string{"cpu": "cpu0"},
+		map[string]interface{}{"a": 3}, time.Unix(1, 0)))
+
+	var acc testutil.Accumulator
+	plugin.Push(&acc)
+	require.Len(t, acc.GetTelegrafMetrics(), 3)
+}
@@ -0,0 +1,219 @@
+package quantile
+
+import (
+	"math"
+	"sort"
+)
+
+type algorithm interface {
+	Add(value float64)
+	Quantile(q float64) float64
+}
+
+// exact keeps all values and computes the quantiles with linear
+// interpolation between the closest ranks.
+type exact struct {
+	values []float64
+	sorted bool
+}
+
+func newExact() algorithm {
+	return &exact{}
+}
+
+func (e *exact) Add(value float64) {
+	e.values = append(e.values, value)
+	e.sorted = false
+}
+
+func (e *exact) Quantile(q float64) float64 {
+	if len(e.values) == 0 {
+		return math.NaN()
+	}
+	if !e.sorted {
+		sort.Float64s(e.values)
+		e.sorted = true
+	}
+
+	rank := q * float64(len(e.values)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	frac := rank - float64(lower)
+	return e.values[lower] + frac*(e.values[upper]-e.values[lower])
+}
+
+type centroid struct {
+	mean   float64
+	weight float64
+}
+
+// quantile interpolates the quantile between the centers of the sorted
+// centroids, and between the minimum or maximum and the outer centroids.
+func quantile(centroids []centroid, total, min, max, q float64) float64 {
+	if len(centroids) == 0 {
+		return math.NaN()
+	}
+	if len(centroids) == 1 {
+		return centroids[0].mean
+	}
+
+	index := q * total
+	if index <= centroids[0].weight/2 {
+		return interpolate(min, centroids[0].mean, index/(centroids[0].weight/2))
+	}
+
+	var cumulative float64
+	for i := 0; i < len(centroids)-1; i++ {
+		left := cumulative + centroids[i].weight/2
+		right := cumulative + centroids[i].weight + centroids[i+1].weight/2
+		if index <= right {
+			return interpolate(centroids[i].mean, centroids[i+1].mean, (index-left)/(right-left))
+		}
+		cumulative += centroids[i].weight
+	}
+
+	last := centroids[len(centroids)-1]
+	left := total - last.weight/2
+	return interpolate(last.mean, max, (index-left)/(last.weight/2))
+}
+
+func interpolate(a, b, frac float64) float64 {
+	return a + frac*(b-a)
+}
+
+// tdigest is a merging t-digest, values are buffered and merged into
+// centroids whose size is bounded by the compression and their quantile.
+type tdigest struct {
+	compression float64
+	centroids   []centroid
+	buffer      []centroid
+	total       float64
+	min         float64
+	max         float64
+}
+
+func newTDigest(compression float64) algorithm {
+	return &tdigest{
+		compression: compression,
+		min:         math.Inf(1),
+		max:         math.Inf(-1),
+	}
+}
+
+func (t *tdigest) Add(value float64) {
+	t.buffer = append(t.buffer, centroid{mean: value, weight: 1})
+	t.min = math.Min(t.min, value)
+	t.max = math.Max(t.max, value)
+	if len(t.buffer) >= int(5*t.compression) {
+		t.merge()
+	}
+}
+
+func (t *tdigest) merge() {
+	if len(t.buffer) == 0 {
+		return
+	}
+
+	all := append(t.centroids, t.buffer...)
+	t.buffer = t.buffer[:0]
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].mean < all[j].mean
+	})
+
+	var total float64
+	for _, c := range all {
+		total += c.weight
+	}
+
+	merged := make([]centroid, 0, len(t.centroids)+1)
+	current := all[0]
+	var before float64
+	for _, c := range all[1:] {
+		q0 := before / total
+		q2 := (before + current.weight + c.weight) / total
+		limit := 4 * total * math.Min(q0*(1-q0), q2*(1-q2)) / t.compression
+
+		if current.weight+c.weight <= limit {
+			weight := current.weight + c.weight
+			current.mean += (c.mean - current.mean) * c.weight / weight
+			current.weight = weight
+			continue
+		}
+
+		before += current.weight
+		merged = append(merged, current)
+		current = c
+	}
+	merged = append(merged, current)
+
+	t.centroids = merged
+	t.total = total
+}
+
+func (t *tdigest) Quantile(q float64) float64 {
+	t.merge()
+	return quantile(t.centroids, t.total, t.min, t.max, q)
+}
+
+// histogram is a streaming histogram as described by Ben-Haim and Tom-Tov,
+// the closest bins are merged when the number of bins exceeds the maximum.
+type histogram struct {
+	maxBins int
+	bins    []centroid
+	total   float64
+	min     float64
+	max     float64
+}
+
+func newHistogram(maxBins int) algorithm {
+	return &histogram{
+		maxBins: maxBins,
+		min:     math.Inf(1),
+		max:     math.Inf(-1),
+	}
+}
+
+func (h *histogram) Add(value float64) {
+	h.total++
+	h.min = math.Min(h.min, value)
+	h.max = math.Max(h.max, value)
+
+	i := sort.Search(len(h.bins), func(i int) bool {
+		return h.bins[i].mean >= value
+	})
+	if i < len(h.bins) && h.bins[i].mean == value {
+		h.bins[i].weight++
+		return
+	}
+
+	h.bins = append(h.bins, centroid{})
+	copy(h.bins[i+1:], h.bins[i:])
+	h.bins[i] = centroid{mean: value, weight: 1}
+
+	if len(h.bins) > h.maxBins {
+		h.mergeClosest()
+	}
+}
+
+func (h *histogram) mergeClosest() {
+	closest := 0
+	minDelta := math.Inf(1)
+	for i := 0; i < len(h.bins)-1; i++ {
+		if delta := h.bins[i+1].mean - h.bins[i].mean; delta < minDelta {
+			minDelta = delta
+			closest = i
+		}
+	}
+
+	a, b := h.bins[closest], h.bins[closest+1]
+	weight := a.weight + b.weight
+	h.bins[closest] = centroid{
+		mean:   (a.mean*a.weight + b.mean*b.weight) / weight,
+		weight: weight,
+	}
+	h.bins = append(h.bins[:closest+1], h.bins[closest+2:]...)
+}
+
+func (h *histogram) Quantile(q float64) float64 {
+	return quantile(h.bins, h.total, h.min, h.max, q)
+}
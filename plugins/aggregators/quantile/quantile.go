@@ -0,0 +1,169 @@
+package quantile
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/aggregators"
+)
+
+var sampleConfig = `
+  ## The period on which to flush & clear the aggregator.
+  period = "30s"
+  ## If true, the original metric will be dropped by the
+  ## aggregator and will not get sent to the output plugins.
+  drop_original = false
+
+  ## Quantiles to output in the range [0,1]
+  # quantiles = [0.25, 0.5, 0.75]
+
+  ## Type of aggregation algorithm
+  ## Supported are:
+  ##  "t-digest"  -- approximation using centroids, can cope with large number of samples
+  ##  "exact"     -- exact computation, keeps all samples of the period in memory
+  ##  "histogram" -- approximation using a merging histogram with a fixed number of bins
+  # algorithm = "t-digest"
+
+  ## Compression of the approximation algorithm, for "t-digest" higher values
+  ## are more accurate, for "histogram" it is the maximum number of bins.
+  # compression = 100.0
+`
+
+type Quantile struct {
+	Quantiles   []float64 `toml:"quantiles"`
+	Algorithm   string    `toml:"algorithm"`
+	Compression float64   `toml:"compression"`
+
+	cache        map[uint64]aggregate
+	suffixes     []string
+	newAlgorithm func() algorithm
+}
+
+type aggregate struct {
+	name   string
+	tags   map[string]string
+	fields map[string]algorithm
+}
+
+func NewQuantile() *Quantile {
+	q := &Quantile{
+		Quantiles:   []float64{0.25, 0.5, 0.75},
+		Algorithm:   "t-digest",
+		Compression: 100,
+	}
+	q.Reset()
+	return q
+}
+
+func (q *Quantile) SampleConfig() string {
+	return sampleConfig
+}
+
+func (q *Quantile) Description() string {
+	return "Keep the aggregate quantiles of each metric passing through."
+}
+
+func (q *Quantile) Init() error {
+	q.suffixes = make([]string, 0, len(q.Quantiles))
+	for _, quantile := range q.Quantiles {
+		if quantile < 0 || quantile > 1 {
+			return fmt.Errorf("quantile %v out of range [0,1]", quantile)
+		}
+		q.suffixes = append(q.suffixes, suffix(quantile))
+	}
+
+	if q.Compression <= 0 {
+		return fmt.Errorf("compression must be greater than zero")
+	}
+
+	switch q.Algorithm {
+	case "t-digest":
+		q.newAlgorithm = func() algorithm { return newTDigest(q.Compression) }
+	case "exact":
+		q.newAlgorithm = newExact
+	case "histogram":
+		bins := int(q.Compression)
+		if bins < 2 {
+			bins = 2
+		}
+		q.newAlgorithm = func() algorithm { return newHistogram(bins) }
+	default:
+		return fmt.Errorf("unknown algorithm: %s", q.Algorithm)
+	}
+	return nil
+}
+
+// suffix returns the field suffix of a quantile, e.g. "_p95" for 0.95 or
+// "_p99_9" for 0.999.  The percentage is rounded to four decimals to drop
+// the floating point noise of quantile*100.
+func suffix(quantile float64) string {
+	percent := strconv.FormatFloat(math.Round(quantile*1e6)/1e4, 'f', -1, 64)
+	return "_p" + strings.Replace(percent, ".", "_", 1)
+}
+
+func (q *Quantile) Add(in telegraf.Metric) {
+	id := in.HashID()
+	agg, ok := q.cache[id]
+	if !ok {
+		agg = aggregate{
+			name:   in.Name(),
+			tags:   in.Tags(),
+			fields: make(map[string]algorithm),
+		}
+		q.cache[id] = agg
+	}
+
+	for _, field := range in.FieldList() {
+		value, ok := convert(field.Value)
+		if !ok {
+			continue
+		}
+
+		algo, ok := agg.fields[field.Key]
+		if !ok {
+			algo = q.newAlgorithm()
+			agg.fields[field.Key] = algo
+		}
+		algo.Add(value)
+	}
+}
+
+func (q *Quantile) Push(acc telegraf.Accumulator) {
+	for _, agg := range q.cache {
+		fields := make(map[string]interface{})
+		for key, algo := range agg.fields {
+			for i, quantile := range q.Quantiles {
+				fields[key+q.suffixes[i]] = algo.Quantile(quantile)
+			}
+		}
+		if len(fields) > 0 {
+			acc.AddFields(agg.name, fields, agg.tags)
+		}
+	}
+}
+
+func (q *Quantile) Reset() {
+	q.cache = make(map[uint64]aggregate)
+}
+
+func convert(in interface{}) (float64, bool) {
+	switch v := in.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
+
+func init() {
+	aggregators.Add("quantile", func() telegraf.Aggregator {
+		return NewQuantile()
+	})
+}
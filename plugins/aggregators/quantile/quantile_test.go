@@ -0,0 +1,132 @@
+package quantile
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuffix(t *testing.T) {
+	require.Equal(t, "_p50", suffix(0.5))
+	require.Equal(t, "_p95", suffix(0.95))
+	require.Equal(t, "_p99_9", suffix(0.999))
+	require.Equal(t, "_p0", suffix(0))
+	require.Equal(t, "_p100", suffix(1))
+	require.Equal(t, "_p7", suffix(0.07))
+	require.Equal(t, "_p29", suffix(0.29))
+	require.Equal(t, "_p57", suffix(0.57))
+	require.Equal(t, "_p99_99", suffix(0.9999))
+}
+
+func TestExact(t *testing.T) {
+	q := NewQuantile()
+	q.Algorithm = "exact"
+	q.Quantiles = []float64{0, 0.5, 0.75, 1}
+	require.NoError(t, q.Init())
+
+	for _, v := range []interface{}{int64(4), 1.0, uint64(3), 2.0, "ignored"} {
+		q.Add(testutil.MustMetric(
+			"m1",
+			map[string]string{"foo": "bar"},
+			map[string]interface{}{"a": v},
+			time.Unix(0, 0),
+		))
+	}
+
+	acc := testutil.Accumulator{}
+	q.Push(&acc)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric(
+			"m1",
+			map[string]string{"foo": "bar"},
+			map[string]interface{}{
+				"a_p0":   1.0,
+				"a_p50":  2.5,
+				"a_p75":  3.25,
+				"a_p100": 4.0,
+			},
+			time.Unix(0, 0),
+		),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.IgnoreTime())
+}
+
+func TestReset(t *testing.T) {
+	q := NewQuantile()
+	require.NoError(t, q.Init())
+	q.Add(testutil.MustMetric("m1", map[string]string{}, map[string]interface{}{"a": 1.0}, time.Unix(0, 0)))
+	q.Reset()
+
+	acc := testutil.Accumulator{}
+	q.Push(&acc)
+	require.Len(t, acc.GetTelegrafMetrics(), 0)
+}
+
+func TestInvalidConfig(t *testing.T) {
+	for _, q := range []*Quantile{
+		{Quantiles: []float64{1.5}, Algorithm: "exact", Compression: 100},
+		{Quantiles: []float64{0.5}, Algorithm: "unknown", Compression: 100},
+		{Quantiles: []float64{0.5}, Algorithm: "t-digest", Compression: 0},
+	} {
+		require.Error(t, q.Init())
+	}
+}
+
+func TestApproximations(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+	values := make([]float64, 0, 100000)
+	for i := 0; i < cap(values); i++ {
+		values = append(values, r.NormFloat64()*10+100)
+	}
+
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	tests := []struct {
+		name      string
+		algorithm algorithm
+		tolerance float64
+	}{
+		{"t-digest", newTDigest(100), 0.005},
+		{"histogram", newHistogram(100), 0.01},
+		{"exact", newExact(), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, v := range values {
+				tt.algorithm.Add(v)
+			}
+
+			for _, quantile := range []float64{0.01, 0.25, 0.5, 0.75, 0.95, 0.99} {
+				// compare the rank of the estimate to the requested quantile
+				estimate := tt.algorithm.Quantile(quantile)
+				rank := float64(sort.SearchFloat64s(sorted, estimate)) / float64(len(sorted))
+				require.InDelta(t, quantile, rank, tt.tolerance+1e-5, "quantile %v", quantile)
+			}
+
+			require.Equal(t, sorted[0], tt.algorithm.Quantile(0))
+			require.Equal(t, sorted[len(sorted)-1], tt.algorithm.Quantile(1))
+		})
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	for _, algo := range []algorithm{newTDigest(100), newHistogram(100), newExact()} {
+		require.True(t, math.IsNaN(algo.Quantile(0.5)))
+	}
+}
+
+func TestSingleValue(t *testing.T) {
+	for _, algo := range []algorithm{newTDigest(100), newHistogram(100), newExact()} {
+		algo.Add(42)
+		require.Equal(t, 42.0, algo.Quantile(0.5))
+	}
+}
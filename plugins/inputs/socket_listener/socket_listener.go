@@ -113,6 +113,9 @@ func (ssl *streamSocketListener) read(c net.Conn) {
 	defer c.Close()
 
 	scnr := bufio.NewScanner(c)
+	if fp, ok := ssl.Parser.(parsers.FramedParser); ok {
+		scnr.Split(fp.SplitFrame)
+	}
 	for {
 		if ssl.ReadTimeout != nil && ssl.ReadTimeout.Duration > 0 {
 			c.SetReadDeadline(time.Now().Add(ssl.ReadTimeout.Duration))
@@ -12,7 +12,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/parsers"
+	"github.com/influxdata/telegraf/plugins/serializers"
 	"github.com/influxdata/telegraf/testutil"
 	"github.com/influxdata/wlog"
 	"github.com/stretchr/testify/assert"
@@ -108,6 +111,41 @@ func TestSocketListener_tcp(t *testing.T) {
 	testSocketListener(t, sl, client)
 }
 
+func TestSocketListener_tcp_msgpack(t *testing.T) {
+	defer testEmptyLog(t)()
+
+	sl := newSocketListener()
+	sl.ServiceAddress = "tcp://127.0.0.1:0"
+	sl.Parser, _ = parsers.NewMsgpackParser(nil)
+
+	acc := &testutil.Accumulator{}
+	err := sl.Start(acc)
+	require.NoError(t, err)
+	defer sl.Stop()
+
+	client, err := net.Dial("tcp", sl.Closer.(net.Listener).Addr().String())
+	require.NoError(t, err)
+
+	serializer, err := serializers.NewMsgpackSerializer()
+	require.NoError(t, err)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric("test", map[string]string{"foo": "bar"},
+			map[string]interface{}{"v": int64(1), "u": uint64(10)}, time.Unix(0, 123456789)),
+		testutil.MustMetric("test", map[string]string{"foo": "baz"},
+			map[string]interface{}{"v": int64(2), "s": "a\nb"}, time.Unix(0, 123456790)),
+	}
+	buf, err := serializer.SerializeBatch(expected)
+	require.NoError(t, err)
+
+	// write the metrics in two chunks split within the first metric
+	client.Write(buf[:5])
+	client.Write(buf[5:])
+
+	acc.Wait(2)
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics())
+}
+
 func TestSocketListener_udp(t *testing.T) {
 	defer testEmptyLog(t)()
 
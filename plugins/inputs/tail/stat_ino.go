@@ -0,0 +1,15 @@
+// +build !solaris,!windows
+
+package tail
+
+import (
+	"syscall"
+)
+
+func statInode(sys interface{}) uint64 {
+	stat, ok := sys.(*syscall.Stat_t)
+	if !ok {
+		return 0
+	}
+	return uint64(stat.Ino)
+}
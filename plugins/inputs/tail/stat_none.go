@@ -0,0 +1,7 @@
+// +build windows
+
+package tail
+
+func statInode(_ interface{}) uint64 {
+	return 0
+}
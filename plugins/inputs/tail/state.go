@@ -0,0 +1,49 @@
+// +build !solaris
+
+package tail
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+)
+
+// fileState is the recorded read position of a tailed file.
+type fileState struct {
+	Offset int64  `json:"offset"`
+	Inode  uint64 `json:"inode,omitempty"`
+}
+
+// loadState reads the per file states from path, a missing file is not an
+// error.
+func loadState(path string) (map[string]fileState, error) {
+	states := make(map[string]fileState)
+
+	buf, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return states, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(buf, &states); err != nil {
+		return nil, err
+	}
+	return states, nil
+}
+
+// saveState writes the per file states to path.  The file is replaced
+// atomically so that a crash while writing does not lose the previous state.
+func saveState(path string, states map[string]fileState) error {
+	buf, err := json.Marshal(states)
+	if err != nil {
+		return err
+	}
+
+	tmpfile := path + ".tmp"
+	if err := ioutil.WriteFile(tmpfile, buf, 0640); err != nil {
+		return err
+	}
+	return os.Rename(tmpfile, path)
+}
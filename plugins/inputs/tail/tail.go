@@ -5,12 +5,15 @@ package tail
 import (
 	"fmt"
 	"log"
+	"os"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/influxdata/tail"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
 	"github.com/influxdata/telegraf/internal/globpath"
 	"github.com/influxdata/telegraf/plugins/inputs"
 	"github.com/influxdata/telegraf/plugins/parsers"
@@ -21,36 +24,42 @@ const (
 )
 
 var (
-	offsets      = make(map[string]int64)
+	offsets      = make(map[string]fileState)
 	offsetsMutex = new(sync.Mutex)
 )
 
 type Tail struct {
-	Files         []string
-	FromBeginning bool
-	Pipe          bool
-	WatchMethod   string
+	Files             []string
+	FromBeginning     bool
+	Pipe              bool
+	WatchMethod       string
+	StateFile         string
+	StateSaveInterval internal.Duration
 
 	tailers    map[string]*tail.Tail
-	offsets    map[string]int64
+	offsets    map[string]fileState
 	parserFunc parsers.ParserFunc
 	wg         sync.WaitGroup
 	acc        telegraf.Accumulator
 
+	done    chan struct{}
+	stateWg sync.WaitGroup
+
 	sync.Mutex
 }
 
 func NewTail() *Tail {
 	offsetsMutex.Lock()
-	offsetsCopy := make(map[string]int64, len(offsets))
+	offsetsCopy := make(map[string]fileState, len(offsets))
 	for k, v := range offsets {
 		offsetsCopy[k] = v
 	}
 	offsetsMutex.Unlock()
 
 	return &Tail{
-		FromBeginning: false,
-		offsets:       offsetsCopy,
+		FromBeginning:     false,
+		StateSaveInterval: internal.Duration{Duration: 10 * time.Second},
+		offsets:           offsetsCopy,
 	}
 }
 
@@ -73,6 +82,15 @@ const sampleConfig = `
   ## Method used to watch for file updates.  Can be either "inotify" or "poll".
   # watch_method = "inotify"
 
+  ## File used to persist the read offset of each tailed file, allowing the
+  ## plugin to resume where it left off after a restart.  The state is not
+  ## used when from_beginning or pipe is set.
+  # state_file = "/var/lib/telegraf/tail.state"
+
+  ## Interval at which the state file is written, the state is also written
+  ## when telegraf is stopped.
+  # state_save_interval = "10s"
+
   ## Data format to consume.
   ## Each data format has its own unique set of configuration options, read
   ## more about them here:
@@ -102,15 +120,34 @@ func (t *Tail) Start(acc telegraf.Accumulator) error {
 	t.acc = acc
 	t.tailers = make(map[string]*tail.Tail)
 
+	if t.StateFile != "" {
+		states, err := loadState(t.StateFile)
+		if err != nil {
+			acc.AddError(fmt.Errorf("error loading state file %s: %v", t.StateFile, err))
+		}
+		for file, state := range states {
+			// offsets kept in memory across a reload are more recent
+			if _, ok := t.offsets[file]; !ok {
+				t.offsets[file] = state
+			}
+		}
+	}
+
 	err := t.tailNewFiles(t.FromBeginning)
 
 	// clear offsets
-	t.offsets = make(map[string]int64)
+	t.offsets = make(map[string]fileState)
 	// assumption that once Start is called, all parallel plugins have already been initialized
 	offsetsMutex.Lock()
-	offsets = make(map[string]int64)
+	offsets = make(map[string]fileState)
 	offsetsMutex.Unlock()
 
+	if t.StateFile != "" && !t.Pipe && !t.FromBeginning && t.StateSaveInterval.Duration > 0 {
+		t.done = make(chan struct{})
+		t.stateWg.Add(1)
+		go t.saveStatePeriodically()
+	}
+
 	return err
 }
 
@@ -134,7 +171,7 @@ func (t *Tail) tailNewFiles(fromBeginning bool) error {
 
 			var seek *tail.SeekInfo
 			if !t.Pipe && !fromBeginning {
-				if offset, ok := t.offsets[file]; ok {
+				if offset, ok := t.resumeOffset(file); ok {
 					log.Printf("D! [inputs.tail] using offset %d for file: %v", offset, file)
 					seek = &tail.SeekInfo{
 						Whence: 0,
@@ -231,16 +268,95 @@ func (t *Tail) receiver(parser parsers.Parser, tailer *tail.Tail) {
 	}
 }
 
+// resumeOffset returns the offset to resume reading the file from, if one was
+// recorded.  A file that has been rotated or truncated since the offset was
+// recorded is read from the start.
+func (t *Tail) resumeOffset(file string) (int64, bool) {
+	state, ok := t.offsets[file]
+	if !ok {
+		return 0, false
+	}
+
+	fi, err := os.Stat(file)
+	if err != nil {
+		return 0, false
+	}
+
+	if inode := statInode(fi.Sys()); state.Inode != 0 && inode != 0 && inode != state.Inode {
+		log.Printf("D! [inputs.tail] file was rotated, reading from start: %v", file)
+		return 0, true
+	}
+
+	if fi.Size() < state.Offset {
+		log.Printf("D! [inputs.tail] file was truncated, reading from start: %v", file)
+		return 0, true
+	}
+
+	return state.Offset, true
+}
+
+// tailerState returns the current position of the tailer.
+func tailerState(tailer *tail.Tail) (fileState, error) {
+	offset, err := tailer.Tell()
+	if err != nil {
+		return fileState{}, err
+	}
+
+	state := fileState{Offset: offset}
+	if fi, err := os.Stat(tailer.Filename); err == nil {
+		state.Inode = statInode(fi.Sys())
+	}
+	return state, nil
+}
+
+// saveStatePeriodically writes the state file every StateSaveInterval until
+// the plugin is stopped.
+func (t *Tail) saveStatePeriodically() {
+	defer t.stateWg.Done()
+
+	ticker := time.NewTicker(t.StateSaveInterval.Duration)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.done:
+			return
+		case <-ticker.C:
+			t.Lock()
+			states := make(map[string]fileState, len(t.tailers))
+			for _, tailer := range t.tailers {
+				state, err := tailerState(tailer)
+				if err != nil {
+					continue
+				}
+				states[tailer.Filename] = state
+			}
+			t.Unlock()
+
+			if err := saveState(t.StateFile, states); err != nil {
+				t.acc.AddError(fmt.Errorf("error writing state file %s: %v", t.StateFile, err))
+			}
+		}
+	}
+}
+
 func (t *Tail) Stop() {
+	if t.done != nil {
+		close(t.done)
+		t.stateWg.Wait()
+		t.done = nil
+	}
+
 	t.Lock()
 	defer t.Unlock()
 
 	for _, tailer := range t.tailers {
 		if !t.Pipe && !t.FromBeginning {
 			// store offset for resume
-			offset, err := tailer.Tell()
+			state, err := tailerState(tailer)
 			if err == nil {
-				log.Printf("D! [inputs.tail] recording offset %d for file: %v", offset, tailer.Filename)
+				log.Printf("D! [inputs.tail] recording offset %d for file: %v", state.Offset, tailer.Filename)
+				t.offsets[tailer.Filename] = state
 			} else {
 				t.acc.AddError(fmt.Errorf("error recording offset for file %s", tailer.Filename))
 			}
@@ -253,6 +369,12 @@ func (t *Tail) Stop() {
 
 	t.wg.Wait()
 
+	if t.StateFile != "" && !t.Pipe && !t.FromBeginning {
+		if err := saveState(t.StateFile, t.offsets); err != nil {
+			t.acc.AddError(fmt.Errorf("error writing state file %s: %v", t.StateFile, err))
+		}
+	}
+
 	// persist offsets
 	offsetsMutex.Lock()
 	for k, v := range t.offsets {
@@ -139,3 +139,119 @@ func TestTailDosLineendings(t *testing.T) {
 			"usage_idle": float64(200),
 		})
 }
+
+func writeTestState(t *testing.T, file string, offset int64) string {
+	fi, err := os.Stat(file)
+	require.NoError(t, err)
+
+	statefile, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	statefile.Close()
+
+	states := map[string]fileState{
+		file: {Offset: offset, Inode: statInode(fi.Sys())},
+	}
+	require.NoError(t, saveState(statefile.Name(), states))
+	return statefile.Name()
+}
+
+func TestTailStateFileResume(t *testing.T) {
+	tmpfile, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	defer os.Remove(tmpfile.Name())
+	defer tmpfile.Close()
+
+	line := "cpu,mytag=foo usage_idle=100\n"
+	_, err = tmpfile.WriteString(line)
+	require.NoError(t, err)
+
+	statefile := writeTestState(t, tmpfile.Name(), int64(len(line)))
+	defer os.Remove(statefile)
+
+	// written while telegraf was not running
+	_, err = tmpfile.WriteString("cpu,othertag=foo usage_idle=100\n")
+	require.NoError(t, err)
+
+	tt := NewTail()
+	tt.Files = []string{tmpfile.Name()}
+	tt.StateFile = statefile
+	tt.SetParserFunc(parsers.NewInfluxParser)
+	defer tt.Stop()
+
+	acc := testutil.Accumulator{}
+	require.NoError(t, tt.Start(&acc))
+	require.NoError(t, acc.GatherError(tt.Gather))
+
+	acc.Wait(1)
+	acc.AssertContainsTaggedFields(t, "cpu",
+		map[string]interface{}{
+			"usage_idle": float64(100),
+		},
+		map[string]string{
+			"othertag": "foo",
+			"path":     tmpfile.Name(),
+		})
+	assert.Len(t, acc.Metrics, 1)
+}
+
+func TestTailStateFileTruncated(t *testing.T) {
+	tmpfile, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	defer os.Remove(tmpfile.Name())
+	defer tmpfile.Close()
+
+	_, err = tmpfile.WriteString("cpu,mytag=foo usage_idle=100\n")
+	require.NoError(t, err)
+
+	statefile := writeTestState(t, tmpfile.Name(), 4096)
+	defer os.Remove(statefile)
+
+	tt := NewTail()
+	tt.Files = []string{tmpfile.Name()}
+	tt.StateFile = statefile
+	tt.SetParserFunc(parsers.NewInfluxParser)
+	defer tt.Stop()
+
+	acc := testutil.Accumulator{}
+	require.NoError(t, tt.Start(&acc))
+	require.NoError(t, acc.GatherError(tt.Gather))
+
+	acc.Wait(1)
+	acc.AssertContainsTaggedFields(t, "cpu",
+		map[string]interface{}{
+			"usage_idle": float64(100),
+		},
+		map[string]string{
+			"mytag": "foo",
+			"path":  tmpfile.Name(),
+		})
+}
+
+func TestTailStateFileWrittenOnStop(t *testing.T) {
+	tmpfile, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	defer os.Remove(tmpfile.Name())
+	defer tmpfile.Close()
+
+	line := "cpu,mytag=foo usage_idle=100\n"
+	_, err = tmpfile.WriteString(line)
+	require.NoError(t, err)
+
+	statefile := writeTestState(t, tmpfile.Name(), 0)
+	defer os.Remove(statefile)
+
+	tt := NewTail()
+	tt.Files = []string{tmpfile.Name()}
+	tt.StateFile = statefile
+	tt.SetParserFunc(parsers.NewInfluxParser)
+
+	acc := testutil.Accumulator{}
+	require.NoError(t, tt.Start(&acc))
+	acc.Wait(1)
+	tt.Stop()
+
+	states, err := loadState(statefile)
+	require.NoError(t, err)
+	require.Contains(t, states, tmpfile.Name())
+	assert.Equal(t, int64(len(line)), states[tmpfile.Name()].Offset)
+}
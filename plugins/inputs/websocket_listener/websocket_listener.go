@@ -0,0 +1,298 @@
+package websocket_listener
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	tlsint "github.com/influxdata/telegraf/internal/tls"
+	"github.com/influxdata/telegraf/plugins/inputs"
+	"github.com/influxdata/telegraf/plugins/parsers"
+	"golang.org/x/net/websocket"
+)
+
+// defaultMaxMessageSize is the default maximum size of a message, in bytes.
+const defaultMaxMessageSize = 1024 * 1024
+
+const sampleConfig = `
+  ## Address and port to accept WebSocket connections on.
+  service_address = ":8080"
+
+  ## Path of the WebSocket endpoint.
+  # path = "/telegraf"
+
+  ## Maximum size of a message; connections sending larger messages are
+  ## closed.
+  # max_message_size = "1MB"
+
+  ## Maximum number of open connections, 0 means unlimited.
+  # max_connections = 0
+
+  ## Origins allowed to connect, for connections from browsers.  If empty
+  ## only connections without an origin, or from the origin of the listener
+  ## itself, are allowed.
+  # allowed_origins = ["https://dashboard.example.com"]
+
+  ## Set one or more allowed client CA certificate file names to
+  ## enable mutually authenticated TLS connections
+  # tls_allowed_cacerts = ["/etc/telegraf/clientca.pem"]
+
+  ## Add service certificate and key
+  # tls_cert = "/etc/telegraf/cert.pem"
+  # tls_key = "/etc/telegraf/key.pem"
+
+  ## Optional username and password to accept for HTTP basic authentication.
+  ## You probably want to make sure you have TLS configured above for this.
+  # basic_username = "foobar"
+  # basic_password = "barfoo"
+
+  ## Optional tokens to accept, sent as "Authorization: Bearer <token>" or,
+  ## when headers cannot be set, as the "token" query parameter.
+  # tokens = ["mytoken"]
+
+  ## Data format to consume.
+  ## Each data format has its own unique set of configuration options, read
+  ## more about them here:
+  ## https://github.com/influxdata/telegraf/blob/master/docs/DATA_FORMATS_INPUT.md
+  data_format = "influx"
+`
+
+// WebSocketListener is an input plugin that accepts metrics sent over
+// WebSocket connections.
+type WebSocketListener struct {
+	ServiceAddress string        `toml:"service_address"`
+	Path           string        `toml:"path"`
+	MaxMessageSize internal.Size `toml:"max_message_size"`
+	MaxConnections int           `toml:"max_connections"`
+	AllowedOrigins []string      `toml:"allowed_origins"`
+	BasicUsername  string        `toml:"basic_username"`
+	BasicPassword  string        `toml:"basic_password"`
+	Tokens         []string      `toml:"tokens"`
+	tlsint.ServerConfig
+
+	wg       sync.WaitGroup
+	listener net.Listener
+	server   websocket.Server
+
+	mu      sync.Mutex
+	conns   map[*websocket.Conn]struct{}
+	stopped bool
+
+	parsers.Parser
+	acc telegraf.Accumulator
+}
+
+func (w *WebSocketListener) SampleConfig() string {
+	return sampleConfig
+}
+
+func (w *WebSocketListener) Description() string {
+	return "Accept metrics over WebSocket connections"
+}
+
+func (w *WebSocketListener) Gather(_ telegraf.Accumulator) error {
+	return nil
+}
+
+func (w *WebSocketListener) SetParser(parser parsers.Parser) {
+	w.Parser = parser
+}
+
+// Start starts the WebSocket listener service.
+func (w *WebSocketListener) Start(acc telegraf.Accumulator) error {
+	if w.MaxMessageSize.Size == 0 {
+		w.MaxMessageSize.Size = defaultMaxMessageSize
+	}
+
+	w.acc = acc
+	w.conns = make(map[*websocket.Conn]struct{})
+	w.server = websocket.Server{
+		Handshake: w.handshake,
+		Handler:   w.handle,
+	}
+
+	tlsConf, err := w.ServerConfig.TLSConfig()
+	if err != nil {
+		return err
+	}
+
+	var listener net.Listener
+	if tlsConf != nil {
+		listener, err = tls.Listen("tcp", w.ServiceAddress, tlsConf)
+	} else {
+		listener, err = net.Listen("tcp", w.ServiceAddress)
+	}
+	if err != nil {
+		return err
+	}
+	w.listener = listener
+
+	server := &http.Server{
+		Handler:   w,
+		TLSConfig: tlsConf,
+	}
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		server.Serve(w.listener)
+	}()
+
+	log.Printf("I! [inputs.websocket_listener] Listening on %s", listener.Addr().String())
+
+	return nil
+}
+
+// Stop closes the listener and all connections.
+func (w *WebSocketListener) Stop() {
+	w.listener.Close()
+
+	w.mu.Lock()
+	w.stopped = true
+	for conn := range w.conns {
+		conn.Close()
+	}
+	w.mu.Unlock()
+
+	w.wg.Wait()
+}
+
+func (w *WebSocketListener) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != w.Path {
+		http.NotFound(res, req)
+		return
+	}
+
+	if !w.authenticate(req) {
+		http.Error(res, "Unauthorized.", http.StatusUnauthorized)
+		return
+	}
+
+	w.server.ServeHTTP(res, req)
+}
+
+// authenticate checks the basic authentication credentials and the tokens
+// when set; either one is sufficient.
+func (w *WebSocketListener) authenticate(req *http.Request) bool {
+	useBasic := w.BasicUsername != "" && w.BasicPassword != ""
+	if !useBasic && len(w.Tokens) == 0 {
+		return true
+	}
+
+	if useBasic {
+		username, password, ok := req.BasicAuth()
+		if ok &&
+			subtle.ConstantTimeCompare([]byte(username), []byte(w.BasicUsername)) == 1 &&
+			subtle.ConstantTimeCompare([]byte(password), []byte(w.BasicPassword)) == 1 {
+			return true
+		}
+	}
+
+	token := req.URL.Query().Get("token")
+	if auth := req.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token = strings.TrimPrefix(auth, "Bearer ")
+	}
+	if token == "" {
+		return false
+	}
+	for _, t := range w.Tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// handshake checks the origin of the connection, which browsers always
+// send.  Without allowed origins only the origin of the listener itself is
+// allowed, so that other sites cannot connect with the credentials stored
+// in the browser.
+func (w *WebSocketListener) handshake(config *websocket.Config, req *http.Request) error {
+	origin := req.Header.Get("Origin")
+	if len(w.AllowedOrigins) == 0 {
+		if origin == "" {
+			return nil
+		}
+		u, err := url.Parse(origin)
+		if err != nil || !strings.EqualFold(u.Host, req.Host) {
+			return fmt.Errorf("origin %q not allowed", origin)
+		}
+		return nil
+	}
+
+	for _, allowed := range w.AllowedOrigins {
+		if origin == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("origin %q not allowed", origin)
+}
+
+func (w *WebSocketListener) handle(conn *websocket.Conn) {
+	w.mu.Lock()
+	if w.stopped {
+		w.mu.Unlock()
+		conn.Close()
+		return
+	}
+	if w.MaxConnections > 0 && len(w.conns) >= w.MaxConnections {
+		w.mu.Unlock()
+		log.Printf("D! [inputs.websocket_listener] Too many connections, closing connection from %s",
+			conn.Request().RemoteAddr)
+		conn.Close()
+		return
+	}
+	w.conns[conn] = struct{}{}
+	w.wg.Add(1)
+	w.mu.Unlock()
+
+	defer func() {
+		w.mu.Lock()
+		delete(w.conns, conn)
+		w.mu.Unlock()
+		conn.Close()
+		w.wg.Done()
+	}()
+
+	conn.MaxPayloadBytes = int(w.MaxMessageSize.Size)
+	for {
+		var msg []byte
+		err := websocket.Message.Receive(conn, &msg)
+		if err == websocket.ErrFrameTooLarge {
+			w.acc.AddError(fmt.Errorf("message from %s larger than %d bytes",
+				conn.Request().RemoteAddr, w.MaxMessageSize.Size))
+			return
+		}
+		if err != nil {
+			return
+		}
+
+		metrics, err := w.Parse(msg)
+		if err != nil {
+			w.acc.AddError(fmt.Errorf("unable to parse message from %s: %v",
+				conn.Request().RemoteAddr, err))
+			continue
+		}
+
+		for _, m := range metrics {
+			w.acc.AddMetric(m)
+		}
+	}
+}
+
+func init() {
+	inputs.Add("websocket_listener", func() telegraf.Input {
+		return &WebSocketListener{
+			ServiceAddress: ":8080",
+			Path:           "/telegraf",
+		}
+	})
+}
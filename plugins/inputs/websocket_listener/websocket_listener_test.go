@@ -0,0 +1,190 @@
+package websocket_listener
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/parsers"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+)
+
+var pki = testutil.NewPKI("../../../testutil/pki")
+
+func newListener() *WebSocketListener {
+	parser, _ := parsers.NewInfluxParser()
+	listener := &WebSocketListener{
+		ServiceAddress: "127.0.0.1:0",
+		Path:           "/telegraf",
+	}
+	listener.SetParser(parser)
+	return listener
+}
+
+func dial(t *testing.T, w *WebSocketListener, scheme, query string, header http.Header) (*websocket.Conn, error) {
+	return dialOrigin(t, w, scheme, query, header, "http://"+w.listener.Addr().String()+"/")
+}
+
+func dialOrigin(t *testing.T, w *WebSocketListener, scheme, query string, header http.Header, origin string) (*websocket.Conn, error) {
+	config, err := websocket.NewConfig(
+		scheme+"://"+w.listener.Addr().String()+w.Path+query, origin)
+	require.NoError(t, err)
+	if header != nil {
+		config.Header = header
+	}
+	if scheme == "wss" {
+		config.TlsConfig, err = pki.TLSClientConfig().TLSConfig()
+		require.NoError(t, err)
+	}
+	return websocket.DialConfig(config)
+}
+
+func TestWriteMessages(t *testing.T) {
+	listener := newListener()
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	conn, err := dial(t, listener, "ws", "", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, websocket.Message.Send(conn, "cpu value=42 0\n"))
+	require.NoError(t, websocket.Message.Send(conn, []byte("cpu value=43 1000000000\nmem free=1i 0\n")))
+	acc.Wait(3)
+
+	testutil.RequireMetricsEqual(t, []telegraf.Metric{
+		testutil.MustMetric("cpu", map[string]string{}, map[string]interface{}{"value": 42.0}, time.Unix(0, 0)),
+		testutil.MustMetric("cpu", map[string]string{}, map[string]interface{}{"value": 43.0}, time.Unix(1, 0)),
+		testutil.MustMetric("mem", map[string]string{}, map[string]interface{}{"free": int64(1)}, time.Unix(0, 0)),
+	}, acc.GetTelegrafMetrics())
+}
+
+func TestParseError(t *testing.T) {
+	listener := newListener()
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	conn, err := dial(t, listener, "ws", "", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, websocket.Message.Send(conn, "not line protocol"))
+	require.NoError(t, websocket.Message.Send(conn, "cpu value=42 0\n"))
+	acc.Wait(1)
+	require.Len(t, acc.Errors, 1)
+}
+
+func TestMaxMessageSize(t *testing.T) {
+	listener := newListener()
+	listener.MaxMessageSize = internal.Size{Size: 16}
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	conn, err := dial(t, listener, "ws", "", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, websocket.Message.Send(conn, "cpu value=42,other=43 0\n"))
+
+	// the connection is closed
+	var msg []byte
+	require.Error(t, websocket.Message.Receive(conn, &msg))
+	require.Len(t, acc.Errors, 1)
+}
+
+func TestAuthentication(t *testing.T) {
+	listener := newListener()
+	listener.BasicUsername = "user"
+	listener.BasicPassword = "secret"
+	listener.Tokens = []string{"mytoken"}
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	_, err := dial(t, listener, "ws", "", nil)
+	require.Error(t, err)
+
+	_, err = dial(t, listener, "ws", "?token=wrong", nil)
+	require.Error(t, err)
+
+	conn, err := dial(t, listener, "ws", "?token=mytoken", nil)
+	require.NoError(t, err)
+	conn.Close()
+
+	conn, err = dial(t, listener, "ws", "", http.Header{"Authorization": {"Bearer mytoken"}})
+	require.NoError(t, err)
+	conn.Close()
+
+	req, err := http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+	req.SetBasicAuth("user", "secret")
+	conn, err = dial(t, listener, "ws", "", req.Header)
+	require.NoError(t, err)
+	conn.Close()
+}
+
+func TestAllowedOrigins(t *testing.T) {
+	listener := newListener()
+	listener.AllowedOrigins = []string{"https://dashboard.example.com"}
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	_, err := dial(t, listener, "ws", "", nil)
+	require.Error(t, err)
+}
+
+func TestCrossOrigin(t *testing.T) {
+	listener := newListener()
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	// without allowed origins only the origin of the listener is allowed
+	_, err := dialOrigin(t, listener, "ws", "", nil, "https://evil.example.com/")
+	require.Error(t, err)
+
+	conn, err := dial(t, listener, "ws", "", nil)
+	require.NoError(t, err)
+	conn.Close()
+}
+
+func TestMaxConnections(t *testing.T) {
+	listener := newListener()
+	listener.MaxConnections = 1
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	conn, err := dial(t, listener, "ws", "", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// the second connection is closed after the handshake
+	conn2, err := dial(t, listener, "ws", "", nil)
+	require.NoError(t, err)
+	var msg []byte
+	require.Error(t, websocket.Message.Receive(conn2, &msg))
+}
+
+func TestTLS(t *testing.T) {
+	listener := newListener()
+	listener.ServerConfig = *pki.TLSServerConfig()
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	conn, err := dial(t, listener, "wss", "", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, websocket.Message.Send(conn, "cpu value=42 0\n"))
+	acc.Wait(1)
+}
@@ -3,9 +3,11 @@ package elasticsearch
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"text/template"
@@ -32,6 +34,10 @@ type Elasticsearch struct {
 	TemplateName        string
 	OverwriteTemplate   bool
 	MajorReleaseNumber  int
+	DataStream          bool
+	ForceDocumentID     bool `toml:"force_document_id"`
+	PipelineTag         string
+	DefaultPipeline     string
 	tls.ClientConfig
 
 	Client *elastic.Client
@@ -71,6 +77,21 @@ var sampleConfig = `
   # default_tag_value = "none"
   index_name = "telegraf-%Y.%m.%d" # required.
 
+  ## Write to a data stream named by index_name; documents are only created,
+  ## never updated.  The index_name must not contain date patterns, the data
+  ## stream rolls over its backing indices.  Requires Elasticsearch 7.9 or
+  ## later.
+  # data_stream = false
+
+  ## Set the document _id from the series and the timestamp of the metric, so
+  ## that metrics written again after a failure do not create duplicates.
+  # force_document_id = false
+
+  ## Ingest pipeline for each metric, from the value of the tag named by
+  ## pipeline_tag, or default_pipeline when the tag is missing.
+  # pipeline_tag = ""
+  # default_pipeline = ""
+
   ## Optional TLS Config
   # tls_ca = "/etc/telegraf/ca.pem"
   # tls_cert = "/etc/telegraf/cert.pem"
@@ -168,6 +189,10 @@ func (a *Elasticsearch) Connect() error {
 		return fmt.Errorf("Elasticsearch urls or index_name is not defined")
 	}
 
+	if a.DataStream && hasDatePattern(a.IndexName) {
+		return fmt.Errorf("Elasticsearch index_name must not contain date patterns with data_stream, the data stream manages the backing indices")
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), a.Timeout.Duration)
 	defer cancel()
 
@@ -227,6 +252,10 @@ func (a *Elasticsearch) Connect() error {
 
 	log.Println("I! Elasticsearch version: " + esVersion)
 
+	if a.DataStream && !versionAtLeast(esVersion, 7, 9) {
+		return fmt.Errorf("Elasticsearch data streams are not supported by version %s", esVersion)
+	}
+
 	a.Client = client
 	a.MajorReleaseNumber = majorReleaseNumber
 
@@ -269,6 +298,18 @@ func (a *Elasticsearch) Write(metrics []telegraf.Metric) error {
 			br.Type("metrics")
 		}
 
+		if a.DataStream {
+			br.OpType("create")
+		}
+
+		if a.ForceDocumentID {
+			br.Id(documentID(metric))
+		}
+
+		if pipeline := a.getPipeline(metric); pipeline != "" {
+			br.Pipeline(pipeline)
+		}
+
 		bulkRequest.Add(br)
 
 	}
@@ -283,22 +324,77 @@ func (a *Elasticsearch) Write(metrics []telegraf.Metric) error {
 	}
 
 	if res.Errors {
-		for id, err := range res.Failed() {
-			log.Printf("E! Elasticsearch indexing failure, id: %d, error: %s, caused by: %s, %s", id, err.Error.Reason, err.Error.CausedBy["reason"], err.Error.CausedBy["type"])
-		}
-		return fmt.Errorf("W! Elasticsearch failed to index %d metrics", len(res.Failed()))
+		return a.bulkError(res)
 	}
 
 	return nil
 
 }
 
+// documentID returns an _id identifying the series and timestamp of the
+// metric.
+func documentID(metric telegraf.Metric) string {
+	return fmt.Sprintf("%016x%016x", metric.HashID(), uint64(metric.Time().UnixNano()))
+}
+
+func (a *Elasticsearch) getPipeline(metric telegraf.Metric) string {
+	if a.PipelineTag != "" {
+		if pipeline, ok := metric.GetTag(a.PipelineTag); ok {
+			return pipeline
+		}
+	}
+	return a.DefaultPipeline
+}
+
+// bulkError returns the error for the failed items of a bulk request.  Items
+// rejected because Elasticsearch is overloaded or failing are retried; any
+// other failure, such as a mapping conflict, would fail again and is dropped.
+// The items of the response are in the order of the metrics.
+func (a *Elasticsearch) bulkError(res *elastic.BulkResponse) error {
+	perr := &internal.PartialWriteError{}
+	for i, item := range res.Items {
+		for _, result := range item {
+			if result.Error == nil {
+				continue
+			}
+
+			// the document was created by an earlier write
+			if result.Status == http.StatusConflict && a.DataStream {
+				continue
+			}
+
+			log.Printf("E! Elasticsearch indexing failure, id: %d, status: %d, error: %s, caused by: %s, %s",
+				i, result.Status, result.Error.Reason, result.Error.CausedBy["reason"], result.Error.CausedBy["type"])
+
+			if result.Status == http.StatusTooManyRequests || result.Status >= 500 {
+				perr.Retry = append(perr.Retry, i)
+			} else {
+				perr.Drop = append(perr.Drop, i)
+			}
+		}
+	}
+
+	if len(perr.Retry) == 0 && len(perr.Drop) == 0 {
+		return nil
+	}
+
+	perr.Err = fmt.Errorf("W! Elasticsearch failed to index %d metrics, %d will be retried",
+		len(perr.Retry)+len(perr.Drop), len(perr.Retry))
+	return perr
+}
+
 func (a *Elasticsearch) manageTemplate(ctx context.Context) error {
 	if a.TemplateName == "" {
 		return fmt.Errorf("Elasticsearch template_name configuration not defined")
 	}
 
-	templateExists, errExists := a.Client.IndexTemplateExists(a.TemplateName).Do(ctx)
+	var templateExists bool
+	var errExists error
+	if a.DataStream {
+		templateExists, errExists = a.indexTemplateExists(ctx)
+	} else {
+		templateExists, errExists = a.Client.IndexTemplateExists(a.TemplateName).Do(ctx)
+	}
 
 	if errExists != nil {
 		return fmt.Errorf("Elasticsearch template check failed, template name: %s, error: %s", a.TemplateName, errExists)
@@ -328,7 +424,13 @@ func (a *Elasticsearch) manageTemplate(ctx context.Context) error {
 		var tmpl bytes.Buffer
 
 		t.Execute(&tmpl, tp)
-		_, errCreateTemplate := a.Client.IndexPutTemplate(a.TemplateName).BodyString(tmpl.String()).Do(ctx)
+
+		var errCreateTemplate error
+		if a.DataStream {
+			errCreateTemplate = a.putIndexTemplate(ctx, tmpl.Bytes())
+		} else {
+			_, errCreateTemplate = a.Client.IndexPutTemplate(a.TemplateName).BodyString(tmpl.String()).Do(ctx)
+		}
 
 		if errCreateTemplate != nil {
 			return fmt.Errorf("Elasticsearch failed to create index template %s : %s", a.TemplateName, errCreateTemplate)
@@ -344,6 +446,36 @@ func (a *Elasticsearch) manageTemplate(ctx context.Context) error {
 	return nil
 }
 
+// indexTemplateExists checks for the composable index template used by data
+// streams, which the legacy template API does not return.
+func (a *Elasticsearch) indexTemplateExists(ctx context.Context) (bool, error) {
+	res, err := a.Client.PerformRequest(ctx, "HEAD", "/_index_template/"+url.PathEscape(a.TemplateName), nil, nil, http.StatusNotFound)
+	if err != nil {
+		return false, err
+	}
+	return res.StatusCode == http.StatusOK, nil
+}
+
+// putIndexTemplate creates a composable index template for the data stream
+// with the settings and mappings of the telegraf template.
+func (a *Elasticsearch) putIndexTemplate(ctx context.Context, telegrafTemplate []byte) error {
+	var tmpl map[string]interface{}
+	if err := json.Unmarshal(telegrafTemplate, &tmpl); err != nil {
+		return err
+	}
+
+	body := map[string]interface{}{
+		"index_patterns": tmpl["index_patterns"],
+		"data_stream":    map[string]interface{}{},
+		"template": map[string]interface{}{
+			"settings": tmpl["settings"],
+			"mappings": tmpl["mappings"],
+		},
+	}
+	_, err := a.Client.PerformRequest(ctx, "PUT", "/_index_template/"+url.PathEscape(a.TemplateName), nil, body)
+	return err
+}
+
 func (a *Elasticsearch) GetTagKeys(indexName string) (string, []string) {
 
 	tagKeys := []string{}
@@ -401,6 +533,31 @@ func (a *Elasticsearch) GetIndexName(indexName string, eventTime time.Time, tagK
 
 }
 
+// hasDatePattern returns true if the index name contains one of the date
+// patterns replaced by GetIndexName.
+func hasDatePattern(indexName string) bool {
+	for _, pattern := range []string{"%Y", "%y", "%m", "%d", "%H", "%V"} {
+		if strings.Contains(indexName, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// versionAtLeast returns true if the version is major.minor or later.
+func versionAtLeast(version string, major, minor int) bool {
+	parts := strings.SplitN(version, ".", 3)
+	v, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return false
+	}
+	if v != major || len(parts) < 2 {
+		return v > major
+	}
+	v, err = strconv.Atoi(parts[1])
+	return err == nil && v >= minor
+}
+
 func getISOWeek(eventTime time.Time) string {
 	_, week := eventTime.ISOWeek()
 	return strconv.Itoa(week)
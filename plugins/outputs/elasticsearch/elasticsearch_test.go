@@ -1,14 +1,20 @@
 package elasticsearch
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"testing"
 	"time"
 
+	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal"
 	"github.com/influxdata/telegraf/testutil"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/olivere/elastic.v5"
 )
 
 func TestConnectAndWrite(t *testing.T) {
@@ -257,3 +263,162 @@ func TestGetIndexName(t *testing.T) {
 		}
 	}
 }
+
+// newBulkServer returns a server answering bulk requests with the given item
+// statuses and recording the actions of the request.
+func newBulkServer(t *testing.T, statuses []int, actions *[]map[string]map[string]interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/_bulk", r.URL.Path)
+
+		// action and document lines alternate
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var action map[string]map[string]interface{}
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &action))
+			*actions = append(*actions, action)
+			scanner.Scan()
+		}
+
+		items := []map[string]interface{}{}
+		errors := false
+		for _, status := range statuses {
+			item := map[string]interface{}{"status": status}
+			if status >= 300 {
+				errors = true
+				item["error"] = map[string]interface{}{"type": "error", "reason": "failed"}
+			}
+			items = append(items, map[string]interface{}{"index": item})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"errors": errors, "items": items})
+	}))
+}
+
+func newTestElasticsearch(t *testing.T, url string) *Elasticsearch {
+	client, err := elastic.NewClient(
+		elastic.SetURL(url),
+		elastic.SetSniff(false),
+		elastic.SetHealthcheck(false),
+	)
+	require.NoError(t, err)
+
+	return &Elasticsearch{
+		IndexName:          "telegraf",
+		Timeout:            internal.Duration{Duration: time.Second * 5},
+		MajorReleaseNumber: 7,
+		Client:             client,
+	}
+}
+
+func testMetrics() []telegraf.Metric {
+	return []telegraf.Metric{
+		testutil.MustMetric("cpu", map[string]string{"host": "a", "pipeline": "cpu"},
+			map[string]interface{}{"value": 42.0}, time.Unix(0, 1)),
+		testutil.MustMetric("cpu", map[string]string{"host": "b"},
+			map[string]interface{}{"value": 42.0}, time.Unix(0, 2)),
+		testutil.MustMetric("cpu", map[string]string{"host": "c"},
+			map[string]interface{}{"value": 42.0}, time.Unix(0, 3)),
+	}
+}
+
+func TestWriteOptions(t *testing.T) {
+	var actions []map[string]map[string]interface{}
+	ts := newBulkServer(t, []int{201, 201, 201}, &actions)
+	defer ts.Close()
+
+	e := newTestElasticsearch(t, ts.URL)
+	e.DataStream = true
+	e.ForceDocumentID = true
+	e.PipelineTag = "pipeline"
+	e.DefaultPipeline = "default"
+
+	metrics := testMetrics()
+	require.NoError(t, e.Write(metrics))
+	require.Len(t, actions, 3)
+
+	require.Equal(t, map[string]interface{}{
+		"_index":   "telegraf",
+		"_id":      documentID(metrics[0]),
+		"pipeline": "cpu",
+	}, actions[0]["create"])
+	require.Equal(t, "default", actions[1]["create"]["pipeline"])
+
+	// the same series and timestamp always have the same id
+	require.Equal(t, documentID(metrics[0]), documentID(testMetrics()[0]))
+	require.NotEqual(t, documentID(metrics[0]), documentID(metrics[1]))
+}
+
+func TestWritePartialFailure(t *testing.T) {
+	var actions []map[string]map[string]interface{}
+	ts := newBulkServer(t, []int{201, 429, 400}, &actions)
+	defer ts.Close()
+
+	e := newTestElasticsearch(t, ts.URL)
+	err := e.Write(testMetrics())
+	require.Error(t, err)
+
+	perr, ok := err.(*internal.PartialWriteError)
+	require.True(t, ok)
+	require.Equal(t, []int{1}, perr.Retry)
+	require.Equal(t, []int{2}, perr.Drop)
+	require.NotNil(t, actions[0]["index"])
+}
+
+func TestWriteDataStreamConflict(t *testing.T) {
+	var actions []map[string]map[string]interface{}
+	ts := newBulkServer(t, []int{201, 409, 201}, &actions)
+	defer ts.Close()
+
+	e := newTestElasticsearch(t, ts.URL)
+	e.DataStream = true
+	require.NoError(t, e.Write(testMetrics()))
+}
+
+func TestManageDataStreamTemplate(t *testing.T) {
+	var body map[string]interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/_index_template/telegraf", r.URL.Path)
+		switch r.Method {
+		case "HEAD":
+			w.WriteHeader(http.StatusNotFound)
+		case "PUT":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"acknowledged": true}`))
+		}
+	}))
+	defer ts.Close()
+
+	e := newTestElasticsearch(t, ts.URL)
+	e.IndexName = "metrics-telegraf"
+	e.DataStream = true
+	e.TemplateName = "telegraf"
+	require.NoError(t, e.manageTemplate(context.Background()))
+
+	require.Equal(t, []interface{}{"metrics-telegraf*"}, body["index_patterns"])
+	require.Equal(t, map[string]interface{}{}, body["data_stream"])
+	tmpl := body["template"].(map[string]interface{})
+	require.Contains(t, tmpl, "settings")
+	require.Contains(t, tmpl["mappings"], "properties")
+}
+
+func TestDataStreamDateIndex(t *testing.T) {
+	e := &Elasticsearch{
+		URLs:       []string{"http://localhost:9200"},
+		IndexName:  "telegraf-%Y.%m.%d",
+		DataStream: true,
+	}
+	err := e.Connect()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "date patterns")
+}
+
+func TestVersionAtLeast(t *testing.T) {
+	require.True(t, versionAtLeast("7.9.0", 7, 9))
+	require.True(t, versionAtLeast("7.10.2", 7, 9))
+	require.True(t, versionAtLeast("8.0.0", 7, 9))
+	require.False(t, versionAtLeast("7.8.1", 7, 9))
+	require.False(t, versionAtLeast("6.8.0", 7, 9))
+	require.False(t, versionAtLeast("7", 7, 9))
+	require.False(t, versionAtLeast("x.y", 7, 9))
+}
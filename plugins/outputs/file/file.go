@@ -1,10 +1,16 @@
 package file
 
 import (
+	"bytes"
+	"container/list"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal"
@@ -18,10 +24,37 @@ type File struct {
 	RotationInterval    internal.Duration `toml:"rotation_interval"`
 	RotationMaxSize     internal.Size     `toml:"rotation_max_size"`
 	RotationMaxArchives int               `toml:"rotation_max_archives"`
+	RotationCompression string            `toml:"rotation_compression"`
+	MaxOpenFiles        int               `toml:"max_open_files"`
+	Fsync               string            `toml:"fsync"`
+	FsyncInterval       internal.Duration `toml:"fsync_interval"`
 
 	writer     io.Writer
 	closers    []io.Closer
+	syncers    []syncer
 	serializer serializers.Serializer
+
+	templates []*template.Template
+	// open templated files, the least recently used at the back
+	open     *list.List
+	openFile map[string]*list.Element
+	lastSync time.Time
+}
+
+// openedFile is a file opened for a templated path.
+type openedFile struct {
+	path   string
+	writer io.WriteCloser
+}
+
+type syncer interface {
+	Sync() error
+}
+
+// releaser is implemented by rotating file writers, which close the file
+// without rotating it.
+type releaser interface {
+	Release() error
 }
 
 var sampleConfig = `
@@ -40,6 +73,24 @@ var sampleConfig = `
   ## If set to -1, no archives are removed.
   # rotation_max_archives = 5
 
+  ## Compression of the rotated archives, "none", "gzip" or "zstd".
+  # rotation_compression = "none"
+
+  ## Files may be Go templates of the path, with {{.Name}}, {{.Tag "key"}}
+  ## and strftime style %Y, %y, %m, %d, %H, %M and %S from the metric time
+  ## in UTC.  Directories are created as needed.
+  ##   ex: files = ['/data/{{.Name}}/{{.Tag "host"}}/%Y%m%d.lp']
+
+  ## Maximum number of templated files kept open, the least recently used
+  ## file is closed when exceeded.  When set to 0 no limit is applied.
+  # max_open_files = 100
+
+  ## When to commit the written data to disk, "none" leaves it to the
+  ## operating system, "write" syncs after every write and "interval" at most
+  ## once per fsync_interval.
+  # fsync = "none"
+  # fsync_interval = "10s"
+
   ## Data format to output.
   ## Each data format has its own unique set of configuration options, read
   ## more about them here:
@@ -58,32 +109,70 @@ func (f *File) Connect() error {
 		f.Files = []string{"stdout"}
 	}
 
+	switch f.Fsync {
+	case "", "none", "write":
+	case "interval":
+		if f.FsyncInterval.Duration <= 0 {
+			f.FsyncInterval.Duration = defaultFsyncInterval
+		}
+	default:
+		return fmt.Errorf("invalid fsync %q", f.Fsync)
+	}
+
+	f.open = list.New()
+	f.openFile = make(map[string]*list.Element)
+	f.lastSync = time.Now()
+
 	for _, file := range f.Files {
 		if file == "stdout" {
 			writers = append(writers, os.Stdout)
+		} else if isTemplate(file) {
+			tmpl, err := template.New(file).Parse(convertStrftime(file))
+			if err != nil {
+				return fmt.Errorf("invalid file template %q: %v", file, err)
+			}
+			f.templates = append(f.templates, tmpl)
 		} else {
-			of, err := rotate.NewFileWriter(
-				file, f.RotationInterval.Duration, f.RotationMaxSize.Size, f.RotationMaxArchives)
+			of, err := f.openWriter(file)
 			if err != nil {
 				return err
 			}
 
 			writers = append(writers, of)
 			f.closers = append(f.closers, of)
+			if s, ok := of.(syncer); ok {
+				f.syncers = append(f.syncers, s)
+			}
 		}
 	}
 	f.writer = io.MultiWriter(writers...)
 	return nil
 }
 
+func (f *File) openWriter(file string) (io.WriteCloser, error) {
+	return rotate.NewCompressingFileWriter(file, f.RotationInterval.Duration,
+		f.RotationMaxSize.Size, f.RotationMaxArchives, f.RotationCompression)
+}
+
 func (f *File) Close() error {
 	var err error
+	if f.Fsync == "interval" {
+		err = f.sync()
+	}
 	for _, c := range f.closers {
 		errClose := c.Close()
 		if errClose != nil {
 			err = errClose
 		}
 	}
+	for e := f.open.Front(); e != nil; e = e.Next() {
+		errClose := e.Value.(*openedFile).writer.Close()
+		if errClose != nil {
+			err = errClose
+		}
+	}
+	f.open.Init()
+	f.openFile = make(map[string]*list.Element)
 	return err
 }
 
@@ -108,13 +197,192 @@ func (f *File) Write(metrics []telegraf.Metric) error {
 		if err != nil {
 			writeErr = fmt.Errorf("E! [outputs.file] failed to write message: %v", err)
 		}
+
+		for _, tmpl := range f.templates {
+			if err := f.writeTemplated(tmpl, metric, b); err != nil {
+				writeErr = fmt.Errorf("E! [outputs.file] failed to write message: %v", err)
+			}
+		}
+	}
+
+	switch f.Fsync {
+	case "write":
+		if err := f.sync(); err != nil {
+			writeErr = fmt.Errorf("E! [outputs.file] failed to sync files: %v", err)
+		}
+	case "interval":
+		if time.Since(f.lastSync) >= f.FsyncInterval.Duration {
+			if err := f.sync(); err != nil {
+				writeErr = fmt.Errorf("E! [outputs.file] failed to sync files: %v", err)
+			}
+		}
 	}
 
 	return writeErr
 }
 
+func (f *File) writeTemplated(tmpl *template.Template, metric telegraf.Metric, b []byte) error {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, &pathData{metric}); err != nil {
+		return err
+	}
+
+	w, err := f.get(buf.String())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// get returns the writer of the templated file, opening it when not open
+// and closing the least recently used file when more than max_open_files
+// are open.
+func (f *File) get(path string) (io.Writer, error) {
+	if e, ok := f.openFile[path]; ok {
+		f.open.MoveToFront(e)
+		return e.Value.(*openedFile).writer, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	w, err := f.openWriter(path)
+	if err != nil {
+		return nil, err
+	}
+	f.openFile[path] = f.open.PushFront(&openedFile{path: path, writer: w})
+
+	for f.MaxOpenFiles > 0 && f.open.Len() > f.MaxOpenFiles {
+		e := f.open.Back()
+		of := e.Value.(*openedFile)
+		f.open.Remove(e)
+		delete(f.openFile, of.path)
+
+		// Syncing before closing, as only open files are synced.
+		if s, ok := of.writer.(syncer); ok && f.Fsync != "" && f.Fsync != "none" {
+			s.Sync()
+		}
+		if r, ok := of.writer.(releaser); ok {
+			err = r.Release()
+		} else {
+			err = of.writer.Close()
+		}
+		if err != nil {
+			log.Printf("W! [outputs.file] Closing %s failed: %v", of.path, err)
+		}
+	}
+	return w, nil
+}
+
+// sync commits the open files to disk, stdout is not synced.
+func (f *File) sync() error {
+	f.lastSync = time.Now()
+
+	var err error
+	for _, s := range f.syncers {
+		if errSync := s.Sync(); errSync != nil {
+			err = errSync
+		}
+	}
+	for e := f.open.Front(); e != nil; e = e.Next() {
+		if s, ok := e.Value.(*openedFile).writer.(syncer); ok {
+			if errSync := s.Sync(); errSync != nil {
+				err = errSync
+			}
+		}
+	}
+	return err
+}
+
+// pathData is the data of the file templates.
+type pathData struct {
+	metric telegraf.Metric
+}
+
+func (d *pathData) Name() string {
+	return sanitize(d.metric.Name())
+}
+
+func (d *pathData) Tag(key string) string {
+	value, _ := d.metric.GetTag(key)
+	return sanitize(value)
+}
+
+func (d *pathData) Time(layout string) string {
+	return d.metric.Time().UTC().Format(layout)
+}
+
+// sanitize makes the value safe as a path element, so that metrics cannot
+// write outside of the templated directory.
+func sanitize(value string) string {
+	value = strings.Replace(value, "/", "_", -1)
+	value = strings.Replace(value, string(filepath.Separator), "_", -1)
+	if value == "." || value == ".." {
+		return "_"
+	}
+	return value
+}
+
+// isTemplate reports whether the file contains a template action or one of
+// the strftime directives; other uses of "%" are part of the file name.
+func isTemplate(file string) bool {
+	if strings.Contains(file, "{{") {
+		return true
+	}
+	for i := 0; i+1 < len(file); i++ {
+		if file[i] != '%' {
+			continue
+		}
+		i++
+		if _, ok := strftime[file[i]]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+var strftime = map[byte]string{
+	'Y': "2006",
+	'y': "06",
+	'm': "01",
+	'd': "02",
+	'H': "15",
+	'M': "04",
+	'S': "05",
+}
+
+// convertStrftime replaces the strftime directives with the formatted metric
+// time; "%%" is a literal "%".
+func convertStrftime(file string) string {
+	var buf strings.Builder
+	for i := 0; i < len(file); i++ {
+		if file[i] != '%' || i+1 == len(file) {
+			buf.WriteByte(file[i])
+			continue
+		}
+		i++
+		if layout, ok := strftime[file[i]]; ok {
+			fmt.Fprintf(&buf, "{{.Time %q}}", layout)
+		} else if file[i] == '%' {
+			buf.WriteByte('%')
+		} else {
+			buf.WriteByte('%')
+			buf.WriteByte(file[i])
+		}
+	}
+	return buf.String()
+}
+
+const (
+	defaultFsyncInterval = 10 * time.Second
+	defaultMaxOpenFiles  = 100
+)
+
 func init() {
 	outputs.Add("file", func() telegraf.Output {
-		return &File{}
+		return &File{
+			MaxOpenFiles: defaultMaxOpenFiles,
+		}
 	})
 }
@@ -5,10 +5,14 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal"
 	"github.com/influxdata/telegraf/plugins/serializers"
 	"github.com/influxdata/telegraf/testutil"
@@ -180,6 +184,97 @@ func TestFileStdout(t *testing.T) {
 	assert.Equal(t, expNewFile, out)
 }
 
+func TestFileTemplate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s, _ := serializers.NewInfluxSerializer()
+	f := File{
+		Files:      []string{filepath.Join(dir, `{{.Name}}/{{.Tag "host"}}/%Y%m%d%%.lp`)},
+		Fsync:      "write",
+		serializer: s,
+	}
+	require.NoError(t, f.Connect())
+
+	ts := time.Date(2019, 6, 14, 23, 30, 0, 0, time.UTC)
+	require.NoError(t, f.Write([]telegraf.Metric{
+		testutil.MustMetric("cpu", map[string]string{"host": "a"},
+			map[string]interface{}{"value": 1}, ts),
+		testutil.MustMetric("cpu", map[string]string{"host": "b"},
+			map[string]interface{}{"value": 2}, ts.Add(time.Hour)),
+		testutil.MustMetric("cpu", map[string]string{"host": "../x"},
+			map[string]interface{}{"value": 3}, ts),
+		testutil.MustMetric("cpu", map[string]string{"host": ".."},
+			map[string]interface{}{"value": 4}, ts),
+	}))
+	require.NoError(t, f.Close())
+
+	validateFile(filepath.Join(dir, "cpu/a/20190614%.lp"), "cpu,host=a value=1i 1560555000000000000\n", t)
+	validateFile(filepath.Join(dir, "cpu/b/20190615%.lp"), "cpu,host=b value=2i 1560558600000000000\n", t)
+	validateFile(filepath.Join(dir, "cpu/.._x/20190614%.lp"), "cpu,host=../x value=3i 1560555000000000000\n", t)
+	validateFile(filepath.Join(dir, "cpu/_/20190614%.lp"), "cpu,host=.. value=4i 1560555000000000000\n", t)
+}
+
+func TestFileMaxOpenFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s, _ := serializers.NewInfluxSerializer()
+	f := File{
+		Files:               []string{filepath.Join(dir, `{{.Tag "host"}}.lp`)},
+		MaxOpenFiles:        2,
+		RotationInterval:    internal.Duration{Duration: time.Hour},
+		RotationMaxArchives: -1,
+		Fsync:               "interval",
+		serializer:          s,
+	}
+	require.NoError(t, f.Connect())
+
+	for _, host := range []string{"a", "b", "a", "c", "b"} {
+		require.NoError(t, f.Write([]telegraf.Metric{
+			testutil.MustMetric("cpu", map[string]string{"host": host},
+				map[string]interface{}{"value": 1}, time.Unix(0, 0)),
+		}))
+		require.True(t, f.open.Len() <= 2)
+	}
+	require.Equal(t, "b", filepath.Base(f.open.Front().Value.(*openedFile).path)[:1])
+	require.NoError(t, f.Close())
+
+	// the evicted file is closed without rotating, the open files are
+	// rotated on close
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 3)
+	require.Equal(t, "a.lp", files[0].Name())
+	require.Regexp(t, "^b\\.[^\\.]+\\.lp$", files[1].Name())
+	require.Regexp(t, "^c\\.[^\\.]+\\.lp$", files[2].Name())
+	validateFile(filepath.Join(dir, "a.lp"), "cpu,host=a value=1i 0\ncpu,host=a value=1i 0\n", t)
+}
+
+func TestFileInvalidConfig(t *testing.T) {
+	f := File{Files: []string{"{{.Name"}}
+	require.Error(t, f.Connect())
+
+	f = File{Files: []string{"stdout"}, Fsync: "always"}
+	require.Error(t, f.Connect())
+}
+
+func TestIsTemplate(t *testing.T) {
+	require.True(t, isTemplate(`/data/{{.Name}}.lp`))
+	require.True(t, isTemplate("/data/%Y%m%d.lp"))
+	require.True(t, isTemplate("/data/100%%/%d.lp"))
+	require.False(t, isTemplate("/data/100%.lp"))
+	require.False(t, isTemplate("/data/%20metrics%.lp"))
+	require.False(t, isTemplate("/data/100%%.lp"))
+}
+
+func TestConvertStrftime(t *testing.T) {
+	require.Equal(t, `/data/{{.Time "2006"}}-{{.Time "01"}}-{{.Time "02"}}T{{.Time "15"}}%q%`,
+		convertStrftime("/data/%Y-%m-%dT%H%q%"))
+}
+
 func createFile() *os.File {
 	f, err := ioutil.TempFile("", "")
 	if err != nil {
@@ -1,15 +1,20 @@
 package graphite
 
 import (
+	"bytes"
 	"crypto/tls"
 	"errors"
+	"fmt"
 	"io"
 	"log"
 	"math/rand"
 	"net"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
 	tlsint "github.com/influxdata/telegraf/internal/tls"
 	"github.com/influxdata/telegraf/plugins/outputs"
 	"github.com/influxdata/telegraf/plugins/serializers"
@@ -18,18 +23,40 @@ import (
 type Graphite struct {
 	GraphiteTagSupport bool
 	// URL is only for backwards compatibility
-	Servers  []string
-	Prefix   string
-	Template string
-	Timeout  int
-	conns    []net.Conn
+	Servers           []string
+	Prefix            string
+	Template          string
+	Timeout           internal.Duration
+	Mode              string
+	Protocol          string
+	ReconnectInterval internal.Duration
 	tlsint.ClientConfig
+
+	tlsConfig *tls.Config
+	servers   []*server
+	ring      ring
+	next      int
+}
+
+// server is a graphite server, conn is nil when it is not connected.
+type server struct {
+	address     string
+	conn        net.Conn
+	lastConnect time.Time
+}
+
+// point is a data point of the plaintext protocol.
+type point struct {
+	path      string
+	value     float64
+	timestamp int64
+	line      []byte
 }
 
 var sampleConfig = `
   ## TCP endpoint for your graphite instance.
-  ## If multiple endpoints are configured, output will be load balanced.
-  ## Only one of the endpoints will be written to with each iteration.
+  ## If multiple endpoints are configured, output will be load balanced
+  ## according to the mode.
   servers = ["localhost:2003"]
   ## Prefix metrics name
   prefix = ""
@@ -40,8 +67,22 @@ var sampleConfig = `
   ## Enable Graphite tags support
   # graphite_tag_support = false
 
-  ## timeout in seconds for the write connection to graphite
-  timeout = 2
+  ## How the servers are chosen:
+  ##   random          - each write goes to a random server
+  ##   round_robin     - each write goes to the next server
+  ##   consistent_hash - each series always goes to the same server, by name
+  ## Writes fail over to the other servers.
+  # mode = "random"
+
+  ## Protocol of the servers, "plaintext" or "pickle".  Carbon listens for
+  ## the pickle protocol on port 2004 by default.
+  # protocol = "plaintext"
+
+  ## Interval of connecting again to the servers that failed.
+  # reconnect_interval = "1m"
+
+  ## Timeout for connecting and writing to graphite.
+  timeout = "2s"
 
   ## Optional TLS Config
   # tls_ca = "/etc/telegraf/ca.pem"
@@ -51,47 +92,94 @@ var sampleConfig = `
   # insecure_skip_verify = false
 `
 
+const (
+	defaultTimeout           = 2 * time.Second
+	defaultReconnectInterval = time.Minute
+)
+
 func (g *Graphite) Connect() error {
 	// Set default values
-	if g.Timeout <= 0 {
-		g.Timeout = 2
+	if g.Timeout.Duration <= 0 {
+		g.Timeout.Duration = defaultTimeout
+	}
+	if g.ReconnectInterval.Duration <= 0 {
+		g.ReconnectInterval.Duration = defaultReconnectInterval
 	}
 	if len(g.Servers) == 0 {
 		g.Servers = append(g.Servers, "localhost:2003")
 	}
 
+	switch g.Mode {
+	case "":
+		g.Mode = "random"
+	case "random", "round_robin", "consistent_hash":
+	default:
+		return fmt.Errorf("invalid mode %q", g.Mode)
+	}
+
+	switch g.Protocol {
+	case "":
+		g.Protocol = "plaintext"
+	case "plaintext", "pickle":
+	default:
+		return fmt.Errorf("invalid protocol %q", g.Protocol)
+	}
+
 	// Set tls config
 	tlsConfig, err := g.ClientConfig.TLSConfig()
 	if err != nil {
 		return err
 	}
+	g.tlsConfig = tlsConfig
+
+	if g.servers == nil {
+		for _, address := range g.Servers {
+			g.servers = append(g.servers, &server{address: address})
+		}
+		g.ring = newRing(g.Servers)
+	}
 
 	// Get Connections
-	var conns []net.Conn
-	for _, server := range g.Servers {
+	g.reconnect(true)
+	return nil
+}
+
+// reconnect connects to the servers that are not connected, when
+// reconnect_interval has passed since the last attempt or when forced.
+func (g *Graphite) reconnect(force bool) {
+	for _, srv := range g.servers {
+		if srv.conn != nil || (!force && time.Since(srv.lastConnect) < g.ReconnectInterval.Duration) {
+			continue
+		}
+		srv.lastConnect = time.Now()
+
 		// Dialer with timeout
-		d := net.Dialer{Timeout: time.Duration(g.Timeout) * time.Second}
+		d := net.Dialer{Timeout: g.Timeout.Duration}
 
 		// Get secure connection if tls config is set
 		var conn net.Conn
-		if tlsConfig != nil {
-			conn, err = tls.DialWithDialer(&d, "tcp", server, tlsConfig)
+		var err error
+		if g.tlsConfig != nil {
+			conn, err = tls.DialWithDialer(&d, "tcp", srv.address, g.tlsConfig)
 		} else {
-			conn, err = d.Dial("tcp", server)
+			conn, err = d.Dial("tcp", srv.address)
 		}
 
-		if err == nil {
-			conns = append(conns, conn)
+		if err != nil {
+			log.Printf("D! [outputs.graphite] Connecting to %s failed: %v", srv.address, err)
+			continue
 		}
+		srv.conn = conn
 	}
-	g.conns = conns
-	return nil
 }
 
 func (g *Graphite) Close() error {
 	// Closing all connections
-	for _, conn := range g.conns {
-		conn.Close()
+	for _, srv := range g.servers {
+		if srv.conn != nil {
+			srv.conn.Close()
+			srv.conn = nil
+		}
 	}
 	return nil
 }
@@ -109,14 +197,15 @@ func (g *Graphite) Description() string {
 // We can detect that by finding an eof
 // if not for this, we can happily write and flush without getting errors (in Go) but getting RST tcp packets back (!)
 // props to Tv via the authors of carbon-relay-ng` for this trick.
-func checkEOF(conn net.Conn) {
+// checkEOF returns false when it closed the connection.
+func checkEOF(conn net.Conn) bool {
 	b := make([]byte, 1024)
 	conn.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
 	num, err := conn.Read(b)
 	if err == io.EOF {
 		log.Printf("E! Conn %s is closed. closing conn explicitly", conn)
 		conn.Close()
-		return
+		return false
 	}
 	// just in case i misunderstand something or the remote behaves badly
 	if num != 0 {
@@ -126,68 +215,165 @@ func checkEOF(conn net.Conn) {
 	if e, ok := err.(net.Error); !(ok && e.Timeout()) {
 		log.Printf("E! conn %s checkEOF .conn.Read returned err != EOF, which is unexpected.  closing conn. error: %s\n", conn, err)
 		conn.Close()
+		return false
 	}
+	return true
 }
 
-// Choose a random server in the cluster to write to until a successful write
-// occurs, logging each unsuccessful. If all servers fail, return error.
+// Write the metrics to the servers chosen by the mode until a successful
+// write occurs, logging each unsuccessful. If all servers fail, return error.
 func (g *Graphite) Write(metrics []telegraf.Metric) error {
 	// Prepare data
-	var batch []byte
 	s, err := serializers.NewGraphiteSerializer(g.Prefix, g.Template, g.GraphiteTagSupport)
 	if err != nil {
 		return err
 	}
 
+	var points []point
 	for _, metric := range metrics {
 		buf, err := s.Serialize(metric)
 		if err != nil {
 			log.Printf("E! Error serializing some metrics to graphite: %s", err.Error())
 		}
-		batch = append(batch, buf...)
+		points = append(points, parsePoints(buf)...)
 	}
 
-	err = g.send(batch)
+	g.reconnect(false)
+	points, err = g.send(points)
 
 	// try to reconnect and retry to send
 	if err != nil {
 		log.Println("E! Graphite: Reconnecting and retrying: ")
-		g.Connect()
-		err = g.send(batch)
+		g.reconnect(true)
+		_, err = g.send(points)
 	}
 
 	return err
 }
 
-func (g *Graphite) send(batch []byte) error {
+// parsePoints splits the serialized lines into points.
+func parsePoints(buf []byte) []point {
+	var points []point
+	for len(buf) > 0 {
+		var line []byte
+		if i := bytes.IndexByte(buf, '\n'); i >= 0 {
+			line = buf[:i+1]
+			buf = buf[i+1:]
+		} else {
+			line = append(append([]byte(nil), buf...), '\n')
+			buf = nil
+		}
+
+		parts := strings.Fields(string(line))
+		if len(parts) != 3 {
+			continue
+		}
+		value, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			continue
+		}
+		timestamp, err := strconv.ParseInt(parts[2], 10, 64)
+		if err != nil {
+			continue
+		}
+		points = append(points, point{path: parts[0], value: value, timestamp: timestamp, line: line})
+	}
+	return points
+}
+
+// send writes the points and returns the points that could not be written.
+func (g *Graphite) send(points []point) ([]point, error) {
 	// This will get set to nil if a successful write occurs
 	err := errors.New("Could not write to any Graphite server in cluster\n")
 
-	// Send data to a random server
-	p := rand.Perm(len(g.conns))
-	for _, n := range p {
-		if g.Timeout > 0 {
-			g.conns[n].SetWriteDeadline(time.Now().Add(time.Duration(g.Timeout) * time.Second))
-		}
-		checkEOF(g.conns[n])
-		if _, e := g.conns[n].Write(batch); e != nil {
-			// Error
-			log.Println("E! Graphite Error: " + e.Error())
-			// Close explicitly
-			g.conns[n].Close()
-			// Let's try the next one
-		} else {
+	if g.Mode == "consistent_hash" {
+		for len(points) > 0 {
+			groups := make(map[*server][]point)
+			for _, p := range points {
+				srv := g.pick(p.path)
+				if srv == nil {
+					return points, err
+				}
+				groups[srv] = append(groups[srv], p)
+			}
+
+			// the points of the failed servers go to the next servers of the
+			// ring.
+			var failed []point
+			for srv, group := range groups {
+				if !g.write(srv, group) {
+					failed = append(failed, group...)
+				}
+			}
+			points = failed
+		}
+		return nil, nil
+	}
+
+	var order []int
+	if g.Mode == "round_robin" {
+		for i := range g.servers {
+			order = append(order, (g.next+i)%len(g.servers))
+		}
+		g.next = (g.next + 1) % len(g.servers)
+	} else {
+		// Send data to a random server
+		order = rand.Perm(len(g.servers))
+	}
+
+	for _, n := range order {
+		if g.servers[n].conn != nil && g.write(g.servers[n], points) {
 			// Success
-			err = nil
-			break
+			return nil, nil
 		}
+		// Let's try the next one
 	}
+	return points, err
+}
 
-	return err
+// write writes the points to the server, closing the connection on errors.
+func (g *Graphite) write(srv *server, points []point) bool {
+	var batch []byte
+	if g.Protocol == "pickle" {
+		batch = encodePickle(points)
+	} else {
+		for _, p := range points {
+			batch = append(batch, p.line...)
+		}
+	}
+
+	if !checkEOF(srv.conn) {
+		srv.conn = nil
+		return false
+	}
+	srv.conn.SetWriteDeadline(time.Now().Add(g.Timeout.Duration))
+	if _, e := srv.conn.Write(batch); e != nil {
+		// Error
+		log.Println("E! Graphite Error: " + e.Error())
+		// Close explicitly
+		srv.conn.Close()
+		srv.conn = nil
+		return false
+	}
+	return true
+}
+
+// pick returns the first connected server of the ring for the path, or nil
+// when no server is connected.
+func (g *Graphite) pick(path string) *server {
+	for _, i := range g.ring.lookup(path) {
+		if g.servers[i].conn != nil {
+			return g.servers[i]
+		}
+	}
+	return nil
 }
 
 func init() {
 	outputs.Add("graphite", func() telegraf.Output {
-		return &Graphite{}
+		return &Graphite{
+			Timeout:           internal.Duration{Duration: defaultTimeout},
+			ReconnectInterval: internal.Duration{Duration: defaultReconnectInterval},
+		}
 	})
 }
@@ -2,13 +2,18 @@ package graphite
 
 import (
 	"bufio"
+	"encoding/binary"
+	"io"
 	"net"
 	"net/textproto"
+	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
 	"github.com/influxdata/telegraf/metric"
 
 	"github.com/stretchr/testify/assert"
@@ -217,3 +222,209 @@ func TCPServer2WithTags(t *testing.T, wg *sync.WaitGroup) {
 		tcpServer.Close()
 	}()
 }
+
+// testServer accepts connections and records the received data per connection.
+type testServer struct {
+	listener net.Listener
+	data     chan []byte
+}
+
+func newTestServer(t *testing.T) *testServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &testServer{listener: l, data: make(chan []byte, 100)}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				buf := make([]byte, 4096)
+				for {
+					n, err := conn.Read(buf)
+					if err != nil {
+						return
+					}
+					s.data <- append([]byte(nil), buf[:n]...)
+				}
+			}()
+		}
+	}()
+	return s
+}
+
+func (s *testServer) addr() string {
+	return s.listener.Addr().String()
+}
+
+// lines returns the lines received within the timeout.
+func (s *testServer) lines(timeout time.Duration) []string {
+	var lines []string
+	var buf []byte
+	deadline := time.After(timeout)
+	for {
+		select {
+		case data := <-s.data:
+			buf = append(buf, data...)
+			for {
+				i := strings.IndexByte(string(buf), '\n')
+				if i < 0 {
+					break
+				}
+				lines = append(lines, string(buf[:i]))
+				buf = buf[i+1:]
+			}
+		case <-deadline:
+			return lines
+		}
+	}
+}
+
+func testMetric(host string) telegraf.Metric {
+	m, _ := metric.New(
+		"cpu",
+		map[string]string{"host": host},
+		map[string]interface{}{"usage": float64(1)},
+		time.Date(2010, time.November, 10, 23, 0, 0, 0, time.UTC),
+	)
+	return m
+}
+
+func TestGraphiteRoundRobin(t *testing.T) {
+	s1 := newTestServer(t)
+	defer s1.listener.Close()
+	s2 := newTestServer(t)
+	defer s2.listener.Close()
+
+	g := Graphite{Servers: []string{s1.addr(), s2.addr()}, Mode: "round_robin"}
+	require.NoError(t, g.Connect())
+	defer g.Close()
+
+	for _, host := range []string{"a", "b", "c"} {
+		require.NoError(t, g.Write([]telegraf.Metric{testMetric(host)}))
+	}
+	require.Equal(t, []string{"a.cpu.usage 1 1289430000", "c.cpu.usage 1 1289430000"}, s1.lines(100*time.Millisecond))
+	require.Equal(t, []string{"b.cpu.usage 1 1289430000"}, s2.lines(100*time.Millisecond))
+}
+
+func TestGraphiteConsistentHash(t *testing.T) {
+	s1 := newTestServer(t)
+	defer s1.listener.Close()
+	s2 := newTestServer(t)
+	defer s2.listener.Close()
+
+	g := Graphite{Servers: []string{s1.addr(), s2.addr()}, Mode: "consistent_hash"}
+	require.NoError(t, g.Connect())
+	defer g.Close()
+
+	var metrics []telegraf.Metric
+	for i := 0; i < 20; i++ {
+		metrics = append(metrics, testMetric(strconv.Itoa(i)))
+	}
+	require.NoError(t, g.Write(metrics))
+	require.NoError(t, g.Write(metrics))
+
+	// each series goes to the same server both times
+	lines1 := s1.lines(100 * time.Millisecond)
+	lines2 := s2.lines(100 * time.Millisecond)
+	require.Len(t, append(lines1, lines2...), 40)
+	require.NotEmpty(t, lines1)
+	require.NotEmpty(t, lines2)
+	for _, line := range lines1 {
+		require.NotContains(t, lines2, line)
+		require.Equal(t, 0, g.ring.lookup(strings.Fields(line)[0])[0])
+	}
+
+	// the series of a failed server go to the other server
+	g.servers[0].conn.Close()
+	require.NoError(t, g.Write(metrics))
+	require.Len(t, s2.lines(100*time.Millisecond), 20)
+}
+
+func TestRingLookup(t *testing.T) {
+	r := newRing([]string{"a:2003", "b:2003", "c:2003"})
+	servers := r.lookup("cpu.usage_idle")
+	require.Len(t, servers, 3)
+	require.ElementsMatch(t, []int{0, 1, 2}, servers)
+	require.Equal(t, servers, r.lookup("cpu.usage_idle"))
+	require.Nil(t, ring(nil).lookup("cpu.usage_idle"))
+}
+
+func TestParsePoints(t *testing.T) {
+	points := parsePoints([]byte("cpu.usage_idle 98 1560540094\ninvalid\nmem.free 1 1560540094"))
+	require.Len(t, points, 2)
+	require.Equal(t, "cpu.usage_idle", points[0].path)
+	require.Equal(t, "mem.free 1 1560540094\n", string(points[1].line))
+}
+
+func TestGraphiteReconnectInterval(t *testing.T) {
+	s1 := newTestServer(t)
+	defer s1.listener.Close()
+
+	// reserve an address for the server that is down on connect
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	l.Close()
+
+	g := Graphite{
+		Servers:           []string{addr, s1.addr()},
+		Mode:              "round_robin",
+		ReconnectInterval: internal.Duration{Duration: 50 * time.Millisecond},
+	}
+	require.NoError(t, g.Connect())
+	defer g.Close()
+	require.Nil(t, g.servers[0].conn)
+
+	l, err = net.Listen("tcp", addr)
+	require.NoError(t, err)
+	s2 := &testServer{listener: l}
+	defer l.Close()
+
+	// written to the other server before the reconnect interval
+	require.NoError(t, g.Write([]telegraf.Metric{testMetric("a")}))
+	require.Nil(t, g.servers[0].conn)
+
+	time.Sleep(100 * time.Millisecond)
+	require.NoError(t, g.Write([]telegraf.Metric{testMetric("b")}))
+	require.NotNil(t, g.servers[0].conn)
+	require.Equal(t, []string{"a.cpu.usage 1 1289430000", "b.cpu.usage 1 1289430000"}, s1.lines(100*time.Millisecond))
+
+	conn, err := s2.listener.Accept()
+	require.NoError(t, err)
+	conn.Close()
+}
+
+func TestGraphitePickle(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	g := Graphite{Servers: []string{l.Addr().String()}, Protocol: "pickle"}
+	require.NoError(t, g.Connect())
+	defer g.Close()
+
+	conn, err := l.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, g.Write([]telegraf.Metric{testMetric("a")}))
+
+	var length uint32
+	require.NoError(t, binary.Read(conn, binary.BigEndian, &length))
+	msg := make([]byte, length)
+	_, err = io.ReadFull(conn, msg)
+	require.NoError(t, err)
+	// [("a.cpu.usage", (1289430000, 1.0))]
+	require.Equal(t, "\x80\x02](X\x0b\x00\x00\x00a.cpu.usageJ\xf0#\xdbLG?\xf0\x00\x00\x00\x00\x00\x00\x86\x86e.", string(msg))
+}
+
+func TestGraphiteInvalidConfig(t *testing.T) {
+	g := Graphite{Mode: "hash"}
+	require.Error(t, g.Connect())
+
+	g = Graphite{Protocol: "json"}
+	require.Error(t, g.Connect())
+}
@@ -0,0 +1,68 @@
+package graphite
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+)
+
+// Opcodes of the pickle protocol 2 used by carbon.
+const (
+	opProto      = 0x80
+	opEmptyList  = ']'
+	opMark       = '('
+	opAppends    = 'e'
+	opBinUnicode = 'X'
+	opBinInt     = 'J'
+	opBinFloat   = 'G'
+	opTuple2     = 0x86
+	opStop       = '.'
+)
+
+// maxPickleBatch is the maximum number of points of a pickle message.
+const maxPickleBatch = 500
+
+// encodePickle encodes the points as pickle messages, each a list of
+// (path, (timestamp, value)) tuples prefixed with its length.
+func encodePickle(points []point) []byte {
+	var buf bytes.Buffer
+	for len(points) > 0 {
+		n := len(points)
+		if n > maxPickleBatch {
+			n = maxPickleBatch
+		}
+		writePickle(&buf, points[:n])
+		points = points[n:]
+	}
+	return buf.Bytes()
+}
+
+func writePickle(buf *bytes.Buffer, points []point) {
+	start := buf.Len()
+	// length header, set below
+	buf.Write([]byte{0, 0, 0, 0})
+
+	buf.Write([]byte{opProto, 2, opEmptyList, opMark})
+	for _, p := range points {
+		buf.WriteByte(opBinUnicode)
+		binary.Write(buf, binary.LittleEndian, uint32(len(p.path)))
+		buf.WriteString(p.path)
+
+		if p.timestamp >= math.MinInt32 && p.timestamp <= math.MaxInt32 {
+			buf.WriteByte(opBinInt)
+			binary.Write(buf, binary.LittleEndian, int32(p.timestamp))
+		} else {
+			buf.WriteByte(opBinFloat)
+			binary.Write(buf, binary.BigEndian, float64(p.timestamp))
+		}
+
+		buf.WriteByte(opBinFloat)
+		binary.Write(buf, binary.BigEndian, p.value)
+
+		buf.WriteByte(opTuple2)
+		buf.WriteByte(opTuple2)
+	}
+	buf.Write([]byte{opAppends, opStop})
+
+	binary.BigEndian.PutUint32(buf.Bytes()[start:], uint32(buf.Len()-start-4))
+}
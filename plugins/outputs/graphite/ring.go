@@ -0,0 +1,60 @@
+package graphite
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+)
+
+// replicas is the number of points of each server on the ring, spreading
+// the series evenly.
+const replicas = 100
+
+type ringEntry struct {
+	hash   uint32
+	server int
+}
+
+// ring is a consistent hash ring of the servers, sorted by hash.
+type ring []ringEntry
+
+func newRing(servers []string) ring {
+	var r ring
+	for i, server := range servers {
+		for n := 0; n < replicas; n++ {
+			r = append(r, ringEntry{hash: hash(server + "-" + strconv.Itoa(n)), server: i})
+		}
+	}
+	sort.Slice(r, func(i, j int) bool { return r[i].hash < r[j].hash })
+	return r
+}
+
+// lookup returns the indexes of the servers in the order of the ring
+// starting from the series, the first server is the one owning the series.
+func (r ring) lookup(series string) []int {
+	if len(r) == 0 {
+		return nil
+	}
+
+	h := hash(series)
+	start := sort.Search(len(r), func(i int) bool { return r[i].hash >= h })
+
+	// every server has the same number of replicas
+	n := len(r) / replicas
+	servers := make([]int, 0, n)
+	seen := make([]bool, n)
+	for i := 0; i < len(r) && len(servers) < n; i++ {
+		e := r[(start+i)%len(r)]
+		if !seen[e.server] {
+			seen[e.server] = true
+			servers = append(servers, e.server)
+		}
+	}
+	return servers
+}
+
+func hash(s string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(s))
+	return h.Sum32()
+}
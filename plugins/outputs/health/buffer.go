@@ -0,0 +1,66 @@
+package health
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/filter"
+	"github.com/influxdata/telegraf/selfstat"
+)
+
+const defaultMaxFill = 0.5
+
+// Buffer fails when the metric buffer of one of the outputs is filled above
+// max_fill, as a fraction of its limit.
+type Buffer struct {
+	Outputs []string `toml:"outputs"`
+	MaxFill float64  `toml:"max_fill"`
+	CheckOptions
+
+	filter filter.Filter
+}
+
+func (b *Buffer) start(now time.Time) error {
+	if b.MaxFill <= 0 {
+		b.MaxFill = defaultMaxFill
+	}
+
+	var err error
+	b.filter, err = filter.Compile(b.Outputs)
+	return err
+}
+
+func (b *Buffer) observe(metrics []telegraf.Metric, now time.Time) {
+}
+
+func (b *Buffer) status(now time.Time) (bool, string) {
+	var output string
+	var fill float64
+	for _, stats := range selfstat.Stats("write") {
+		size, ok := stats["buffer_size"]
+		if !ok {
+			continue
+		}
+		limit, ok := stats["buffer_limit"]
+		if !ok || limit.Get() <= 0 {
+			continue
+		}
+
+		name := size.Tags()["output"]
+		if b.filter != nil && !b.filter.Match(name) {
+			continue
+		}
+
+		f := float64(size.Get()) / float64(limit.Get())
+		if output == "" || f > fill {
+			output = name
+			fill = f
+		}
+	}
+
+	if output == "" {
+		return true, "no output buffers"
+	}
+	return fill <= b.MaxFill, fmt.Sprintf("buffer of %s %.0f%% full", output, fill*100)
+}
@@ -0,0 +1,34 @@
+package health
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/selfstat"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer(t *testing.T) {
+	tags := map[string]string{"output": "health_test"}
+	size := selfstat.Register("write", "buffer_size", tags)
+	selfstat.Register("write", "buffer_limit", tags).Set(100)
+	defer size.Set(0)
+
+	b := &Buffer{Outputs: []string{"health_test"}}
+	require.NoError(t, b.start(time.Now()))
+
+	size.Set(50)
+	healthy, message := b.status(time.Now())
+	require.True(t, healthy)
+	require.Equal(t, "buffer of health_test 50% full", message)
+
+	size.Set(51)
+	healthy, _ = b.status(time.Now())
+	require.False(t, healthy)
+
+	b = &Buffer{Outputs: []string{"missing"}, MaxFill: 0.9}
+	require.NoError(t, b.start(time.Now()))
+	healthy, message = b.status(time.Now())
+	require.True(t, healthy)
+	require.Equal(t, "no output buffers", message)
+}
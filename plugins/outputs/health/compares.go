@@ -12,6 +12,7 @@ type Compares struct {
 	LE    *float64 `toml:"le"`
 	EQ    *float64 `toml:"eq"`
 	NE    *float64 `toml:"ne"`
+	CheckOptions
 }
 
 func (c *Compares) runChecks(fv float64) bool {
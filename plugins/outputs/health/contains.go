@@ -4,6 +4,7 @@ import "github.com/influxdata/telegraf"
 
 type Contains struct {
 	Field string `toml:"field"`
+	CheckOptions
 }
 
 func (c *Contains) Check(metrics []telegraf.Metric) bool {
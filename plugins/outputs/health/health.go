@@ -3,10 +3,13 @@ package health
 import (
 	"context"
 	"crypto/tls"
+	"encoding/json"
+	"fmt"
 	"log"
 	"net"
 	"net/http"
 	"net/url"
+	"strings"
 	"sync"
 	"time"
 
@@ -59,6 +62,34 @@ var sampleConfig = `
   ##
   ## [[outputs.health.contains]]
   ##   field = "buffer_size"
+  ##
+  ## Every check has an optional name, listed in the JSON status, and group.
+  ## The checks of a group are served at the path of the group, for example
+  ## "/liveness"; other paths serve all checks.
+  ##
+  ## Fails when no metric of the measurement, with the field when set, was
+  ## written within max_age.
+  ## [[outputs.health.staleness]]
+  ##   name = "cpu_stale"
+  ##   group = "liveness"
+  ##   measurement = ["cpu"]
+  ##   field = "usage_idle"
+  ##   max_age = "1m"
+  ##
+  ## Fails when less than min metrics per second of the measurement, with
+  ## the field when set, were written over the window.
+  ## [[outputs.health.rate]]
+  ##   group = "readiness"
+  ##   measurement = ["cpu"]
+  ##   min = 1.0
+  ##   window = "1m"
+  ##
+  ## Fails when the metric buffer of one of the outputs is filled above
+  ## max_fill, a fraction of its limit.
+  ## [[outputs.health.buffer]]
+  ##   group = "readiness"
+  ##   outputs = ["influxdb"]
+  ##   max_fill = 0.5
 `
 
 type Checker interface {
@@ -66,6 +97,62 @@ type Checker interface {
 	Check(metrics []telegraf.Metric) bool
 }
 
+// CheckOptions are the options of every check.
+type CheckOptions struct {
+	Name  string `toml:"name"`
+	Group string `toml:"group"`
+}
+
+func (o *CheckOptions) options() *CheckOptions {
+	return o
+}
+
+// check is evaluated when requested, over the metrics written since
+// startup.
+type check interface {
+	options() *CheckOptions
+	start(now time.Time) error
+	// observe records the metrics of a write.
+	observe(metrics []telegraf.Metric, now time.Time)
+	// status returns true if the check passes and a description of its
+	// state.
+	status(now time.Time) (bool, string)
+}
+
+// batchCheck passes if the Checker passed on the last batch.
+type batchCheck struct {
+	Checker
+	*CheckOptions
+	healthy bool
+}
+
+func (c *batchCheck) start(now time.Time) error {
+	c.healthy = true
+	return nil
+}
+
+func (c *batchCheck) observe(metrics []telegraf.Metric, now time.Time) {
+	c.healthy = c.Check(metrics)
+}
+
+func (c *batchCheck) status(now time.Time) (bool, string) {
+	return c.healthy, ""
+}
+
+// Status is the JSON body of the response.
+type Status struct {
+	Healthy bool          `json:"healthy"`
+	Checks  []CheckStatus `json:"checks"`
+}
+
+// CheckStatus is the result of a check.
+type CheckStatus struct {
+	Name    string `json:"name"`
+	Group   string `json:"group,omitempty"`
+	Healthy bool   `json:"healthy"`
+	Message string `json:"message,omitempty"`
+}
+
 type Health struct {
 	ServiceAddress string            `toml:"service_address"`
 	ReadTimeout    internal.Duration `toml:"read_timeout"`
@@ -74,16 +161,20 @@ type Health struct {
 	BasicPassword  string            `toml:"basic_password"`
 	tlsint.ServerConfig
 
-	Compares []*Compares `toml:"compares"`
-	Contains []*Contains `toml:"contains"`
-	checkers []Checker
+	Compares  []*Compares  `toml:"compares"`
+	Contains  []*Contains  `toml:"contains"`
+	Staleness []*Staleness `toml:"staleness"`
+	Rate      []*Rate      `toml:"rate"`
+	Buffer    []*Buffer    `toml:"buffer"`
 
 	wg     sync.WaitGroup
 	server *http.Server
 	origin string
 
-	mu      sync.Mutex
-	healthy bool
+	mu     sync.Mutex
+	checks []check
+	groups map[string]bool
+	now    func() time.Time
 }
 
 func (h *Health) SampleConfig() string {
@@ -96,12 +187,48 @@ func (h *Health) Description() string {
 
 // Connect starts the HTTP server.
 func (h *Health) Connect() error {
-	h.checkers = make([]Checker, 0)
-	for i := range h.Compares {
-		h.checkers = append(h.checkers, h.Compares[i])
+	if h.now == nil {
+		h.now = time.Now
+	}
+
+	h.checks = make([]check, 0)
+	h.groups = make(map[string]bool)
+	add := func(kind string, i int, c check) error {
+		opts := c.options()
+		if opts.Name == "" {
+			opts.Name = fmt.Sprintf("%s.%d", kind, i)
+		}
+		if opts.Group != "" {
+			h.groups[opts.Group] = true
+		}
+		h.checks = append(h.checks, c)
+		return c.start(h.now())
+	}
+
+	for i, c := range h.Compares {
+		if err := add("compares", i, &batchCheck{Checker: c, CheckOptions: &c.CheckOptions}); err != nil {
+			return err
+		}
 	}
-	for i := range h.Contains {
-		h.checkers = append(h.checkers, h.Contains[i])
+	for i, c := range h.Contains {
+		if err := add("contains", i, &batchCheck{Checker: c, CheckOptions: &c.CheckOptions}); err != nil {
+			return err
+		}
+	}
+	for i, c := range h.Staleness {
+		if err := add("staleness", i, c); err != nil {
+			return err
+		}
+	}
+	for i, c := range h.Rate {
+		if err := add("rate", i, c); err != nil {
+			return err
+		}
+	}
+	for i, c := range h.Buffer {
+		if err := add("buffer", i, c); err != nil {
+			return err
+		}
 	}
 
 	tlsConf, err := h.ServerConfig.TLSConfig()
@@ -167,26 +294,62 @@ func (h *Health) listen(tlsConf *tls.Config) (net.Listener, error) {
 }
 
 func (h *Health) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	// The path of a group serves its checks, other paths all checks.
+	group := strings.Trim(req.URL.Path, "/")
+	if !h.groups[group] {
+		group = ""
+	}
+	status := h.status(group)
+
 	var code = http.StatusOK
-	if !h.isHealthy() {
+	if !status.Healthy {
 		code = http.StatusServiceUnavailable
 	}
 
 	rw.Header().Set("Server", internal.ProductToken())
-	http.Error(rw, http.StatusText(code), code)
+	rw.Header().Set("Content-Type", "application/json; charset=utf-8")
+	rw.Header().Set("X-Content-Type-Options", "nosniff")
+	rw.WriteHeader(code)
+	json.NewEncoder(rw).Encode(status)
 }
 
-// Write runs all checks over the metric batch and adjust health state.
-func (h *Health) Write(metrics []telegraf.Metric) error {
-	healthy := true
-	for _, checker := range h.checkers {
-		success := checker.Check(metrics)
-		if !success {
-			healthy = false
+// status evaluates the checks of the group, or all checks when group is
+// empty.
+func (h *Health) status(group string) *Status {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := h.now()
+	status := &Status{Healthy: true, Checks: []CheckStatus{}}
+	for _, c := range h.checks {
+		opts := c.options()
+		if group != "" && opts.Group != group {
+			continue
+		}
+
+		healthy, message := c.status(now)
+		if !healthy {
+			status.Healthy = false
 		}
+		status.Checks = append(status.Checks, CheckStatus{
+			Name:    opts.Name,
+			Group:   opts.Group,
+			Healthy: healthy,
+			Message: message,
+		})
 	}
+	return status
+}
+
+// Write runs all checks over the metric batch and adjust health state.
+func (h *Health) Write(metrics []telegraf.Metric) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
 
-	h.setHealthy(healthy)
+	now := h.now()
+	for _, c := range h.checks {
+		c.observe(metrics, now)
+	}
 	return nil
 }
 
@@ -224,24 +387,11 @@ func (h *Health) getOrigin(listener net.Listener, tlsConf *tls.Config) string {
 	}
 }
 
-func (h *Health) setHealthy(healthy bool) {
-	h.mu.Lock()
-	defer h.mu.Unlock()
-	h.healthy = healthy
-}
-
-func (h *Health) isHealthy() bool {
-	h.mu.Lock()
-	defer h.mu.Unlock()
-	return h.healthy
-}
-
 func NewHealth() *Health {
 	return &Health{
 		ServiceAddress: defaultServiceAddress,
 		ReadTimeout:    internal.Duration{Duration: defaultReadTimeout},
 		WriteTimeout:   internal.Duration{Duration: defaultWriteTimeout},
-		healthy:        true,
 	}
 }
 
@@ -1,12 +1,14 @@
 package health_test
 
 import (
+	"encoding/json"
 	"io/ioutil"
 	"net/http"
 	"testing"
 	"time"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
 	"github.com/influxdata/telegraf/plugins/outputs/health"
 	"github.com/influxdata/telegraf/testutil"
 	"github.com/stretchr/testify/require"
@@ -122,3 +124,59 @@ func TestHealth(t *testing.T) {
 		})
 	}
 }
+
+func TestHealthGroups(t *testing.T) {
+	output := health.NewHealth()
+	output.ServiceAddress = "tcp://127.0.0.1:0"
+	output.Contains = []*health.Contains{
+		{
+			Field:        "foo",
+			CheckOptions: health.CheckOptions{Group: "readiness"},
+		},
+	}
+	output.Staleness = []*health.Staleness{
+		{
+			Measurement:  []string{"cpu"},
+			MaxAge:       internal.Duration{Duration: time.Hour},
+			CheckOptions: health.CheckOptions{Name: "cpu_stale", Group: "liveness"},
+		},
+	}
+	require.NoError(t, output.Connect())
+	defer output.Close()
+
+	require.NoError(t, output.Write([]telegraf.Metric{
+		testutil.MustMetric("cpu", map[string]string{},
+			map[string]interface{}{"time_idle": 42}, time.Now()),
+	}))
+
+	get := func(path string) (int, health.Status) {
+		resp, err := http.Get(output.Origin() + path)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, "application/json; charset=utf-8", resp.Header.Get("Content-Type"))
+
+		var status health.Status
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&status))
+		return resp.StatusCode, status
+	}
+
+	code, status := get("/liveness")
+	require.Equal(t, 200, code)
+	require.True(t, status.Healthy)
+	require.Len(t, status.Checks, 1)
+	require.Equal(t, "cpu_stale", status.Checks[0].Name)
+	require.Equal(t, "liveness", status.Checks[0].Group)
+	require.True(t, status.Checks[0].Healthy)
+	require.Contains(t, status.Checks[0].Message, "last metric")
+
+	code, status = get("/readiness")
+	require.Equal(t, 503, code)
+	require.Equal(t, []health.CheckStatus{
+		{Name: "contains.0", Group: "readiness", Healthy: false},
+	}, status.Checks)
+
+	code, status = get("/")
+	require.Equal(t, 503, code)
+	require.False(t, status.Healthy)
+	require.Len(t, status.Checks, 2)
+}
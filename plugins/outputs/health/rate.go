@@ -0,0 +1,86 @@
+package health
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/filter"
+	"github.com/influxdata/telegraf/internal"
+)
+
+const defaultRateWindow = time.Minute
+
+// Rate fails when fewer than min metrics per second matching the measurement
+// and field were written over the window.
+type Rate struct {
+	Measurement []string          `toml:"measurement"`
+	Field       string            `toml:"field"`
+	Min         float64           `toml:"min"`
+	Window      internal.Duration `toml:"window"`
+	CheckOptions
+
+	filter  filter.Filter
+	started time.Time
+	// number of matching metrics of each write within the window, oldest
+	// first.
+	writes []rateWrite
+}
+
+type rateWrite struct {
+	time  time.Time
+	count int
+}
+
+func (r *Rate) start(now time.Time) error {
+	if r.Window.Duration <= 0 {
+		r.Window.Duration = defaultRateWindow
+	}
+
+	var err error
+	r.filter, err = filter.Compile(r.Measurement)
+	if err != nil {
+		return err
+	}
+
+	r.started = now
+	return nil
+}
+
+func (r *Rate) observe(metrics []telegraf.Metric, now time.Time) {
+	count := 0
+	for _, m := range metrics {
+		if matches(m, r.filter, r.Field) {
+			count++
+		}
+	}
+	if count > 0 {
+		r.writes = append(r.writes, rateWrite{time: now, count: count})
+	}
+	r.expire(now)
+}
+
+func (r *Rate) expire(now time.Time) {
+	i := 0
+	for i < len(r.writes) && now.Sub(r.writes[i].time) > r.Window.Duration {
+		i++
+	}
+	r.writes = r.writes[i:]
+}
+
+func (r *Rate) status(now time.Time) (bool, string) {
+	r.expire(now)
+
+	count := 0
+	for _, w := range r.writes {
+		count += w.count
+	}
+	rate := float64(count) / r.Window.Duration.Seconds()
+	message := fmt.Sprintf("%.2f metrics/s", rate)
+
+	// The rate is not known before a full window has passed after startup.
+	if now.Sub(r.started) < r.Window.Duration {
+		return true, message
+	}
+	return rate >= r.Min, message
+}
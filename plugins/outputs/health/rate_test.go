@@ -0,0 +1,47 @@
+package health
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRate(t *testing.T) {
+	now := time.Unix(1000, 0)
+	r := &Rate{
+		Measurement: []string{"cpu"},
+		Min:         1.0,
+		Window:      internal.Duration{Duration: 10 * time.Second},
+	}
+	require.NoError(t, r.start(now))
+
+	metrics := func(n int) []telegraf.Metric {
+		var metrics []telegraf.Metric
+		for i := 0; i < n; i++ {
+			metrics = append(metrics, testutil.MustMetric("cpu", map[string]string{},
+				map[string]interface{}{"usage_idle": 1.0}, now))
+		}
+		return append(metrics, testutil.MustMetric("mem", map[string]string{},
+			map[string]interface{}{"used": 1.0}, now))
+	}
+
+	// passes before a full window has passed
+	healthy, message := r.status(now.Add(5 * time.Second))
+	require.True(t, healthy)
+	require.Equal(t, "0.00 metrics/s", message)
+
+	r.observe(metrics(5), now.Add(5*time.Second))
+	r.observe(metrics(6), now.Add(10*time.Second))
+	healthy, message = r.status(now.Add(10 * time.Second))
+	require.True(t, healthy)
+	require.Equal(t, "1.10 metrics/s", message)
+
+	// the first write is out of the window
+	healthy, message = r.status(now.Add(16 * time.Second))
+	require.False(t, healthy)
+	require.Equal(t, "0.60 metrics/s", message)
+}
@@ -0,0 +1,61 @@
+package health
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/filter"
+	"github.com/influxdata/telegraf/internal"
+)
+
+// Staleness fails when no metric matching the measurement and field was
+// written within max_age.
+type Staleness struct {
+	Measurement []string          `toml:"measurement"`
+	Field       string            `toml:"field"`
+	MaxAge      internal.Duration `toml:"max_age"`
+	CheckOptions
+
+	filter filter.Filter
+	last   time.Time
+}
+
+func (s *Staleness) start(now time.Time) error {
+	if s.MaxAge.Duration <= 0 {
+		return fmt.Errorf("staleness check %q requires max_age", s.Name)
+	}
+
+	var err error
+	s.filter, err = filter.Compile(s.Measurement)
+	if err != nil {
+		return err
+	}
+
+	// The metrics are not stale before max_age has passed after startup.
+	s.last = now
+	return nil
+}
+
+func (s *Staleness) observe(metrics []telegraf.Metric, now time.Time) {
+	for _, m := range metrics {
+		if matches(m, s.filter, s.Field) {
+			s.last = now
+			return
+		}
+	}
+}
+
+func (s *Staleness) status(now time.Time) (bool, string) {
+	age := now.Sub(s.last)
+	return age <= s.MaxAge.Duration, fmt.Sprintf("last metric %s ago", age.Round(time.Millisecond))
+}
+
+// matches returns true if the metric passes the measurement filter, when
+// set, and has the field, when set.
+func matches(m telegraf.Metric, f filter.Filter, field string) bool {
+	if f != nil && !f.Match(m.Name()) {
+		return false
+	}
+	return field == "" || m.HasField(field)
+}
@@ -0,0 +1,51 @@
+package health
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaleness(t *testing.T) {
+	now := time.Unix(1000, 0)
+	s := &Staleness{
+		Measurement: []string{"cpu*"},
+		Field:       "usage_idle",
+		MaxAge:      internal.Duration{Duration: time.Minute},
+	}
+	require.NoError(t, s.start(now))
+
+	// not stale on startup
+	healthy, _ := s.status(now.Add(time.Minute))
+	require.True(t, healthy)
+	healthy, message := s.status(now.Add(2 * time.Minute))
+	require.False(t, healthy)
+	require.Equal(t, "last metric 2m0s ago", message)
+
+	// metrics without the field or of another measurement are ignored
+	s.observe([]telegraf.Metric{
+		testutil.MustMetric("cpu", map[string]string{},
+			map[string]interface{}{"usage_user": 1.0}, now),
+		testutil.MustMetric("mem", map[string]string{},
+			map[string]interface{}{"usage_idle": 1.0}, now),
+	}, now.Add(2*time.Minute))
+	healthy, _ = s.status(now.Add(2 * time.Minute))
+	require.False(t, healthy)
+
+	s.observe([]telegraf.Metric{
+		testutil.MustMetric("cpu0", map[string]string{},
+			map[string]interface{}{"usage_idle": 1.0}, now),
+	}, now.Add(2*time.Minute))
+	healthy, message = s.status(now.Add(2*time.Minute + time.Second))
+	require.True(t, healthy)
+	require.Equal(t, "last metric 1s ago", message)
+}
+
+func TestStalenessRequiresMaxAge(t *testing.T) {
+	s := &Staleness{Measurement: []string{"cpu"}}
+	require.Error(t, s.start(time.Now()))
+}
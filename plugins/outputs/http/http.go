@@ -3,11 +3,15 @@ package http
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"log"
 	"net/http"
+	"strconv"
 	"strings"
+	"text/template"
 	"time"
 
 	"github.com/influxdata/telegraf"
@@ -60,6 +64,17 @@ var sampleConfig = `
   ## compress body or "identity" to apply no encoding.
   # content_encoding = "identity"
 
+  ## Maximum size of a request body before compression; larger batches are
+  ## split and a single metric that is too large is dropped.  Batches are
+  ## also split when the server responds with 413 Payload Too Large.
+  # max_payload_size = "0B"
+
+  ## Send one request per metric instead of one per batch.  The url and the
+  ## header values are then Go templates, with {{.Name}}, {{.Tag "key"}}
+  ## and {{.Tags}} of the metric.
+  ##   ex: url = 'https://api.example.com/devices/{{.Tag "device"}}/metrics'
+  # per_metric_requests = false
+
   ## Additional HTTP headers
   # [outputs.http.headers]
   #   # Should be set manually to "application/json" for json data_format
@@ -84,12 +99,44 @@ type HTTP struct {
 	TokenURL        string            `toml:"token_url"`
 	Scopes          []string          `toml:"scopes"`
 	ContentEncoding string            `toml:"content_encoding"`
+	MaxPayloadSize  internal.Size     `toml:"max_payload_size"`
+	PerMetric       bool              `toml:"per_metric_requests"`
 	tls.ClientConfig
 
 	client     *http.Client
 	serializer serializers.Serializer
+
+	urlTemplate     *template.Template
+	headerTemplates map[string]*template.Template
+
+	// no requests are sent before retryAfter when the server asked to back
+	// off with Retry-After
+	retryAfter time.Time
+	now        func() time.Time
+}
+
+// metricData is the data of the url and header templates.
+type metricData struct {
+	metric telegraf.Metric
+}
+
+func (d *metricData) Name() string {
+	return d.metric.Name()
+}
+
+func (d *metricData) Tag(key string) string {
+	value, _ := d.metric.GetTag(key)
+	return value
+}
+
+func (d *metricData) Tags() map[string]string {
+	return d.metric.Tags()
 }
 
+// errPayloadTooLarge is returned by write when the server rejects the
+// request with 413 Payload Too Large.
+var errPayloadTooLarge = errors.New("payload too large")
+
 func (h *HTTP) SetSerializer(serializer serializers.Serializer) {
 	h.serializer = serializer
 }
@@ -135,6 +182,25 @@ func (h *HTTP) Connect() error {
 		h.Timeout.Duration = defaultClientTimeout
 	}
 
+	if h.now == nil {
+		h.now = time.Now
+	}
+
+	if h.PerMetric {
+		var err error
+		h.urlTemplate, err = template.New("url").Parse(h.URL)
+		if err != nil {
+			return fmt.Errorf("invalid url template: %v", err)
+		}
+		h.headerTemplates = make(map[string]*template.Template, len(h.Headers))
+		for k, v := range h.Headers {
+			h.headerTemplates[k], err = template.New(k).Parse(v)
+			if err != nil {
+				return fmt.Errorf("invalid template of header %s: %v", k, err)
+			}
+		}
+	}
+
 	ctx := context.Background()
 	client, err := h.createClient(ctx)
 	if err != nil {
@@ -159,19 +225,127 @@ func (h *HTTP) SampleConfig() string {
 }
 
 func (h *HTTP) Write(metrics []telegraf.Metric) error {
+	if wait := h.retryAfter.Sub(h.now()); wait > 0 {
+		return fmt.Errorf("when writing to [%s] backing off for %s as requested by Retry-After",
+			h.URL, wait.Round(time.Second))
+	}
+
+	perr := &internal.PartialWriteError{}
+	var err error
+	if h.PerMetric {
+		err = h.writeEach(metrics, perr)
+	} else {
+		err = h.writeBatch(metrics, 0, perr)
+	}
+
+	switch {
+	case len(perr.Retry) == 0 && len(perr.Drop) == 0:
+		return err
+	case len(perr.Retry) == len(metrics):
+		return err
+	case err == nil:
+		err = fmt.Errorf("dropped %d metrics that cannot be sent", len(perr.Drop))
+	}
+	perr.Err = err
+	return perr
+}
+
+// writeBatch sends the metrics in one request, splitting them in half when
+// the payload is too large.  The offset is the index of the first metric in
+// the batch given to Write; metrics that are not written are added to perr.
+func (h *HTTP) writeBatch(metrics []telegraf.Metric, offset int, perr *internal.PartialWriteError) error {
 	reqBody, err := h.serializer.SerializeBatch(metrics)
 	if err != nil {
+		perr.Retry = appendRange(perr.Retry, offset, len(metrics))
 		return err
 	}
 
-	if err := h.write(reqBody); err != nil {
-		return err
+	if h.tooLarge(reqBody) {
+		err = errPayloadTooLarge
+	} else {
+		err = h.write(h.URL, h.Headers, reqBody)
+	}
+
+	if err == errPayloadTooLarge {
+		if len(metrics) == 1 {
+			log.Printf("E! [outputs.http] Dropping metric with payload of %d bytes: too large", len(reqBody))
+			perr.Drop = append(perr.Drop, offset)
+			return nil
+		}
+
+		half := len(metrics) / 2
+		if err := h.writeBatch(metrics[:half], offset, perr); err != nil {
+			perr.Retry = appendRange(perr.Retry, offset+half, len(metrics)-half)
+			return err
+		}
+		return h.writeBatch(metrics[half:], offset+half, perr)
 	}
 
+	if err != nil {
+		perr.Retry = appendRange(perr.Retry, offset, len(metrics))
+	}
+	return err
+}
+
+// writeEach sends one request per metric, with the url and headers rendered
+// from the metric.
+func (h *HTTP) writeEach(metrics []telegraf.Metric, perr *internal.PartialWriteError) error {
+	for i, metric := range metrics {
+		url, headers, err := h.render(metric)
+		if err != nil {
+			log.Printf("E! [outputs.http] Dropping metric %s: %v", metric.Name(), err)
+			perr.Drop = append(perr.Drop, i)
+			continue
+		}
+
+		reqBody, err := h.serializer.Serialize(metric)
+		if err == nil {
+			if h.tooLarge(reqBody) {
+				err = errPayloadTooLarge
+			} else {
+				err = h.write(url, headers, reqBody)
+			}
+		}
+
+		if err == errPayloadTooLarge {
+			log.Printf("E! [outputs.http] Dropping metric with payload of %d bytes: too large", len(reqBody))
+			perr.Drop = append(perr.Drop, i)
+			continue
+		}
+
+		if err != nil {
+			perr.Retry = appendRange(perr.Retry, i, len(metrics)-i)
+			return err
+		}
+	}
 	return nil
 }
 
-func (h *HTTP) write(reqBody []byte) error {
+// render returns the url and headers of a metric.
+func (h *HTTP) render(metric telegraf.Metric) (string, map[string]string, error) {
+	data := &metricData{metric: metric}
+	var buf bytes.Buffer
+	if err := h.urlTemplate.Execute(&buf, data); err != nil {
+		return "", nil, fmt.Errorf("rendering url: %v", err)
+	}
+	url := buf.String()
+
+	headers := make(map[string]string, len(h.headerTemplates))
+	for k, t := range h.headerTemplates {
+		buf.Reset()
+		if err := t.Execute(&buf, data); err != nil {
+			return "", nil, fmt.Errorf("rendering header %s: %v", k, err)
+		}
+		headers[k] = buf.String()
+	}
+	return url, headers, nil
+}
+
+func (h *HTTP) tooLarge(reqBody []byte) bool {
+	return h.MaxPayloadSize.Size > 0 && int64(len(reqBody)) > h.MaxPayloadSize.Size
+}
+
+func (h *HTTP) write(url string, headers map[string]string, reqBody []byte) error {
 	var reqBodyBuffer io.Reader = bytes.NewBuffer(reqBody)
 
 	var err error
@@ -182,7 +356,7 @@ func (h *HTTP) write(reqBody []byte) error {
 		}
 	}
 
-	req, err := http.NewRequest(h.Method, h.URL, reqBodyBuffer)
+	req, err := http.NewRequest(h.Method, url, reqBodyBuffer)
 	if err != nil {
 		return err
 	}
@@ -196,7 +370,7 @@ func (h *HTTP) write(reqBody []byte) error {
 	if h.ContentEncoding == "gzip" {
 		req.Header.Set("Content-Encoding", "gzip")
 	}
-	for k, v := range h.Headers {
+	for k, v := range headers {
 		if strings.ToLower(k) == "host" {
 			req.Host = v
 		}
@@ -210,13 +384,51 @@ func (h *HTTP) write(reqBody []byte) error {
 	defer resp.Body.Close()
 	_, err = ioutil.ReadAll(resp.Body)
 
+	switch resp.StatusCode {
+	case http.StatusRequestEntityTooLarge:
+		return errPayloadTooLarge
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		now := h.now()
+		if wait, ok := parseRetryAfter(resp.Header.Get("Retry-After"), now); ok {
+			h.retryAfter = now.Add(wait)
+			return fmt.Errorf("when writing to [%s] received status code: %d, retrying after %s",
+				url, resp.StatusCode, wait)
+		}
+	}
+
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("when writing to [%s] received status code: %d", h.URL, resp.StatusCode)
+		return fmt.Errorf("when writing to [%s] received status code: %d", url, resp.StatusCode)
 	}
 
 	return nil
 }
 
+// parseRetryAfter returns the time to wait from the value of a Retry-After
+// header, either a number of seconds or a HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if t.Before(now) {
+			return 0, true
+		}
+		return t.Sub(now), true
+	}
+	return 0, false
+}
+
+// appendRange appends the count indexes starting at first.
+func appendRange(indexes []int, first, count int) []int {
+	for i := first; i < first+count; i++ {
+		indexes = append(indexes, i)
+	}
+	return indexes
+}
+
 func init() {
 	outputs.Add("http", func() telegraf.Output {
 		return &HTTP{
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -453,3 +454,204 @@ func TestDefaultUserAgent(t *testing.T) {
 		require.NoError(t, err)
 	})
 }
+
+func getMetrics(names ...string) []telegraf.Metric {
+	metrics := make([]telegraf.Metric, 0, len(names))
+	for _, name := range names {
+		m := getMetric()
+		m.SetName(name)
+		metrics = append(metrics, m)
+	}
+	return metrics
+}
+
+func TestRetryAfter(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer ts.Close()
+
+	now := time.Unix(1000, 0)
+	plugin := &HTTP{URL: ts.URL, now: func() time.Time { return now }}
+	plugin.SetSerializer(influx.NewSerializer())
+	require.NoError(t, plugin.Connect())
+
+	require.Error(t, plugin.Write(getMetrics("cpu")))
+	require.Equal(t, 1, requests)
+
+	// no requests while backing off
+	now = now.Add(time.Minute)
+	require.Error(t, plugin.Write(getMetrics("cpu")))
+	require.Equal(t, 1, requests)
+
+	now = now.Add(time.Minute)
+	require.Error(t, plugin.Write(getMetrics("cpu")))
+	require.Equal(t, 2, requests)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	wait, ok := parseRetryAfter("30", now)
+	require.True(t, ok)
+	require.Equal(t, 30*time.Second, wait)
+
+	wait, ok = parseRetryAfter("Sat, 01 Jun 2019 12:01:00 GMT", now)
+	require.True(t, ok)
+	require.Equal(t, time.Minute, wait)
+
+	_, ok = parseRetryAfter("", now)
+	require.False(t, ok)
+	_, ok = parseRetryAfter("soon", now)
+	require.False(t, ok)
+}
+
+func TestPayloadTooLargeSplit(t *testing.T) {
+	var bodies []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		if strings.Count(string(body), "\n") > 2 || strings.HasPrefix(string(body), "huge") {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		bodies = append(bodies, string(body))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	plugin := &HTTP{URL: ts.URL}
+	plugin.SetSerializer(influx.NewSerializer())
+	require.NoError(t, plugin.Connect())
+
+	require.NoError(t, plugin.Write(getMetrics("a", "b", "c", "d", "e")))
+	require.Equal(t, []string{
+		"a value=42 0\nb value=42 0\n",
+		"c value=42 0\n",
+		"d value=42 0\ne value=42 0\n",
+	}, bodies)
+
+	// a single metric that is too large is dropped
+	bodies = nil
+	err := plugin.Write(getMetrics("a", "huge", "b"))
+	require.Error(t, err)
+	perr, ok := err.(*internal.PartialWriteError)
+	require.True(t, ok)
+	require.Equal(t, []int{1}, perr.Drop)
+	require.Empty(t, perr.Retry)
+	require.Equal(t, []string{"a value=42 0\n", "b value=42 0\n"}, bodies)
+}
+
+func TestMaxPayloadSize(t *testing.T) {
+	var bodies []string
+	fail := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		bodies = append(bodies, string(body))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	plugin := &HTTP{URL: ts.URL, MaxPayloadSize: internal.Size{Size: 30}}
+	plugin.SetSerializer(influx.NewSerializer())
+	require.NoError(t, plugin.Connect())
+
+	require.NoError(t, plugin.Write(getMetrics("a", "b", "c")))
+	require.Equal(t, []string{"a value=42 0\n", "b value=42 0\nc value=42 0\n"}, bodies)
+
+	// metrics not written after a failure are retried
+	bodies = nil
+	fail = true
+	err := plugin.Write(getMetrics("a", "b", "this_name_is_longer_than_thirty_bytes"))
+	require.Error(t, err)
+	_, ok := err.(*internal.PartialWriteError)
+	require.False(t, ok)
+}
+
+func TestPerMetricRequests(t *testing.T) {
+	type request struct {
+		path   string
+		header string
+		body   string
+	}
+	var requests []request
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		requests = append(requests, request{r.URL.Path, r.Header.Get("X-Device"), string(body)})
+		if r.URL.Path == "/devices/d3/cpu" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	plugin := &HTTP{
+		URL:       ts.URL + `/devices/{{.Tag "device"}}/{{.Name}}`,
+		Headers:   map[string]string{"X-Device": `{{.Tag "device"}}`},
+		PerMetric: true,
+	}
+	plugin.SetSerializer(influx.NewSerializer())
+	require.NoError(t, plugin.Connect())
+
+	metrics := getMetrics("cpu", "cpu", "cpu", "cpu")
+	for i, m := range metrics {
+		m.AddTag("device", fmt.Sprintf("d%d", i+1))
+	}
+
+	err := plugin.Write(metrics)
+	require.Error(t, err)
+	perr, ok := err.(*internal.PartialWriteError)
+	require.True(t, ok)
+	require.Equal(t, []int{2, 3}, perr.Retry)
+
+	require.Equal(t, []request{
+		{"/devices/d1/cpu", "d1", "cpu,device=d1 value=42 0\n"},
+		{"/devices/d2/cpu", "d2", "cpu,device=d2 value=42 0\n"},
+		{"/devices/d3/cpu", "d3", "cpu,device=d3 value=42 0\n"},
+	}, requests)
+}
+
+func TestTemplateErrorDropsMetric(t *testing.T) {
+	var paths []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	plugin := &HTTP{
+		URL:       ts.URL + `/devices/{{.Tag "device"}}`,
+		Headers:   map[string]string{"X-Device": `{{if eq (.Tag "device") "d2"}}{{template "missing"}}{{end}}`},
+		PerMetric: true,
+	}
+	plugin.SetSerializer(influx.NewSerializer())
+	require.NoError(t, plugin.Connect())
+
+	metrics := getMetrics("cpu", "cpu", "cpu")
+	for i, m := range metrics {
+		m.AddTag("device", fmt.Sprintf("d%d", i+1))
+	}
+
+	err := plugin.Write(metrics)
+	require.Error(t, err)
+	perr, ok := err.(*internal.PartialWriteError)
+	require.True(t, ok)
+	require.Equal(t, []int{1}, perr.Drop)
+	require.Empty(t, perr.Retry)
+	require.Equal(t, []string{"/devices/d1", "/devices/d3"}, paths)
+}
+
+func TestInvalidURLTemplate(t *testing.T) {
+	plugin := &HTTP{URL: "http://localhost/{{.Name", PerMetric: true}
+	require.Error(t, plugin.Connect())
+}
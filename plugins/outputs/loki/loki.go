@@ -0,0 +1,351 @@
+package loki
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/tls"
+	"github.com/influxdata/telegraf/plugins/outputs"
+)
+
+var sampleConfig = `
+  ## URL of the Loki push API.
+  url = "http://localhost:3100/loki/api/v1/push"
+
+  ## Timeout for HTTP requests.
+  # timeout = "5s"
+
+  ## Tags used as the labels of the log streams, the other tags are part of
+  ## the log line.  Keep the number of distinct label values low.
+  # label_tags = ["host"]
+
+  ## Label holding the measurement name, not added if empty.
+  # measurement_label = "measurement"
+
+  ## Format of the log line made of the remaining tags and the fields,
+  ## "logfmt" or "json".
+  # line_format = "logfmt"
+
+  ## HTTP Basic Auth credentials
+  # username = "username"
+  # password = "pa$$word"
+
+  ## Bearer token sent in the Authorization header
+  # bearer_token = ""
+
+  ## Optional TLS Config
+  # tls_ca = "/etc/telegraf/ca.pem"
+  # tls_cert = "/etc/telegraf/cert.pem"
+  # tls_key = "/etc/telegraf/key.pem"
+  ## Use TLS but skip chain & host verification
+  # insecure_skip_verify = false
+
+  ## HTTP Content-Encoding for write request body, can be set to "gzip" to
+  ## compress body or "identity" to apply no encoding.
+  # content_encoding = "gzip"
+
+  ## Additional HTTP headers, such as the tenant of a multi-tenant Loki
+  # [outputs.loki.headers]
+  #   X-Scope-OrgID = "tenant1"
+`
+
+const (
+	formatLogfmt = "logfmt"
+	formatJSON   = "json"
+)
+
+type Loki struct {
+	URL              string            `toml:"url"`
+	Timeout          internal.Duration `toml:"timeout"`
+	LabelTags        []string          `toml:"label_tags"`
+	MeasurementLabel string            `toml:"measurement_label"`
+	LineFormat       string            `toml:"line_format"`
+	Username         string            `toml:"username"`
+	Password         string            `toml:"password"`
+	BearerToken      string            `toml:"bearer_token"`
+	ContentEncoding  string            `toml:"content_encoding"`
+	Headers          map[string]string `toml:"headers"`
+	tls.ClientConfig
+
+	client    *http.Client
+	labelTags map[string]bool
+}
+
+func (l *Loki) SampleConfig() string {
+	return sampleConfig
+}
+
+func (l *Loki) Description() string {
+	return "Send metrics as log lines to Loki"
+}
+
+func (l *Loki) Connect() error {
+	switch l.LineFormat {
+	case formatLogfmt, formatJSON:
+	default:
+		return fmt.Errorf("invalid line_format: %s", l.LineFormat)
+	}
+
+	switch l.ContentEncoding {
+	case "", "identity", "gzip":
+	default:
+		return fmt.Errorf("invalid content_encoding: %s", l.ContentEncoding)
+	}
+
+	tlsCfg, err := l.ClientConfig.TLSConfig()
+	if err != nil {
+		return err
+	}
+
+	l.client = &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: tlsCfg,
+			Proxy:           http.ProxyFromEnvironment,
+		},
+		Timeout: l.Timeout.Duration,
+	}
+
+	l.labelTags = make(map[string]bool, len(l.LabelTags))
+	for _, tag := range l.LabelTags {
+		l.labelTags[tag] = true
+	}
+	return nil
+}
+
+func (l *Loki) Close() error {
+	return nil
+}
+
+// stream is a Loki log stream, values are pairs of the timestamp in
+// nanoseconds and the log line.
+type stream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+type entry struct {
+	time time.Time
+	line string
+}
+
+func (l *Loki) Write(metrics []telegraf.Metric) error {
+	streams := make(map[string]map[string]string)
+	entries := make(map[string][]entry)
+	for _, m := range metrics {
+		labels, line, err := l.render(m)
+		if err != nil {
+			// Retrying cannot render the metric, drop it to not hold up
+			// the others.
+			log.Printf("E! [outputs.loki] Dropping metric %s: %v", m.Name(), err)
+			continue
+		}
+
+		key := streamKey(labels)
+		if _, ok := streams[key]; !ok {
+			streams[key] = labels
+		}
+		entries[key] = append(entries[key], entry{time: m.Time(), line: line})
+	}
+
+	keys := make([]string, 0, len(streams))
+	for key := range streams {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	req := struct {
+		Streams []stream `json:"streams"`
+	}{}
+	for _, key := range keys {
+		es := entries[key]
+		// Loki rejects entries older than the last one of a stream
+		sort.SliceStable(es, func(i, j int) bool { return es[i].time.Before(es[j].time) })
+
+		s := stream{Stream: streams[key], Values: make([][2]string, 0, len(es))}
+		for _, e := range es {
+			s.Values = append(s.Values, [2]string{strconv.FormatInt(e.time.UnixNano(), 10), e.line})
+		}
+		req.Streams = append(req.Streams, s)
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	return l.send(body)
+}
+
+// render returns the stream labels and the log line of a metric.
+func (l *Loki) render(m telegraf.Metric) (map[string]string, string, error) {
+	labels := make(map[string]string)
+	if l.MeasurementLabel != "" {
+		labels[sanitizeLabel(l.MeasurementLabel)] = m.Name()
+	}
+
+	var tags []*telegraf.Tag
+	for _, tag := range m.TagList() {
+		if l.labelTags[tag.Key] {
+			labels[sanitizeLabel(tag.Key)] = tag.Value
+			continue
+		}
+		tags = append(tags, tag)
+	}
+
+	fields := append([]*telegraf.Field(nil), m.FieldList()...)
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Key < fields[j].Key })
+
+	if l.LineFormat == formatJSON {
+		values := make(map[string]interface{}, len(tags)+len(fields))
+		for _, tag := range tags {
+			values[tag.Key] = tag.Value
+		}
+		for _, field := range fields {
+			if v, ok := field.Value.(float64); ok && (math.IsNaN(v) || math.IsInf(v, 0)) {
+				log.Printf("D! [outputs.loki] Skipping field %s of metric %s, %v is not valid JSON",
+					field.Key, m.Name(), v)
+				continue
+			}
+			values[field.Key] = field.Value
+		}
+		line, err := json.Marshal(values)
+		if err != nil {
+			return nil, "", err
+		}
+		return labels, string(line), nil
+	}
+
+	var buf bytes.Buffer
+	for _, tag := range tags {
+		writeLogfmt(&buf, tag.Key, tag.Value)
+	}
+	for _, field := range fields {
+		writeLogfmt(&buf, field.Key, internal.FormatValue(field.Value))
+	}
+	return labels, buf.String(), nil
+}
+
+func streamKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteByte(0)
+		b.WriteString(labels[k])
+		b.WriteByte(0)
+	}
+	return b.String()
+}
+
+// sanitizeLabel replaces the characters not allowed in Loki label names.
+func sanitizeLabel(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			r = '_'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func writeLogfmt(buf *bytes.Buffer, key, value string) {
+	if buf.Len() > 0 {
+		buf.WriteByte(' ')
+	}
+	buf.WriteString(strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '=' || r == '"' {
+			return '_'
+		}
+		return r
+	}, key))
+	buf.WriteByte('=')
+
+	if value == "" || strings.IndexFunc(value, func(r rune) bool {
+		return r <= ' ' || r == '=' || r == '"' || r == '\\'
+	}) >= 0 {
+		value = strconv.Quote(value)
+	}
+	buf.WriteString(value)
+}
+
+func (l *Loki) send(body []byte) error {
+	var reqBody io.Reader = bytes.NewReader(body)
+	if l.ContentEncoding == "gzip" {
+		var err error
+		reqBody, err = internal.CompressWithGzip(reqBody)
+		if err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodPost, l.URL, reqBody)
+	if err != nil {
+		return err
+	}
+
+	if l.Username != "" || l.Password != "" {
+		req.SetBasicAuth(l.Username, l.Password)
+	}
+	if l.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+l.BearerToken)
+	}
+
+	req.Header.Set("User-Agent", "Telegraf/"+internal.Version())
+	req.Header.Set("Content-Type", "application/json")
+	if l.ContentEncoding == "gzip" {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	for k, v := range l.Headers {
+		if strings.ToLower(k) == "host" {
+			req.Host = v
+		}
+		req.Header.Set(k, v)
+	}
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("when writing to [%s] received status code %d: %s",
+			l.URL, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	_, err = io.Copy(ioutil.Discard, resp.Body)
+	return err
+}
+
+func init() {
+	outputs.Add("loki", func() telegraf.Output {
+		return &Loki{
+			URL:              "http://localhost:3100/loki/api/v1/push",
+			Timeout:          internal.Duration{Duration: 5 * time.Second},
+			LabelTags:        []string{"host"},
+			MeasurementLabel: "measurement",
+			LineFormat:       formatLogfmt,
+			ContentEncoding:  "gzip",
+		}
+	})
+}
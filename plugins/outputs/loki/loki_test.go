@@ -0,0 +1,200 @@
+package loki
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+type pushRequest struct {
+	Streams []stream `json:"streams"`
+}
+
+func newLoki(url string) *Loki {
+	return &Loki{
+		URL:              url,
+		Timeout:          internal.Duration{Duration: 5 * time.Second},
+		LabelTags:        []string{"host"},
+		MeasurementLabel: "measurement",
+		LineFormat:       formatLogfmt,
+	}
+}
+
+func testMetrics() []telegraf.Metric {
+	return []telegraf.Metric{
+		testutil.MustMetric("syslog",
+			map[string]string{"host": "a", "appname": "sshd"},
+			map[string]interface{}{"message": "session opened for user root", "severity_code": int64(6)},
+			time.Unix(0, 3)),
+		testutil.MustMetric("syslog",
+			map[string]string{"host": "b", "appname": "cron"},
+			map[string]interface{}{"message": "job done", "ok": true},
+			time.Unix(0, 2)),
+		testutil.MustMetric("syslog",
+			map[string]string{"host": "a", "appname": "sshd"},
+			map[string]interface{}{"message": "connection from 10.0.0.1", "severity_code": int64(6)},
+			time.Unix(0, 1)),
+	}
+}
+
+func TestWrite(t *testing.T) {
+	var got pushRequest
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/loki/api/v1/push", r.URL.Path)
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.Equal(t, "tenant1", r.Header.Get("X-Scope-OrgID"))
+		user, password, ok := r.BasicAuth()
+		require.True(t, ok)
+		require.Equal(t, "user", user)
+		require.Equal(t, "secret", password)
+
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	l := newLoki(ts.URL + "/loki/api/v1/push")
+	l.Username = "user"
+	l.Password = "secret"
+	l.Headers = map[string]string{"X-Scope-OrgID": "tenant1"}
+	require.NoError(t, l.Connect())
+	require.NoError(t, l.Write(testMetrics()))
+
+	expected := pushRequest{
+		Streams: []stream{
+			{
+				Stream: map[string]string{"host": "a", "measurement": "syslog"},
+				Values: [][2]string{
+					{"1", `appname=sshd message="connection from 10.0.0.1" severity_code=6`},
+					{"3", `appname=sshd message="session opened for user root" severity_code=6`},
+				},
+			},
+			{
+				Stream: map[string]string{"host": "b", "measurement": "syslog"},
+				Values: [][2]string{
+					{"2", `appname=cron message="job done" ok=true`},
+				},
+			},
+		},
+	}
+	require.Equal(t, expected, got)
+}
+
+func TestWriteJSONGzipBearer(t *testing.T) {
+	var got pushRequest
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		require.Equal(t, "gzip", r.Header.Get("Content-Encoding"))
+
+		gz, err := gzip.NewReader(r.Body)
+		require.NoError(t, err)
+		require.NoError(t, json.NewDecoder(gz).Decode(&got))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	l := newLoki(ts.URL)
+	l.LineFormat = formatJSON
+	l.ContentEncoding = "gzip"
+	l.BearerToken = "token"
+	l.LabelTags = []string{"host", "app.name"}
+	l.MeasurementLabel = ""
+	require.NoError(t, l.Connect())
+
+	require.NoError(t, l.Write([]telegraf.Metric{
+		testutil.MustMetric("docker_log",
+			map[string]string{"host": "a", "app.name": "web", "stream": "stderr"},
+			map[string]interface{}{"message": "GET / 200", "size": 1.5},
+			time.Unix(1, 0)),
+	}))
+
+	expected := pushRequest{
+		Streams: []stream{
+			{
+				Stream: map[string]string{"host": "a", "app_name": "web"},
+				Values: [][2]string{
+					{"1000000000", `{"message":"GET / 200","size":1.5,"stream":"stderr"}`},
+				},
+			},
+		},
+	}
+	require.Equal(t, expected, got)
+}
+
+func TestWriteJSONSkipsNonFinite(t *testing.T) {
+	var got pushRequest
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	l := newLoki(ts.URL)
+	l.LineFormat = formatJSON
+	require.NoError(t, l.Connect())
+
+	m := testutil.MustMetric("m",
+		map[string]string{"host": "a"},
+		map[string]interface{}{"value": 1.0, "nan": math.NaN(), "inf": math.Inf(-1)},
+		time.Unix(0, 1))
+	fields := append([]*telegraf.Field(nil), m.FieldList()...)
+	require.NoError(t, l.Write([]telegraf.Metric{m}))
+
+	expected := pushRequest{
+		Streams: []stream{
+			{
+				Stream: map[string]string{"host": "a", "measurement": "m"},
+				Values: [][2]string{{"1", `{"value":1}`}},
+			},
+		},
+	}
+	require.Equal(t, expected, got)
+	// The field list of the metric is left as is.
+	require.Equal(t, fields, m.FieldList())
+}
+
+func TestWriteError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, "entry out of order\n")
+	}))
+	defer ts.Close()
+
+	l := newLoki(ts.URL)
+	require.NoError(t, l.Connect())
+	err := l.Write(testMetrics())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "entry out of order")
+}
+
+func TestLogfmt(t *testing.T) {
+	l := newLoki("")
+	require.NoError(t, l.Connect())
+
+	_, line, err := l.render(testutil.MustMetric("m",
+		map[string]string{"path": `C:\temp`, "empty key": "x"},
+		map[string]interface{}{"a": "", "b": `say "hi"`, "c": "x=y", "d": 0.000001, "e": uint64(18446744073709551615)},
+		time.Unix(0, 0)))
+	require.NoError(t, err)
+	require.Equal(t, `empty_key=x path="C:\\temp" a="" b="say \"hi\"" c="x=y" d=0.000001 e=18446744073709551615`, line)
+}
+
+func TestInvalidConfig(t *testing.T) {
+	l := newLoki("")
+	l.LineFormat = "xml"
+	require.Error(t, l.Connect())
+
+	l = newLoki("")
+	l.ContentEncoding = "br"
+	require.Error(t, l.Connect())
+}
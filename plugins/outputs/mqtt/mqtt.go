@@ -1,10 +1,13 @@
 package mqtt
 
 import (
+	"bytes"
 	"fmt"
 	"log"
+	"sort"
 	"strings"
 	"sync"
+	"text/template"
 	"time"
 
 	paho "github.com/eclipse/paho.mqtt.golang"
@@ -23,6 +26,19 @@ var sampleConfig = `
   ##   ex: prefix/web01.example.com/mem
   topic_prefix = "telegraf"
 
+  ## Topic as a Go template, overrides topic_prefix when set.  Available
+  ## are {{.Name}}, {{.Tag "key"}}, {{.Tags}}, and {{.Field}} when fields
+  ## are published separately.
+  ##   ex: site/{{.Tag "site"}}/device/{{.Tag "device"}}/{{.Field}}
+  # topic = ""
+
+  ## MQTT protocol version, "3.1.1" or "5".
+  # protocol = "3.1.1"
+
+  ## MQTT v5 user properties added to every message.
+  # [outputs.mqtt.user_properties]
+  #   source = "telegraf"
+
   ## QoS policy for messages
   ##   0 = at most once
   ##   1 = at least once
@@ -54,6 +70,12 @@ var sampleConfig = `
   ## actually reads it
   # retain = false
 
+  ## When true, each field is published separately as its raw value
+  ## instead of serializing the metric, and batch and data_format are
+  ## ignored.  Without a topic template the field name is appended to the
+  ## topic.
+  # publish_fields = false
+
   ## Data format to output.
   ## Each data format has its own unique set of configuration options, read
   ## more about them here:
@@ -71,17 +93,86 @@ type MQTT struct {
 	QoS         int    `toml:"qos"`
 	ClientID    string `toml:"client_id"`
 	tls.ClientConfig
-	BatchMessage bool `toml:"batch"`
-	Retain       bool `toml:"retain"`
-
-	client paho.Client
+	BatchMessage   bool              `toml:"batch"`
+	Retain         bool              `toml:"retain"`
+	Topic          string            `toml:"topic"`
+	Protocol       string            `toml:"protocol"`
+	UserProperties map[string]string `toml:"user_properties"`
+	PublishFields  bool              `toml:"publish_fields"`
+
+	client client
 	opts   *paho.ClientOptions
+	topic  *template.Template
 
 	serializer serializers.Serializer
 
 	sync.Mutex
 }
 
+// client publishes messages to the MQTT servers.
+type client interface {
+	Connect() error
+	Publish(topic string, qos byte, retain bool, payload []byte, properties map[string]string) error
+	Close() error
+}
+
+// v3Client is a MQTT 3.1.1 client, the paho client reconnects
+// automatically.
+type v3Client struct {
+	client  paho.Client
+	timeout time.Duration
+}
+
+func (c *v3Client) Connect() error {
+	if token := c.client.Connect(); token.Wait() && token.Error() != nil {
+		return token.Error()
+	}
+	return nil
+}
+
+func (c *v3Client) Publish(topic string, qos byte, retain bool, payload []byte, _ map[string]string) error {
+	token := c.client.Publish(topic, qos, retain, payload)
+	token.WaitTimeout(c.timeout)
+	if token.Error() != nil {
+		return token.Error()
+	}
+	return nil
+}
+
+func (c *v3Client) Close() error {
+	if c.client.IsConnected() {
+		c.client.Disconnect(20)
+	}
+	return nil
+}
+
+// topicData is the data of the topic template.
+type topicData struct {
+	metric telegraf.Metric
+	field  string
+}
+
+// Name returns the measurement name.
+func (d *topicData) Name() string {
+	return d.metric.Name()
+}
+
+// Tag returns the value of the tag key, or an empty string.
+func (d *topicData) Tag(key string) string {
+	value, _ := d.metric.GetTag(key)
+	return value
+}
+
+// Tags returns all tags.
+func (d *topicData) Tags() map[string]string {
+	return d.metric.Tags()
+}
+
+// Field returns the name of the field published.
+func (d *topicData) Field() string {
+	return d.field
+}
+
 func (m *MQTT) Connect() error {
 	var err error
 	m.Lock()
@@ -90,17 +181,34 @@ func (m *MQTT) Connect() error {
 		return fmt.Errorf("MQTT Output, invalid QoS value: %d", m.QoS)
 	}
 
-	m.opts, err = m.createOpts()
-	if err != nil {
-		return err
+	if m.Topic != "" {
+		m.topic, err = template.New("topic").Option("missingkey=zero").Parse(m.Topic)
+		if err != nil {
+			return fmt.Errorf("MQTT Output, invalid topic template: %v", err)
+		}
 	}
 
-	m.client = paho.NewClient(m.opts)
-	if token := m.client.Connect(); token.Wait() && token.Error() != nil {
-		return token.Error()
+	if m.Timeout.Duration < time.Second {
+		m.Timeout.Duration = 5 * time.Second
 	}
 
-	return nil
+	switch m.Protocol {
+	case "", "3.1.1":
+		m.opts, err = m.createOpts()
+		if err != nil {
+			return err
+		}
+		m.client = &v3Client{client: paho.NewClient(m.opts), timeout: m.Timeout.Duration}
+	case "5":
+		m.client, err = m.createV5Client()
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("MQTT Output, invalid protocol: %s", m.Protocol)
+	}
+
+	return m.client.Connect()
 }
 
 func (m *MQTT) SetSerializer(serializer serializers.Serializer) {
@@ -108,10 +216,7 @@ func (m *MQTT) SetSerializer(serializer serializers.Serializer) {
 }
 
 func (m *MQTT) Close() error {
-	if m.client.IsConnected() {
-		m.client.Disconnect(20)
-	}
-	return nil
+	return m.client.Close()
 }
 
 func (m *MQTT) SampleConfig() string {
@@ -136,16 +241,30 @@ func (m *MQTT) Write(metrics []telegraf.Metric) error {
 	metricsmap := make(map[string][]telegraf.Metric)
 
 	for _, metric := range metrics {
-		var t []string
-		if m.TopicPrefix != "" {
-			t = append(t, m.TopicPrefix)
-		}
-		if hostname != "" {
-			t = append(t, hostname)
+		if m.PublishFields {
+			// publish in a stable order, the field list is in map order
+			fields := append([]*telegraf.Field(nil), metric.FieldList()...)
+			sort.Slice(fields, func(i, j int) bool { return fields[i].Key < fields[j].Key })
+			for _, field := range fields {
+				topic, err := m.topicName(metric, field.Key, hostname)
+				if err != nil {
+					log.Printf("E! [outputs.mqtt] Could not create topic: %v", err)
+					continue
+				}
+
+				err = m.publish(topic, []byte(internal.FormatValue(field.Value)))
+				if err != nil {
+					return fmt.Errorf("Could not write to MQTT server, %s", err)
+				}
+			}
+			continue
 		}
 
-		t = append(t, metric.Name())
-		topic := strings.Join(t, "/")
+		topic, err := m.topicName(metric, "", hostname)
+		if err != nil {
+			log.Printf("E! [outputs.mqtt] Could not create topic: %v", err)
+			continue
+		}
 
 		if m.BatchMessage {
 			metricsmap[topic] = append(metricsmap[topic], metric)
@@ -178,22 +297,74 @@ func (m *MQTT) Write(metrics []telegraf.Metric) error {
 	return nil
 }
 
+// topicName returns the topic of a metric, or of one of its fields if field
+// is set.
+func (m *MQTT) topicName(metric telegraf.Metric, field, hostname string) (string, error) {
+	if m.topic != nil {
+		var buf bytes.Buffer
+		err := m.topic.Execute(&buf, &topicData{metric: metric, field: field})
+		if err != nil {
+			return "", err
+		}
+		return buf.String(), nil
+	}
+
+	var t []string
+	if m.TopicPrefix != "" {
+		t = append(t, m.TopicPrefix)
+	}
+	if hostname != "" {
+		t = append(t, hostname)
+	}
+
+	t = append(t, metric.Name())
+	if field != "" {
+		t = append(t, field)
+	}
+	return strings.Join(t, "/"), nil
+}
+
 func (m *MQTT) publish(topic string, body []byte) error {
-	token := m.client.Publish(topic, byte(m.QoS), m.Retain, body)
-	token.WaitTimeout(m.Timeout.Duration)
-	if token.Error() != nil {
-		return token.Error()
+	return m.client.Publish(topic, byte(m.QoS), m.Retain, body, m.UserProperties)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
 	}
-	return nil
+	sort.Strings(keys)
+	return keys
+}
+
+func (m *MQTT) createV5Client() (*v5Client, error) {
+	if len(m.Servers) == 0 {
+		return nil, fmt.Errorf("could not get host infomations")
+	}
+
+	tlsCfg, err := m.ClientConfig.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	clientID := m.ClientID
+	if clientID == "" {
+		clientID = "Telegraf-Output-" + internal.RandomString(5)
+	}
+
+	return &v5Client{
+		servers:  m.Servers,
+		tls:      tlsCfg,
+		clientID: clientID,
+		username: m.Username,
+		password: m.Password,
+		timeout:  m.Timeout.Duration,
+	}, nil
 }
 
 func (m *MQTT) createOpts() (*paho.ClientOptions, error) {
 	opts := paho.NewClientOptions()
 	opts.KeepAlive = 0
-
-	if m.Timeout.Duration < time.Second {
-		m.Timeout.Duration = 5 * time.Second
-	}
 	opts.WriteTimeout = m.Timeout.Duration
 
 	if m.ClientID != "" {
@@ -1,9 +1,19 @@
 package mqtt
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
 	"testing"
+	"text/template"
+	"time"
 
+	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/plugins/serializers"
+	"github.com/influxdata/telegraf/plugins/serializers/influx"
 	"github.com/influxdata/telegraf/testutil"
 
 	"github.com/stretchr/testify/require"
@@ -29,3 +39,297 @@ func TestConnectAndWrite(t *testing.T) {
 	err = m.Write(testutil.MockMetrics())
 	require.NoError(t, err)
 }
+
+type message struct {
+	topic      string
+	qos        byte
+	retain     bool
+	payload    string
+	properties map[string]string
+}
+
+type fakeClient struct {
+	messages []message
+}
+
+func (c *fakeClient) Connect() error {
+	return nil
+}
+
+func (c *fakeClient) Publish(topic string, qos byte, retain bool, payload []byte, properties map[string]string) error {
+	c.messages = append(c.messages, message{topic, qos, retain, string(payload), properties})
+	return nil
+}
+
+func (c *fakeClient) Close() error {
+	return nil
+}
+
+func newFakeMQTT(t *testing.T, m *MQTT) *fakeClient {
+	if m.Topic != "" {
+		var err error
+		m.topic, err = template.New("topic").Option("missingkey=zero").Parse(m.Topic)
+		require.NoError(t, err)
+	}
+	// sort the fields to compare the payloads
+	s := influx.NewSerializer()
+	s.SetFieldSortOrder(influx.SortFields)
+	m.serializer = s
+	c := &fakeClient{}
+	m.client = c
+	return c
+}
+
+func testMetric() telegraf.Metric {
+	return testutil.MustMetric("sensor",
+		map[string]string{"site": "ams", "device": "d1", "host": "gw"},
+		map[string]interface{}{"temp": 21.5, "on": true},
+		time.Unix(0, 0))
+}
+
+func TestDefaultTopic(t *testing.T) {
+	m := &MQTT{TopicPrefix: "telegraf", QoS: 1, Retain: true}
+	c := newFakeMQTT(t, m)
+
+	require.NoError(t, m.Write([]telegraf.Metric{testMetric()}))
+	require.Equal(t, []message{
+		{topic: "telegraf/gw/sensor", qos: 1, retain: true, payload: "sensor,device=d1,host=gw,site=ams on=true,temp=21.5 0\n"},
+	}, c.messages)
+}
+
+func TestTopicTemplate(t *testing.T) {
+	m := &MQTT{
+		Topic:          `site/{{.Tag "site"}}/device/{{.Tag "device"}}/{{.Name}}{{.Tag "missing"}}`,
+		BatchMessage:   true,
+		UserProperties: map[string]string{"source": "telegraf"},
+	}
+	c := newFakeMQTT(t, m)
+
+	require.NoError(t, m.Write([]telegraf.Metric{testMetric(), testMetric()}))
+	require.Len(t, c.messages, 1)
+	require.Equal(t, "site/ams/device/d1/sensor", c.messages[0].topic)
+	require.Equal(t, map[string]string{"source": "telegraf"}, c.messages[0].properties)
+	require.Equal(t, 2, strings.Count(c.messages[0].payload, "\n"))
+}
+
+func TestPublishFields(t *testing.T) {
+	m := &MQTT{Topic: `site/{{.Tag "site"}}/device/{{.Tag "device"}}/{{.Field}}`, PublishFields: true}
+	c := newFakeMQTT(t, m)
+
+	require.NoError(t, m.Write([]telegraf.Metric{testMetric()}))
+	require.Equal(t, []message{
+		{topic: "site/ams/device/d1/on", payload: "true"},
+		{topic: "site/ams/device/d1/temp", payload: "21.5"},
+	}, c.messages)
+
+	m = &MQTT{TopicPrefix: "telegraf", PublishFields: true}
+	c = newFakeMQTT(t, m)
+	require.NoError(t, m.Write([]telegraf.Metric{testMetric()}))
+	require.Equal(t, "telegraf/gw/sensor/on", c.messages[0].topic)
+}
+
+func TestInvalidConfig(t *testing.T) {
+	m := &MQTT{Servers: []string{"localhost:1883"}, Protocol: "4"}
+	require.Error(t, m.Connect())
+
+	m = &MQTT{Servers: []string{"localhost:1883"}, Topic: "{{.Name"}
+	require.Error(t, m.Connect())
+
+	m = &MQTT{Servers: []string{"localhost:1883"}, QoS: 3}
+	require.Error(t, m.Connect())
+}
+
+// broker is a MQTT v5 server accepting one connection and recording the
+// published messages.
+type broker struct {
+	listener net.Listener
+	messages chan message
+	connect  chan []byte
+	// reason code of the acknowledgements
+	reason byte
+	// properties of the CONNACK, and the maximum QoS they announce
+	connack []byte
+	maxQoS  byte
+}
+
+func newBroker(t *testing.T) *broker {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	b := &broker{listener: l, messages: make(chan message, 10), connect: make(chan []byte, 1), maxQoS: 2}
+	go b.serve()
+	return b
+}
+
+func (b *broker) serve() {
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			return
+		}
+		go b.handle(conn)
+	}
+}
+
+func (b *broker) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	c := &v5Client{conn: conn}
+	for {
+		header, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+		length, err := readVarint(r)
+		if err != nil {
+			return
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return
+		}
+
+		switch header >> 4 {
+		case packetConnect:
+			b.connect <- body
+			var connack bytes.Buffer
+			connack.Write([]byte{0, 0})
+			writeVarint(&connack, len(b.connack))
+			connack.Write(b.connack)
+			c.writePacket(packetConnack<<4, connack.Bytes())
+		case packetPublish:
+			qos := header >> 1 & 0x03
+			if qos > b.maxQoS {
+				c.writePacket(packetDisconnect<<4, []byte{0x9B, 0})
+				return
+			}
+			msg := message{qos: qos, retain: header&0x01 != 0, properties: map[string]string{}}
+			n := int(binary.BigEndian.Uint16(body))
+			msg.topic = string(body[2 : 2+n])
+			body = body[2+n:]
+
+			var id []byte
+			if qos > 0 {
+				id = body[:2]
+				body = body[2:]
+			}
+			br := bytes.NewReader(body)
+			propLen, _ := readVarint(br)
+			props := body[len(body)-br.Len() : len(body)-br.Len()+propLen]
+			for len(props) > 0 && props[0] == propertyUserProperty {
+				kl := int(binary.BigEndian.Uint16(props[1:]))
+				key := string(props[3 : 3+kl])
+				props = props[3+kl:]
+				vl := int(binary.BigEndian.Uint16(props))
+				msg.properties[key] = string(props[2 : 2+vl])
+				props = props[2+vl:]
+			}
+			msg.payload = string(body[len(body)-br.Len()+propLen:])
+			b.messages <- msg
+
+			switch qos {
+			case 1:
+				c.writePacket(packetPuback<<4, append(id, b.reason))
+			case 2:
+				c.writePacket(packetPubrec<<4, append(id, b.reason))
+			}
+		case packetPubrel:
+			c.writePacket(packetPubcomp<<4, body[:2])
+		case packetDisconnect:
+			return
+		}
+	}
+}
+
+func TestV5Client(t *testing.T) {
+	b := newBroker(t)
+	defer b.listener.Close()
+
+	for _, qos := range []byte{0, 1, 2} {
+		c := &v5Client{
+			servers:  []string{"127.0.0.1:1", b.listener.Addr().String()},
+			clientID: "telegraf",
+			username: "user",
+			password: "secret",
+			timeout:  time.Second,
+		}
+		require.NoError(t, c.Connect())
+
+		connect := <-b.connect
+		require.Equal(t, []byte{0, 4, 'M', 'Q', 'T', 'T', 5, 0xC2, 0, 0, 0}, connect[:11])
+		require.Equal(t, "\x00\x08telegraf\x00\x04user\x00\x06secret", string(connect[11:]))
+
+		props := map[string]string{"source": "telegraf", "site": "ams"}
+		require.NoError(t, c.Publish("a/b", qos, qos == 1, []byte("21.5"), props))
+		require.Equal(t, message{topic: "a/b", qos: qos, retain: qos == 1, payload: "21.5", properties: props}, <-b.messages)
+
+		require.NoError(t, c.Publish("a/c", qos, false, []byte("x"), nil))
+		require.Equal(t, "a/c", (<-b.messages).topic)
+		require.NoError(t, c.Close())
+	}
+}
+
+func TestV5ClientRejected(t *testing.T) {
+	b := newBroker(t)
+	defer b.listener.Close()
+	b.reason = 0x87
+
+	c := &v5Client{servers: []string{b.listener.Addr().String()}, clientID: "telegraf", timeout: time.Second}
+	require.NoError(t, c.Connect())
+	<-b.connect
+
+	err := c.Publish("a/b", 1, false, []byte("x"), nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "not authorized")
+	<-b.messages
+
+	// the connection is closed and opened again on the next publish
+	require.Nil(t, c.conn)
+	b.reason = 0
+	require.NoError(t, c.Publish("a/b", 1, false, []byte("x"), nil))
+	<-b.connect
+	<-b.messages
+	c.Close()
+}
+
+func TestV5ClientServerLimits(t *testing.T) {
+	b := newBroker(t)
+	defer b.listener.Close()
+	// receive maximum, reason string, maximum QoS, user property and retain
+	// available
+	b.connack = []byte{
+		0x21, 0, 10,
+		0x1F, 0, 2, 'o', 'k',
+		propertyMaximumQoS, 1,
+		propertyUserProperty, 0, 1, 'a', 0, 1, 'b',
+		propertyRetainAvailable, 0,
+	}
+	b.maxQoS = 1
+
+	c := &v5Client{servers: []string{b.listener.Addr().String()}, clientID: "telegraf", timeout: time.Second}
+	require.NoError(t, c.Connect())
+	<-b.connect
+
+	require.NoError(t, c.Publish("a/b", 2, true, []byte("x"), nil))
+	msg := <-b.messages
+	require.Equal(t, byte(1), msg.qos)
+	require.False(t, msg.retain)
+	require.NoError(t, c.Close())
+}
+
+func TestConnackLimits(t *testing.T) {
+	qos, retain, err := connackLimits([]byte{0})
+	require.NoError(t, err)
+	require.Equal(t, byte(2), qos)
+	require.True(t, retain)
+
+	qos, _, err = connackLimits([]byte{2, propertyMaximumQoS, 0})
+	require.NoError(t, err)
+	require.Equal(t, byte(0), qos)
+
+	_, _, err = connackLimits([]byte{2, propertyMaximumQoS, 2})
+	require.Error(t, err)
+	_, _, err = connackLimits([]byte{3, 0x1F, 0, 5})
+	require.Error(t, err)
+	_, _, err = connackLimits([]byte{1, 0x7F})
+	require.Error(t, err)
+}
@@ -0,0 +1,456 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// MQTT v5 control packet types.
+const (
+	packetConnect    = 1
+	packetConnack    = 2
+	packetPublish    = 3
+	packetPuback     = 4
+	packetPubrec     = 5
+	packetPubrel     = 6
+	packetPubcomp    = 7
+	packetPingresp   = 13
+	packetDisconnect = 14
+)
+
+// MQTT v5 property identifiers.
+const (
+	propertyMaximumQoS      = 0x24
+	propertyRetainAvailable = 0x25
+	propertyUserProperty    = 0x26
+)
+
+// v5Client is a minimal MQTT v5 client that only publishes.  Every publish
+// waits for the acknowledgement of its QoS level; on an error the connection
+// is closed and opened again on the next publish.  The QoS and retain flag
+// of the messages are lowered to what the server supports.
+type v5Client struct {
+	servers  []string
+	tls      *tls.Config
+	clientID string
+	username string
+	password string
+	timeout  time.Duration
+
+	sync.Mutex
+	conn     net.Conn
+	reader   *bufio.Reader
+	packetID uint16
+
+	// limits of the server from the CONNACK
+	maxQoS          byte
+	retainAvailable bool
+}
+
+func (c *v5Client) Connect() error {
+	c.Lock()
+	defer c.Unlock()
+	return c.connect()
+}
+
+func (c *v5Client) connect() error {
+	var err error
+	for _, server := range c.servers {
+		err = c.dial(server)
+		if err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func (c *v5Client) dial(server string) error {
+	dialer := &net.Dialer{Timeout: c.timeout}
+	var conn net.Conn
+	var err error
+	if c.tls != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", server, c.tls)
+	} else {
+		conn, err = dialer.Dial("tcp", server)
+	}
+	if err != nil {
+		return err
+	}
+
+	c.conn = conn
+	c.reader = bufio.NewReader(conn)
+	conn.SetDeadline(time.Now().Add(c.timeout))
+
+	if err := c.writePacket(packetConnect<<4, c.connectPacket()); err != nil {
+		c.close()
+		return err
+	}
+
+	typ, body, err := c.readPacket()
+	if err != nil {
+		c.close()
+		return err
+	}
+	if typ != packetConnack || len(body) < 2 {
+		c.close()
+		return fmt.Errorf("unexpected packet type %d connecting to %s", typ, server)
+	}
+	if body[1] >= 0x80 {
+		c.close()
+		return fmt.Errorf("connection to %s refused: %s", server, reasonString(body[1]))
+	}
+
+	c.maxQoS, c.retainAvailable, err = connackLimits(body[2:])
+	if err != nil {
+		c.close()
+		return fmt.Errorf("invalid connack from %s: %v", server, err)
+	}
+	if c.maxQoS < 2 {
+		log.Printf("I! [outputs.mqtt] Server %s supports QoS up to %d, messages are sent with at most this QoS",
+			server, c.maxQoS)
+	}
+	if !c.retainAvailable {
+		log.Printf("I! [outputs.mqtt] Server %s does not support retained messages, messages are sent without retain",
+			server)
+	}
+	return nil
+}
+
+// connackLimits returns the maximum QoS and whether retained messages are
+// available from the CONNACK properties.
+func connackLimits(b []byte) (byte, bool, error) {
+	maxQoS, retain := byte(2), true
+	r := bytes.NewReader(b)
+	length, err := readVarint(r)
+	if err != nil {
+		return 0, false, err
+	}
+	if length > r.Len() {
+		return 0, false, io.ErrUnexpectedEOF
+	}
+	props := b[len(b)-r.Len() : len(b)-r.Len()+length]
+
+	for len(props) > 0 {
+		id := props[0]
+		n, err := propertyLen(id, props[1:])
+		if err != nil {
+			return 0, false, err
+		}
+		value := props[1 : 1+n]
+		props = props[1+n:]
+
+		switch id {
+		case propertyMaximumQoS:
+			if value[0] > 1 {
+				return 0, false, fmt.Errorf("invalid maximum QoS %d", value[0])
+			}
+			maxQoS = value[0]
+		case propertyRetainAvailable:
+			retain = value[0] != 0
+		}
+	}
+	return maxQoS, retain, nil
+}
+
+// propertyLen returns the length of the value of the property id at the
+// start of b.
+func propertyLen(id byte, b []byte) (int, error) {
+	var n int
+	switch id {
+	case 0x01, 0x17, 0x19, 0x24, 0x25, 0x28, 0x29, 0x2A:
+		n = 1
+	case 0x13, 0x21, 0x22, 0x23:
+		n = 2
+	case 0x02, 0x11, 0x18, 0x27:
+		n = 4
+	case 0x0B:
+		r := bytes.NewReader(b)
+		if _, err := readVarint(r); err != nil {
+			return 0, err
+		}
+		n = len(b) - r.Len()
+	case 0x03, 0x08, 0x09, 0x12, 0x15, 0x16, 0x1A, 0x1C, 0x1F:
+		// string or binary data
+		if len(b) < 2 {
+			return 0, io.ErrUnexpectedEOF
+		}
+		n = 2 + int(binary.BigEndian.Uint16(b))
+	case propertyUserProperty:
+		if len(b) < 2 {
+			return 0, io.ErrUnexpectedEOF
+		}
+		n = 2 + int(binary.BigEndian.Uint16(b))
+		if len(b) < n+2 {
+			return 0, io.ErrUnexpectedEOF
+		}
+		n += 2 + int(binary.BigEndian.Uint16(b[n:]))
+	default:
+		return 0, fmt.Errorf("unknown property 0x%02x", id)
+	}
+	if len(b) < n {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return n, nil
+}
+
+func (c *v5Client) connectPacket() []byte {
+	var buf bytes.Buffer
+	writeString(&buf, "MQTT")
+	buf.WriteByte(5)
+
+	// clean start
+	flags := byte(0x02)
+	if c.username != "" {
+		flags |= 0x80
+	}
+	if c.password != "" {
+		flags |= 0x40
+	}
+	buf.WriteByte(flags)
+	// no keep alive
+	binary.Write(&buf, binary.BigEndian, uint16(0))
+	// no properties
+	writeVarint(&buf, 0)
+
+	writeString(&buf, c.clientID)
+	if c.username != "" {
+		writeString(&buf, c.username)
+	}
+	if c.password != "" {
+		writeString(&buf, c.password)
+	}
+	return buf.Bytes()
+}
+
+func (c *v5Client) Publish(topic string, qos byte, retain bool, payload []byte, properties map[string]string) error {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.conn == nil {
+		if err := c.connect(); err != nil {
+			return err
+		}
+	}
+
+	if qos > c.maxQoS {
+		qos = c.maxQoS
+	}
+	if !c.retainAvailable {
+		retain = false
+	}
+
+	err := c.publish(topic, qos, retain, payload, properties)
+	if err != nil {
+		c.close()
+	}
+	return err
+}
+
+func (c *v5Client) publish(topic string, qos byte, retain bool, payload []byte, properties map[string]string) error {
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+
+	header := byte(packetPublish<<4) | qos<<1
+	if retain {
+		header |= 0x01
+	}
+
+	var buf bytes.Buffer
+	writeString(&buf, topic)
+
+	var id uint16
+	if qos > 0 {
+		c.packetID++
+		if c.packetID == 0 {
+			c.packetID = 1
+		}
+		id = c.packetID
+		binary.Write(&buf, binary.BigEndian, id)
+	}
+
+	var props bytes.Buffer
+	for _, key := range sortedKeys(properties) {
+		props.WriteByte(propertyUserProperty)
+		writeString(&props, key)
+		writeString(&props, properties[key])
+	}
+	writeVarint(&buf, props.Len())
+	buf.Write(props.Bytes())
+	buf.Write(payload)
+
+	if err := c.writePacket(header, buf.Bytes()); err != nil {
+		return err
+	}
+
+	switch qos {
+	case 1:
+		return c.waitAck(packetPuback, id)
+	case 2:
+		if err := c.waitAck(packetPubrec, id); err != nil {
+			return err
+		}
+		var rel bytes.Buffer
+		binary.Write(&rel, binary.BigEndian, id)
+		if err := c.writePacket(packetPubrel<<4|0x02, rel.Bytes()); err != nil {
+			return err
+		}
+		return c.waitAck(packetPubcomp, id)
+	}
+	return nil
+}
+
+// waitAck reads packets until the acknowledgement of type typ for the
+// packet id.
+func (c *v5Client) waitAck(typ byte, id uint16) error {
+	for {
+		t, body, err := c.readPacket()
+		if err != nil {
+			return err
+		}
+
+		switch t {
+		case typ:
+			if len(body) < 2 || binary.BigEndian.Uint16(body) != id {
+				continue
+			}
+			if len(body) > 2 && body[2] >= 0x80 {
+				return fmt.Errorf("publish failed: %s", reasonString(body[2]))
+			}
+			return nil
+		case packetDisconnect:
+			reason := byte(0)
+			if len(body) > 0 {
+				reason = body[0]
+			}
+			return fmt.Errorf("disconnected by server: %s", reasonString(reason))
+		case packetPingresp:
+		default:
+			return fmt.Errorf("unexpected packet type %d", t)
+		}
+	}
+}
+
+func (c *v5Client) Close() error {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.conn == nil {
+		return nil
+	}
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+	// normal disconnection
+	c.writePacket(packetDisconnect<<4, nil)
+	return c.close()
+}
+
+func (c *v5Client) close() error {
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	c.reader = nil
+	return err
+}
+
+func (c *v5Client) writePacket(header byte, body []byte) error {
+	var buf bytes.Buffer
+	buf.WriteByte(header)
+	writeVarint(&buf, len(body))
+	buf.Write(body)
+	_, err := c.conn.Write(buf.Bytes())
+	return err
+}
+
+func (c *v5Client) readPacket() (byte, []byte, error) {
+	header, err := c.reader.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	length, err := readVarint(c.reader)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, body); err != nil {
+		return 0, nil, err
+	}
+	return header >> 4, body, nil
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	binary.Write(buf, binary.BigEndian, uint16(len(s)))
+	buf.WriteString(s)
+}
+
+// writeVarint writes a variable byte integer.
+func writeVarint(buf *bytes.Buffer, n int) {
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		buf.WriteByte(b)
+		if n == 0 {
+			return
+		}
+	}
+}
+
+func readVarint(r io.ByteReader) (int, error) {
+	var n, shift int
+	for i := 0; i < 4; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		n |= int(b&0x7f) << uint(shift)
+		if b&0x80 == 0 {
+			return n, nil
+		}
+		shift += 7
+	}
+	return 0, errors.New("malformed variable byte integer")
+}
+
+var reasonStrings = map[byte]string{
+	0x00: "success",
+	0x80: "unspecified error",
+	0x81: "malformed packet",
+	0x82: "protocol error",
+	0x83: "implementation specific error",
+	0x84: "unsupported protocol version",
+	0x85: "client identifier not valid",
+	0x86: "bad user name or password",
+	0x87: "not authorized",
+	0x88: "server unavailable",
+	0x89: "server busy",
+	0x8A: "banned",
+	0x8B: "server shutting down",
+	0x90: "topic name invalid",
+	0x91: "packet identifier in use",
+	0x93: "receive maximum exceeded",
+	0x95: "packet too large",
+	0x97: "quota exceeded",
+	0x99: "payload format invalid",
+	0x9A: "retain not supported",
+	0x9B: "QoS not supported",
+}
+
+func reasonString(code byte) string {
+	if s, ok := reasonStrings[code]; ok {
+		return s
+	}
+	return fmt.Sprintf("reason code 0x%02x", code)
+}
@@ -0,0 +1,383 @@
+package parquet
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/outputs"
+)
+
+// FilePerm defines the permissions of the written files.
+const FilePerm = os.FileMode(0644)
+
+const defaultRowGroupSize = 8 * 1024 * 1024
+
+type Parquet struct {
+	Directory         string            `toml:"directory"`
+	PartitionInterval internal.Duration `toml:"partition_interval"`
+	PartitionFormat   string            `toml:"partition_format"`
+	RotationInterval  internal.Duration `toml:"rotation_interval"`
+	RotationMaxSize   internal.Size     `toml:"rotation_max_size"`
+	RowGroupSize      internal.Size     `toml:"row_group_size"`
+	Compression       string            `toml:"compression"`
+
+	codec      int32
+	partitions map[partitionKey]*partition
+	now        func() time.Time
+}
+
+type partitionKey struct {
+	measurement string
+	window      int64
+}
+
+// partition buffers the rows of a measurement within a time window until
+// they are written as a row group to the file of the partition.
+type partition struct {
+	measurement string
+	window      time.Time
+	created     time.Time
+	size        int64
+	rows        []row
+
+	// file being written, completed with the footer on rotation
+	file      *os.File
+	filename  string
+	offset    int64
+	columns   []*column
+	rowGroups []rowGroup
+}
+
+var sampleConfig = `
+  ## Directory to write the files to.  Files are written to
+  ## <directory>/<measurement>/<window>/ with one subdirectory per
+  ## measurement and time window.
+  directory = "/var/lib/telegraf/parquet"
+
+  ## Length of the time windows, metrics are assigned to a window by their
+  ## timestamp.
+  # partition_interval = "1h"
+
+  ## Name of the time window directories, formatted from the start of the
+  ## window in UTC using the Go "reference time".
+  # partition_format = "2006-01-02T15"
+
+  ## The file of a partition is completed after the time interval specified.
+  ## When set to 0 no time based rotation is performed.  Files are always
+  ## completed when their time window ends and when Telegraf stops.
+  # rotation_interval = "0d"
+
+  ## The file of a partition is completed when it becomes larger than the
+  ## specified size.  When set to 0 no size based rotation is performed.
+  # rotation_max_size = "0MB"
+
+  ## The buffered metrics of a partition are written to its file as a row
+  ## group when they become larger than the specified size.
+  # row_group_size = "8MB"
+
+  ## Compression of the column data, one of "none", "snappy" or "gzip".
+  # compression = "snappy"
+`
+
+func (p *Parquet) SampleConfig() string {
+	return sampleConfig
+}
+
+func (p *Parquet) Description() string {
+	return "Write metrics to Parquet files partitioned by measurement and time"
+}
+
+func (p *Parquet) Connect() error {
+	if p.Directory == "" {
+		return fmt.Errorf("directory is required")
+	}
+	if p.PartitionInterval.Duration <= 0 {
+		return fmt.Errorf("partition_interval must be greater than zero")
+	}
+	if p.RowGroupSize.Size <= 0 {
+		p.RowGroupSize.Size = defaultRowGroupSize
+	}
+
+	codec, err := codecFor(p.Compression)
+	if err != nil {
+		return err
+	}
+	p.codec = codec
+
+	if err := os.MkdirAll(p.Directory, 0755); err != nil {
+		return err
+	}
+
+	p.partitions = make(map[partitionKey]*partition)
+	return nil
+}
+
+// Close writes all buffered metrics and completes the files.
+func (p *Parquet) Close() error {
+	var err error
+	for key, part := range p.partitions {
+		if errWrite := p.flush(part); errWrite != nil {
+			err = errWrite
+			continue
+		}
+		if errWrite := p.complete(part); errWrite != nil {
+			err = errWrite
+			continue
+		}
+		delete(p.partitions, key)
+	}
+	return err
+}
+
+// Write adds the metrics to their partitions, writes the partitions with a
+// full row group and completes the files due for rotation.  The metrics of a
+// partition that fails to be written are retried, the metrics of earlier
+// writes stay buffered.
+func (p *Parquet) Write(metrics []telegraf.Metric) error {
+	now := p.now()
+
+	// the number of buffered rows of each partition before this write and
+	// the partition of each metric.
+	buffered := make(map[*partition]int)
+	parts := make([]*partition, 0, len(metrics))
+	for _, m := range metrics {
+		part := p.partition(m, now)
+		if _, ok := buffered[part]; !ok {
+			buffered[part] = len(part.rows)
+		}
+		p.add(part, m)
+		parts = append(parts, part)
+	}
+
+	var lastErr error
+	failed := make(map[*partition]bool)
+	for key, part := range p.partitions {
+		due := p.rotationDue(part, now)
+		if due || part.size >= p.RowGroupSize.Size {
+			if err := p.flush(part); err != nil {
+				log.Printf("E! [outputs.parquet] Error writing %s: %v", part.measurement, err)
+				lastErr = err
+				failed[part] = true
+				part.rows = part.rows[:buffered[part]]
+				part.size = 0
+				for _, r := range part.rows {
+					part.size += rowSize(r)
+				}
+				continue
+			}
+		}
+
+		if !due {
+			continue
+		}
+		if err := p.complete(part); err != nil {
+			// the rows are written, completing the file is retried on the
+			// next write.
+			log.Printf("E! [outputs.parquet] Error completing %s: %v", part.filename, err)
+			lastErr = err
+			continue
+		}
+		delete(p.partitions, key)
+	}
+
+	if lastErr == nil {
+		return nil
+	}
+
+	perr := &internal.PartialWriteError{Err: lastErr}
+	for i, part := range parts {
+		if failed[part] {
+			perr.Retry = append(perr.Retry, i)
+		}
+	}
+	return perr
+}
+
+func (p *Parquet) partition(m telegraf.Metric, now time.Time) *partition {
+	window := m.Time().UTC().Truncate(p.PartitionInterval.Duration)
+	key := partitionKey{measurement: m.Name(), window: window.UnixNano()}
+
+	part, ok := p.partitions[key]
+	if !ok {
+		part = &partition{
+			measurement: m.Name(),
+			window:      window,
+			created:     now,
+		}
+		p.partitions[key] = part
+	}
+	return part
+}
+
+func (p *Parquet) add(part *partition, m telegraf.Metric) {
+	r := row{
+		time:   m.Time().UnixNano(),
+		tags:   m.Tags(),
+		fields: m.Fields(),
+	}
+	part.rows = append(part.rows, r)
+	part.size += rowSize(r)
+}
+
+func (p *Parquet) rotationDue(part *partition, now time.Time) bool {
+	if !now.Before(part.window.Add(p.PartitionInterval.Duration)) {
+		return true
+	}
+	if p.RotationInterval.Duration > 0 && !now.Before(part.created.Add(p.RotationInterval.Duration)) {
+		return true
+	}
+	if p.RotationMaxSize.Size > 0 && part.offset+part.size >= p.RotationMaxSize.Size {
+		return true
+	}
+	return false
+}
+
+// flush writes the buffered rows of the partition as a row group.  The
+// file is written under a temporary name and renamed once complete so
+// readers never see a partial file.  A file holds row groups of a single
+// schema, rows with other columns complete the file and start a new one.
+func (p *Parquet) flush(part *partition) error {
+	if len(part.rows) == 0 {
+		return nil
+	}
+
+	columns := buildColumns(part.rows)
+	if part.file != nil && !sameSchema(part.columns, columns) {
+		if err := p.complete(part); err != nil {
+			return err
+		}
+	}
+
+	if part.file == nil {
+		if err := p.create(part); err != nil {
+			return err
+		}
+		part.columns = columns
+	}
+
+	data, rg, err := encodeRowGroup(columns, len(part.rows), p.codec, part.offset)
+	if err != nil {
+		return err
+	}
+	if _, err := part.file.Write(data); err != nil {
+		// drop the partial row group, the rows are written again
+		part.file.Truncate(part.offset)
+		part.file.Seek(part.offset, io.SeekStart)
+		return err
+	}
+
+	part.offset += int64(len(data))
+	part.rowGroups = append(part.rowGroups, rg)
+	part.rows = nil
+	part.size = 0
+	return nil
+}
+
+func (p *Parquet) create(part *partition) error {
+	dir := filepath.Join(
+		p.Directory,
+		sanitize(part.measurement),
+		sanitize(part.window.Format(p.PartitionFormat)))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	// files created at the same instant get a sequence suffix
+	name := fmt.Sprintf("%s-%d", sanitize(part.measurement), p.now().UnixNano())
+	filename := filepath.Join(dir, name+".parquet")
+	for i := 1; ; i++ {
+		if _, err := os.Stat(filename); os.IsNotExist(err) {
+			break
+		}
+		filename = filepath.Join(dir, fmt.Sprintf("%s-%d.parquet", name, i))
+	}
+	file, err := os.OpenFile(filename+".tmp", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, FilePerm)
+	if err != nil {
+		return err
+	}
+	if _, err := file.WriteString(magic); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+
+	part.file = file
+	part.filename = filename
+	part.offset = int64(len(magic))
+	part.rowGroups = nil
+	return nil
+}
+
+// complete writes the footer of the file of the partition and renames it.
+func (p *Parquet) complete(part *partition) error {
+	if part.file == nil {
+		return nil
+	}
+
+	if _, err := part.file.Write(encodeTail(part.columns, part.rowGroups, p.codec)); err != nil {
+		part.file.Truncate(part.offset)
+		part.file.Seek(part.offset, io.SeekStart)
+		return err
+	}
+	if err := part.file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(part.file.Name(), part.filename); err != nil {
+		return err
+	}
+
+	part.file = nil
+	part.offset = 0
+	part.columns = nil
+	part.rowGroups = nil
+	return nil
+}
+
+// rowSize returns an estimate of the encoded size of a row.
+func rowSize(r row) int64 {
+	size := int64(8)
+	for _, v := range r.tags {
+		size += int64(len(v)) + 4
+	}
+	for _, v := range r.fields {
+		if s, ok := v.(string); ok {
+			size += int64(len(s)) + 4
+		} else {
+			size += 8
+		}
+	}
+	return size
+}
+
+// sanitize makes the name usable as a single path element.
+func sanitize(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == os.PathSeparator {
+			return '_'
+		}
+		return r
+	}, name)
+	if name == "" || name == "." || name == ".." {
+		return "_"
+	}
+	return name
+}
+
+func init() {
+	outputs.Add("parquet", func() telegraf.Output {
+		return &Parquet{
+			PartitionInterval: internal.Duration{Duration: time.Hour},
+			PartitionFormat:   "2006-01-02T15",
+			RowGroupSize:      internal.Size{Size: defaultRowGroupSize},
+			Compression:       "snappy",
+			now:               time.Now,
+		}
+	})
+}
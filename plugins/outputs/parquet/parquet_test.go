@@ -0,0 +1,502 @@
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func newParquet(t *testing.T, dir string) *Parquet {
+	p := &Parquet{
+		Directory:         dir,
+		PartitionInterval: internal.Duration{Duration: time.Hour},
+		PartitionFormat:   "2006-01-02T15",
+		Compression:       "snappy",
+		now: func() time.Time {
+			return time.Date(2019, 5, 2, 16, 30, 0, 0, time.UTC)
+		},
+	}
+	require.NoError(t, p.Connect())
+	return p
+}
+
+func cpu(host string, ts time.Time) telegraf.Metric {
+	return testutil.MustMetric(
+		"cpu",
+		map[string]string{"host": host},
+		map[string]interface{}{
+			"usage_idle": 98.5,
+			"count":      int64(42),
+			"ok":         true,
+			"state":      "running",
+		},
+		ts,
+	)
+}
+
+func parquetFiles(t *testing.T, dir string) []string {
+	files, err := filepath.Glob(filepath.Join(dir, "*", "*", "*.parquet"))
+	require.NoError(t, err)
+	return files
+}
+
+func requireParquet(t *testing.T, filename string) {
+	_, err := readParquet(filename)
+	require.NoError(t, err)
+}
+
+// thriftReader decodes structs of the Thrift compact protocol into maps by
+// field id.
+type thriftReader struct {
+	buf []byte
+	pos int
+}
+
+var errCorrupt = errors.New("corrupt thrift data")
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.buf[r.pos:])
+	if n <= 0 {
+		panic(errCorrupt)
+	}
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) varint() int64 {
+	v := r.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) byte() byte {
+	if r.pos >= len(r.buf) {
+		panic(errCorrupt)
+	}
+	r.pos++
+	return r.buf[r.pos-1]
+}
+
+func (r *thriftReader) value(typ byte) interface{} {
+	switch typ {
+	case compactBooleanTrue:
+		return true
+	case compactBooleanFalse:
+		return false
+	case compactI32, compactI64:
+		return r.varint()
+	case compactBinary:
+		n := int(r.uvarint())
+		if n > len(r.buf)-r.pos {
+			panic(errCorrupt)
+		}
+		r.pos += n
+		return string(r.buf[r.pos-n : r.pos])
+	case compactList:
+		header := r.byte()
+		size := int(header >> 4)
+		if size == 15 {
+			size = int(r.uvarint())
+		}
+		list := make([]interface{}, 0, size)
+		for i := 0; i < size; i++ {
+			list = append(list, r.value(header&0x0f))
+		}
+		return list
+	case compactStruct:
+		return r.structure()
+	default:
+		panic(errCorrupt)
+	}
+}
+
+func (r *thriftReader) structure() map[int16]interface{} {
+	fields := make(map[int16]interface{})
+	var id int16
+	for {
+		header := r.byte()
+		if header == 0 {
+			return fields
+		}
+		if delta := int16(header >> 4); delta != 0 {
+			id += delta
+		} else {
+			id = int16(r.varint())
+		}
+		fields[id] = r.value(header & 0x0f)
+	}
+}
+
+// readParquet reads the columns of the file by name, using the parts of the
+// format written by the output.
+func readParquet(filename string) (columns map[string][]interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = r.(error)
+		}
+	}()
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 12 || string(data[:4]) != magic || string(data[len(data)-4:]) != magic {
+		return nil, errors.New("missing magic")
+	}
+	footerLen := int(binary.LittleEndian.Uint32(data[len(data)-8 : len(data)-4]))
+	footer := &thriftReader{buf: data[len(data)-8-footerLen : len(data)-8]}
+	meta := footer.structure()
+	if footer.pos != footerLen {
+		return nil, errors.New("footer length mismatch")
+	}
+
+	schema := meta[2].([]interface{})
+	optional := make(map[string]bool)
+	for _, e := range schema[1:] {
+		e := e.(map[int16]interface{})
+		optional[e[4].(string)] = e[3].(int64) == repetitionOptional
+	}
+
+	columns = make(map[string][]interface{})
+	var numRows int64
+	for _, rg := range meta[4].([]interface{}) {
+		rg := rg.(map[int16]interface{})
+		numRows += rg[3].(int64)
+		for _, chunk := range rg[1].([]interface{}) {
+			cm := chunk.(map[int16]interface{})[3].(map[int16]interface{})
+			name := cm[3].([]interface{})[0].(string)
+
+			page := &thriftReader{buf: data, pos: int(cm[9].(int64))}
+			header := page.structure()
+			size := int(header[3].(int64))
+			body := data[page.pos : page.pos+size]
+			switch cm[4].(int64) {
+			case codecSnappy:
+				body, err = snappy.Decode(nil, body)
+			case codecGzip:
+				var zr *gzip.Reader
+				if zr, err = gzip.NewReader(bytes.NewReader(body)); err == nil {
+					body, err = ioutil.ReadAll(zr)
+				}
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			numValues := int(header[5].(map[int16]interface{})[1].(int64))
+			if int64(numValues) != rg[3].(int64) || cm[5].(int64) != rg[3].(int64) {
+				return nil, errors.New("value count mismatch")
+			}
+			columns[name] = append(columns[name], decodePage(body, cm[1].(int64), optional[name], numValues)...)
+		}
+	}
+	if numRows != meta[3].(int64) {
+		return nil, errors.New("row count mismatch")
+	}
+	return columns, nil
+}
+
+func decodePage(body []byte, typ int64, optional bool, numValues int) []interface{} {
+	present := make([]bool, numValues)
+	for i := range present {
+		present[i] = true
+	}
+	if optional {
+		n := int(binary.LittleEndian.Uint32(body))
+		levels := &thriftReader{buf: body[4 : 4+n]}
+		body = body[4+n:]
+		i := 0
+		for levels.pos < len(levels.buf) {
+			count := int(levels.uvarint() >> 1)
+			level := levels.byte()
+			for j := 0; j < count; j++ {
+				present[i] = level == 1
+				i++
+			}
+		}
+	}
+
+	values := make([]interface{}, 0, numValues)
+	bit := 0
+	for _, ok := range present {
+		if !ok {
+			values = append(values, nil)
+			continue
+		}
+		switch typ {
+		case typeBoolean:
+			values = append(values, body[bit/8]&(1<<uint(bit%8)) != 0)
+			bit++
+		case typeInt64:
+			values = append(values, int64(binary.LittleEndian.Uint64(body)))
+			body = body[8:]
+		case typeDouble:
+			values = append(values, math.Float64frombits(binary.LittleEndian.Uint64(body)))
+			body = body[8:]
+		case typeByteArray:
+			n := int(binary.LittleEndian.Uint32(body))
+			values = append(values, string(body[4:4+n]))
+			body = body[4+n:]
+		}
+	}
+	return values
+}
+
+func TestWritePartitionsOnClose(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parquet")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	p := newParquet(t, dir)
+	err = p.Write([]telegraf.Metric{
+		cpu("a", time.Date(2019, 5, 2, 16, 10, 0, 0, time.UTC)),
+		cpu("b", time.Date(2019, 5, 2, 16, 20, 0, 0, time.UTC)),
+		testutil.MustMetric(
+			"mem",
+			map[string]string{},
+			map[string]interface{}{"used": uint64(100)},
+			time.Date(2019, 5, 2, 16, 20, 0, 0, time.UTC),
+		),
+	})
+	require.NoError(t, err)
+	require.Len(t, parquetFiles(t, dir), 0)
+
+	require.NoError(t, p.Close())
+	files := parquetFiles(t, dir)
+	require.Len(t, files, 2)
+	for _, f := range files {
+		requireParquet(t, f)
+	}
+
+	_, err = os.Stat(filepath.Join(dir, "cpu", "2019-05-02T16"))
+	require.NoError(t, err)
+	_, err = os.Stat(filepath.Join(dir, "mem", "2019-05-02T16"))
+	require.NoError(t, err)
+}
+
+func TestWriteEndedWindow(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parquet")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	p := newParquet(t, dir)
+	err = p.Write([]telegraf.Metric{
+		cpu("a", time.Date(2019, 5, 2, 15, 10, 0, 0, time.UTC)),
+		cpu("a", time.Date(2019, 5, 2, 16, 10, 0, 0, time.UTC)),
+	})
+	require.NoError(t, err)
+
+	files := parquetFiles(t, dir)
+	require.Len(t, files, 1)
+	require.Equal(t, filepath.Join(dir, "cpu", "2019-05-02T15"), filepath.Dir(files[0]))
+	requireParquet(t, files[0])
+	require.Len(t, p.partitions, 1)
+}
+
+func TestRotationMaxSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parquet")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	p := newParquet(t, dir)
+	p.RotationMaxSize = internal.Size{Size: 40}
+
+	ts := time.Date(2019, 5, 2, 16, 10, 0, 0, time.UTC)
+	require.NoError(t, p.Write([]telegraf.Metric{cpu("a", ts)}))
+	require.Len(t, parquetFiles(t, dir), 1)
+	require.Len(t, p.partitions, 0)
+}
+
+func TestRotationInterval(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parquet")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	p := newParquet(t, dir)
+	p.RotationInterval = internal.Duration{Duration: 10 * time.Minute}
+
+	ts := time.Date(2019, 5, 2, 16, 10, 0, 0, time.UTC)
+	require.NoError(t, p.Write([]telegraf.Metric{cpu("a", ts)}))
+	require.Len(t, parquetFiles(t, dir), 0)
+
+	p.now = func() time.Time {
+		return time.Date(2019, 5, 2, 16, 40, 0, 0, time.UTC)
+	}
+	require.NoError(t, p.Write([]telegraf.Metric{cpu("b", ts)}))
+	require.Len(t, parquetFiles(t, dir), 1)
+}
+
+func TestResolveType(t *testing.T) {
+	tests := []struct {
+		name      string
+		values    []interface{}
+		typ       int32
+		converted int32
+		expected  []interface{}
+	}{
+		{
+			name:      "int",
+			values:    []interface{}{int64(1), nil},
+			typ:       typeInt64,
+			converted: -1,
+			expected:  []interface{}{int64(1), nil},
+		},
+		{
+			name:      "uint",
+			values:    []interface{}{uint64(1)},
+			typ:       typeInt64,
+			converted: convertedUint64,
+			expected:  []interface{}{uint64(1)},
+		},
+		{
+			name:      "mixed numeric",
+			values:    []interface{}{int64(1), uint64(2), 3.5},
+			typ:       typeDouble,
+			converted: -1,
+			expected:  []interface{}{1.0, 2.0, 3.5},
+		},
+		{
+			name:      "mixed types",
+			values:    []interface{}{int64(1), true, "a"},
+			typ:       typeByteArray,
+			converted: convertedUTF8,
+			expected:  []interface{}{"1", "true", "a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &column{values: tt.values, converted: -1}
+			resolveType(c)
+			require.Equal(t, tt.typ, c.typ)
+			require.Equal(t, tt.converted, c.converted)
+			require.Equal(t, tt.expected, c.values)
+		})
+	}
+}
+
+func TestEncodeLevels(t *testing.T) {
+	levels := encodeLevels([]interface{}{1.0, 2.0, nil, 3.0})
+	require.Equal(t, []byte{4, 1, 2, 0, 2, 1}, levels)
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, compression := range []string{"none", "snappy", "gzip"} {
+		t.Run(compression, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "parquet")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			p := newParquet(t, dir)
+			p.Compression = compression
+			require.NoError(t, p.Connect())
+			// a row group per write
+			p.RowGroupSize = internal.Size{Size: 1}
+
+			ts := time.Date(2019, 5, 2, 16, 10, 0, 0, time.UTC)
+			require.NoError(t, p.Write([]telegraf.Metric{
+				testutil.MustMetric("cpu",
+					map[string]string{"host": "a", "time": "t"},
+					map[string]interface{}{"host": "f", "time": int64(1), "value": 1.5, "ok": true},
+					ts),
+				testutil.MustMetric("cpu",
+					map[string]string{"host": "b"},
+					map[string]interface{}{"host": "g", "time": int64(2), "value": 2.5, "ok": false},
+					ts.Add(time.Second)),
+			}))
+			require.NoError(t, p.Write([]telegraf.Metric{
+				testutil.MustMetric("cpu",
+					map[string]string{"host": "c", "time": "u"},
+					map[string]interface{}{"host": "h", "time": int64(3), "value": 3.5, "ok": true},
+					ts.Add(2*time.Second)),
+			}))
+			// the file is completed on close
+			require.Len(t, parquetFiles(t, dir), 0)
+			require.NoError(t, p.Close())
+
+			files := parquetFiles(t, dir)
+			require.Len(t, files, 1)
+			columns, err := readParquet(files[0])
+			require.NoError(t, err)
+			require.Equal(t, map[string][]interface{}{
+				"time":       {ts.UnixNano(), ts.Add(time.Second).UnixNano(), ts.Add(2 * time.Second).UnixNano()},
+				"host":       {"a", "b", "c"},
+				"time_tag":   {"t", nil, "u"},
+				"host_field": {"f", "g", "h"},
+				"time_field": {int64(1), int64(2), int64(3)},
+				"value":      {1.5, 2.5, 3.5},
+				"ok":         {true, false, true},
+			}, columns)
+		})
+	}
+}
+
+func TestRowGroupSchemaChange(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parquet")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	p := newParquet(t, dir)
+	p.RowGroupSize = internal.Size{Size: 1}
+
+	ts := time.Date(2019, 5, 2, 16, 10, 0, 0, time.UTC)
+	require.NoError(t, p.Write([]telegraf.Metric{cpu("a", ts)}))
+	require.NoError(t, p.Write([]telegraf.Metric{cpu("b", ts)}))
+	// a row group with other columns starts a new file
+	require.NoError(t, p.Write([]telegraf.Metric{
+		testutil.MustMetric("cpu", map[string]string{},
+			map[string]interface{}{"usage_idle": 1.0}, ts),
+	}))
+	require.Len(t, parquetFiles(t, dir), 1)
+	require.NoError(t, p.Close())
+
+	files := parquetFiles(t, dir)
+	require.Len(t, files, 2)
+	var hosts [][]interface{}
+	for _, f := range files {
+		columns, err := readParquet(f)
+		require.NoError(t, err)
+		hosts = append(hosts, columns["host"])
+	}
+	sort.Slice(hosts, func(i, j int) bool { return len(hosts[i]) < len(hosts[j]) })
+	require.Equal(t, [][]interface{}{nil, {"a", "b"}}, hosts)
+}
+
+func TestWriteErrorRetries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parquet")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	p := newParquet(t, dir)
+	p.RowGroupSize = internal.Size{Size: 1}
+
+	// the measurement directory cannot be created
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "mem"), nil, FilePerm))
+
+	ts := time.Date(2019, 5, 2, 16, 10, 0, 0, time.UTC)
+	err = p.Write([]telegraf.Metric{
+		cpu("a", ts),
+		testutil.MustMetric("mem", map[string]string{},
+			map[string]interface{}{"used": int64(1)}, ts),
+	})
+	require.Error(t, err)
+	perr, ok := err.(*internal.PartialWriteError)
+	require.True(t, ok)
+	require.Equal(t, []int{1}, perr.Retry)
+
+	for _, part := range p.partitions {
+		require.Empty(t, part.rows)
+	}
+}
@@ -0,0 +1,106 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Thrift compact protocol types used by the Parquet metadata.
+const (
+	compactBooleanTrue  = 1
+	compactBooleanFalse = 2
+	compactI32          = 5
+	compactI64          = 6
+	compactBinary       = 8
+	compactList         = 9
+	compactStruct       = 12
+)
+
+// thriftWriter encodes structs using the Thrift compact protocol, which is
+// the encoding of the Parquet page headers and file footer.
+type thriftWriter struct {
+	buf bytes.Buffer
+
+	// lastID is the id of the last field written in each open struct.
+	lastID []int16
+}
+
+func (w *thriftWriter) Bytes() []byte {
+	return w.buf.Bytes()
+}
+
+func (w *thriftWriter) structBegin() {
+	w.lastID = append(w.lastID, 0)
+}
+
+func (w *thriftWriter) structEnd() {
+	w.buf.WriteByte(0)
+	w.lastID = w.lastID[:len(w.lastID)-1]
+}
+
+func (w *thriftWriter) fieldHeader(id int16, typ byte) {
+	last := &w.lastID[len(w.lastID)-1]
+	delta := id - *last
+	if delta > 0 && delta <= 15 {
+		w.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		w.buf.WriteByte(typ)
+		w.varint(int64(id))
+	}
+	*last = id
+}
+
+func (w *thriftWriter) varint(v int64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(b[:], v)
+	w.buf.Write(b[:n])
+}
+
+func (w *thriftWriter) uvarint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+	w.buf.Write(b[:n])
+}
+
+func (w *thriftWriter) fieldBool(id int16, v bool) {
+	if v {
+		w.fieldHeader(id, compactBooleanTrue)
+	} else {
+		w.fieldHeader(id, compactBooleanFalse)
+	}
+}
+
+func (w *thriftWriter) fieldI32(id int16, v int32) {
+	w.fieldHeader(id, compactI32)
+	w.varint(int64(v))
+}
+
+func (w *thriftWriter) fieldI64(id int16, v int64) {
+	w.fieldHeader(id, compactI64)
+	w.varint(v)
+}
+
+func (w *thriftWriter) fieldString(id int16, v string) {
+	w.fieldHeader(id, compactBinary)
+	w.binary(v)
+}
+
+func (w *thriftWriter) binary(v string) {
+	w.uvarint(uint64(len(v)))
+	w.buf.WriteString(v)
+}
+
+func (w *thriftWriter) fieldStruct(id int16) {
+	w.fieldHeader(id, compactStruct)
+	w.structBegin()
+}
+
+func (w *thriftWriter) fieldList(id int16, elemType byte, size int) {
+	w.fieldHeader(id, compactList)
+	if size < 15 {
+		w.buf.WriteByte(byte(size)<<4 | elemType)
+	} else {
+		w.buf.WriteByte(0xf0 | elemType)
+		w.uvarint(uint64(size))
+	}
+}
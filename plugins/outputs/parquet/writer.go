@@ -0,0 +1,460 @@
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/golang/snappy"
+)
+
+// Parquet physical types.
+const (
+	typeBoolean   = 0
+	typeInt64     = 2
+	typeDouble    = 5
+	typeByteArray = 6
+)
+
+// Parquet converted types.
+const (
+	convertedUTF8   = 0
+	convertedUint64 = 14
+)
+
+// Parquet field repetition types.
+const (
+	repetitionRequired = 0
+	repetitionOptional = 1
+)
+
+// Parquet encodings.
+const (
+	encodingPlain = 0
+	encodingRLE   = 3
+)
+
+// Parquet compression codecs.
+const (
+	codecUncompressed = 0
+	codecSnappy       = 1
+	codecGzip         = 2
+)
+
+const (
+	magic     = "PAR1"
+	createdBy = "telegraf"
+	timeKey   = "time"
+)
+
+// row is a single metric of a partition.
+type row struct {
+	time   int64
+	tags   map[string]string
+	fields map[string]interface{}
+}
+
+// column is a leaf column of the file schema with its values, a nil value is
+// written as null.
+type column struct {
+	name      string
+	typ       int32
+	converted int32
+	optional  bool
+	values    []interface{}
+}
+
+func codecFor(compression string) (int32, error) {
+	switch compression {
+	case "", "none":
+		return codecUncompressed, nil
+	case "snappy":
+		return codecSnappy, nil
+	case "gzip":
+		return codecGzip, nil
+	default:
+		return 0, fmt.Errorf("unsupported compression: %s", compression)
+	}
+}
+
+// buildColumns creates the schema columns of a partition: the time, a column
+// per tag key and a column per field key.  A tag named "time" is renamed with
+// a "_tag" suffix, fields with the same key as a tag or named "time" with a
+// "_field" suffix.
+func buildColumns(rows []row) []*column {
+	tagKeys := make(map[string]bool)
+	fieldKeys := make(map[string]bool)
+	for _, r := range rows {
+		for k := range r.tags {
+			tagKeys[k] = true
+		}
+		for k := range r.fields {
+			fieldKeys[k] = true
+		}
+	}
+
+	times := make([]interface{}, 0, len(rows))
+	for _, r := range rows {
+		times = append(times, r.time)
+	}
+	columns := []*column{{name: timeKey, typ: typeInt64, values: times}}
+
+	for _, key := range sortedKeys(tagKeys) {
+		name := key
+		if key == timeKey {
+			name = key + "_tag"
+		}
+		c := &column{name: name, typ: typeByteArray, converted: convertedUTF8, optional: true}
+		for _, r := range rows {
+			if v, ok := r.tags[key]; ok {
+				c.values = append(c.values, v)
+			} else {
+				c.values = append(c.values, nil)
+			}
+		}
+		columns = append(columns, c)
+	}
+
+	for _, key := range sortedKeys(fieldKeys) {
+		name := key
+		if tagKeys[key] || key == timeKey {
+			name = key + "_field"
+		}
+		c := &column{name: name, optional: true, converted: -1}
+		for _, r := range rows {
+			c.values = append(c.values, r.fields[key])
+		}
+		resolveType(c)
+		columns = append(columns, c)
+	}
+	return columns
+}
+
+// resolveType sets the column type from the values.  Mixed numeric values are
+// written as doubles, any other mix of types is written as strings.
+func resolveType(c *column) {
+	kinds := make(map[string]bool)
+	for _, v := range c.values {
+		switch v.(type) {
+		case int64:
+			kinds["int"] = true
+		case uint64:
+			kinds["uint"] = true
+		case float64:
+			kinds["float"] = true
+		case bool:
+			kinds["bool"] = true
+		case string:
+			kinds["string"] = true
+		}
+	}
+
+	switch {
+	case len(kinds) == 1 && kinds["int"]:
+		c.typ = typeInt64
+	case len(kinds) == 1 && kinds["uint"]:
+		c.typ = typeInt64
+		c.converted = convertedUint64
+	case len(kinds) == 1 && kinds["bool"]:
+		c.typ = typeBoolean
+	case !kinds["bool"] && !kinds["string"]:
+		c.typ = typeDouble
+		for i, v := range c.values {
+			switch v := v.(type) {
+			case int64:
+				c.values[i] = float64(v)
+			case uint64:
+				c.values[i] = float64(v)
+			}
+		}
+	default:
+		c.typ = typeByteArray
+		c.converted = convertedUTF8
+		for i, v := range c.values {
+			switch v := v.(type) {
+			case int64:
+				c.values[i] = strconv.FormatInt(v, 10)
+			case uint64:
+				c.values[i] = strconv.FormatUint(v, 10)
+			case float64:
+				c.values[i] = strconv.FormatFloat(v, 'f', -1, 64)
+			case bool:
+				c.values[i] = strconv.FormatBool(v)
+			}
+		}
+	}
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type chunkMeta struct {
+	column           *column
+	offset           int64
+	uncompressedSize int64
+	compressedSize   int64
+}
+
+// rowGroup is the metadata of a row group written to a file.
+type rowGroup struct {
+	chunks    []chunkMeta
+	numRows   int
+	totalSize int64
+}
+
+// sameSchema returns true if the row groups of the columns can be written to
+// the same file.
+func sameSchema(a, b []*column) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].name != b[i].name || a[i].typ != b[i].typ ||
+			a[i].converted != b[i].converted || a[i].optional != b[i].optional {
+			return false
+		}
+	}
+	return true
+}
+
+// encodeRowGroup returns the column chunks of the rows, starting at offset
+// in the file.
+func encodeRowGroup(columns []*column, numRows int, codec int32, offset int64) ([]byte, rowGroup, error) {
+	var buf bytes.Buffer
+	rg := rowGroup{numRows: numRows}
+	for _, c := range columns {
+		body := encodePage(c)
+		compressed, err := compress(body, codec)
+		if err != nil {
+			return nil, rg, err
+		}
+
+		header := encodePageHeader(numRows, len(body), len(compressed))
+
+		chunk := chunkMeta{
+			column:           c,
+			offset:           offset + int64(buf.Len()),
+			uncompressedSize: int64(len(header) + len(body)),
+			compressedSize:   int64(len(header) + len(compressed)),
+		}
+		buf.Write(header)
+		buf.Write(compressed)
+
+		rg.chunks = append(rg.chunks, chunk)
+		rg.totalSize += chunk.uncompressedSize
+	}
+	return buf.Bytes(), rg, nil
+}
+
+// encodeTail returns the end of a file with the row groups: the footer, its
+// length and the magic.
+func encodeTail(columns []*column, rowGroups []rowGroup, codec int32) []byte {
+	footer := encodeFooter(columns, rowGroups, codec)
+
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(len(footer)))
+	return append(append(footer, length[:]...), magic...)
+}
+
+func compress(data []byte, codec int32) ([]byte, error) {
+	switch codec {
+	case codecSnappy:
+		return snappy.Encode(nil, data), nil
+	case codecGzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return data, nil
+	}
+}
+
+// encodePage returns the data page content: the definition levels of
+// optional columns followed by the plain encoded non-null values.
+func encodePage(c *column) []byte {
+	var buf bytes.Buffer
+
+	if c.optional {
+		levels := encodeLevels(c.values)
+		var length [4]byte
+		binary.LittleEndian.PutUint32(length[:], uint32(len(levels)))
+		buf.Write(length[:])
+		buf.Write(levels)
+	}
+
+	var b [8]byte
+	var bits byte
+	var nbits uint
+	for _, v := range c.values {
+		switch v := v.(type) {
+		case int64:
+			binary.LittleEndian.PutUint64(b[:], uint64(v))
+			buf.Write(b[:])
+		case uint64:
+			binary.LittleEndian.PutUint64(b[:], v)
+			buf.Write(b[:])
+		case float64:
+			binary.LittleEndian.PutUint64(b[:], math.Float64bits(v))
+			buf.Write(b[:])
+		case string:
+			binary.LittleEndian.PutUint32(b[:4], uint32(len(v)))
+			buf.Write(b[:4])
+			buf.WriteString(v)
+		case bool:
+			if v {
+				bits |= 1 << nbits
+			}
+			nbits++
+			if nbits == 8 {
+				buf.WriteByte(bits)
+				bits, nbits = 0, 0
+			}
+		}
+	}
+	if nbits > 0 {
+		buf.WriteByte(bits)
+	}
+	return buf.Bytes()
+}
+
+// encodeLevels returns the definition levels of the values using runs of the
+// RLE/bit-packing hybrid encoding with a bit width of one.
+func encodeLevels(values []interface{}) []byte {
+	var buf bytes.Buffer
+	var b [binary.MaxVarintLen64]byte
+
+	writeRun := func(count int, level byte) {
+		n := binary.PutUvarint(b[:], uint64(count)<<1)
+		buf.Write(b[:n])
+		buf.WriteByte(level)
+	}
+
+	var count int
+	var level byte
+	for i, v := range values {
+		var l byte
+		if v != nil {
+			l = 1
+		}
+		if i > 0 && l != level {
+			writeRun(count, level)
+			count = 0
+		}
+		level = l
+		count++
+	}
+	if count > 0 {
+		writeRun(count, level)
+	}
+	return buf.Bytes()
+}
+
+func encodePageHeader(numValues, uncompressedSize, compressedSize int) []byte {
+	w := &thriftWriter{}
+	w.structBegin()
+	w.fieldI32(1, 0) // DATA_PAGE
+	w.fieldI32(2, int32(uncompressedSize))
+	w.fieldI32(3, int32(compressedSize))
+	w.fieldStruct(5)
+	w.fieldI32(1, int32(numValues))
+	w.fieldI32(2, encodingPlain)
+	w.fieldI32(3, encodingRLE)
+	w.fieldI32(4, encodingRLE)
+	w.structEnd()
+	w.structEnd()
+	return w.Bytes()
+}
+
+func encodeFooter(columns []*column, rowGroups []rowGroup, codec int32) []byte {
+	var numRows int64
+	for _, rg := range rowGroups {
+		numRows += int64(rg.numRows)
+	}
+
+	w := &thriftWriter{}
+	w.structBegin()
+	w.fieldI32(1, 1)
+
+	// schema, the root element followed by the leaf columns
+	w.fieldList(2, compactStruct, len(columns)+1)
+	w.structBegin()
+	w.fieldString(4, "schema")
+	w.fieldI32(5, int32(len(columns)))
+	w.structEnd()
+	for _, c := range columns {
+		w.structBegin()
+		w.fieldI32(1, c.typ)
+		if c.optional {
+			w.fieldI32(3, repetitionOptional)
+		} else {
+			w.fieldI32(3, repetitionRequired)
+		}
+		w.fieldString(4, c.name)
+		if c.typ == typeByteArray || c.converted == convertedUint64 {
+			w.fieldI32(6, c.converted)
+		}
+		if c.name == timeKey && !c.optional {
+			// TIMESTAMP(isAdjustedToUTC=true, unit=NANOS)
+			w.fieldStruct(10)
+			w.fieldStruct(8)
+			w.fieldBool(1, true)
+			w.fieldStruct(2)
+			w.fieldStruct(3)
+			w.structEnd()
+			w.structEnd()
+			w.structEnd()
+			w.structEnd()
+		}
+		w.structEnd()
+	}
+
+	w.fieldI64(3, numRows)
+
+	w.fieldList(4, compactStruct, len(rowGroups))
+	for _, rg := range rowGroups {
+		w.structBegin()
+		w.fieldList(1, compactStruct, len(rg.chunks))
+		for _, chunk := range rg.chunks {
+			c := chunk.column
+			w.structBegin()
+			w.fieldI64(2, chunk.offset)
+			w.fieldStruct(3)
+			w.fieldI32(1, c.typ)
+			w.fieldList(2, compactI32, 2)
+			w.varint(encodingPlain)
+			w.varint(encodingRLE)
+			w.fieldList(3, compactBinary, 1)
+			w.binary(c.name)
+			w.fieldI32(4, codec)
+			w.fieldI64(5, int64(rg.numRows))
+			w.fieldI64(6, chunk.uncompressedSize)
+			w.fieldI64(7, chunk.compressedSize)
+			w.fieldI64(9, chunk.offset)
+			w.structEnd()
+			w.structEnd()
+		}
+		w.fieldI64(2, rg.totalSize)
+		w.fieldI64(3, int64(rg.numRows))
+		w.structEnd()
+	}
+
+	w.fieldString(6, createdBy)
+	w.structEnd()
+	return w.Bytes()
+}
@@ -17,6 +17,7 @@ import (
 	"time"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/filter"
 	"github.com/influxdata/telegraf/internal"
 	tlsint "github.com/influxdata/telegraf/internal/tls"
 	"github.com/influxdata/telegraf/plugins/outputs"
@@ -24,6 +25,14 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+const (
+	// bucketTag and bucketSuffix identify the buckets of the histogram
+	// aggregator.
+	bucketTag    = "le"
+	bucketInf    = "+Inf"
+	bucketSuffix = "_bucket"
+)
+
 var (
 	invalidNameCharRE = regexp.MustCompile(`[^a-zA-Z0-9_:]`)
 	validNameCharRE   = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*`)
@@ -70,6 +79,8 @@ type PrometheusClient struct {
 	CollectorsExclude  []string          `toml:"collectors_exclude"`
 	StringAsLabel      bool              `toml:"string_as_label"`
 	ExportTimestamp    bool              `toml:"export_timestamp"`
+	BucketHistograms   bool              `toml:"bucket_histograms"`
+	Endpoints          []*Endpoint       `toml:"endpoint"`
 
 	tlsint.ServerConfig
 
@@ -119,8 +130,75 @@ var sampleConfig = `
 
   ## Export metric collection time.
   # export_timestamp = false
+
+  ## Expose the buckets of the histogram aggregator, metrics tagged with "le"
+  ## with "_bucket" fields, as Prometheus histograms instead of gauges.
+  # bucket_histograms = false
+
+  ## Additional endpoints on the same listener, each publishing the metrics
+  ## selected by name and label filters.  namepass and namedrop match the
+  ## Prometheus metric names.
+  # [[outputs.prometheus_client.endpoint]]
+  #   path = "/metrics/cpu"
+  #   namepass = ["cpu_*"]
+  #   namedrop = []
+  #   [outputs.prometheus_client.endpoint.tagpass]
+  #     cpu = ["cpu-total"]
 `
 
+// Endpoint is an additional path publishing a filtered subset of the
+// metrics.
+type Endpoint struct {
+	Path     string              `toml:"path"`
+	NamePass []string            `toml:"namepass"`
+	NameDrop []string            `toml:"namedrop"`
+	TagPass  map[string][]string `toml:"tagpass"`
+
+	nameFilter filter.Filter
+	tagFilters map[string]filter.Filter
+}
+
+func (e *Endpoint) compile() error {
+	var err error
+	e.nameFilter, err = filter.NewIncludeExcludeFilter(e.NamePass, e.NameDrop)
+	if err != nil {
+		return fmt.Errorf("invalid name filter of endpoint %s: %v", e.Path, err)
+	}
+
+	e.tagFilters = make(map[string]filter.Filter, len(e.TagPass))
+	for key, values := range e.TagPass {
+		e.tagFilters[key], err = filter.Compile(values)
+		if err != nil {
+			return fmt.Errorf("invalid tag filter of endpoint %s: %v", e.Path, err)
+		}
+	}
+	return nil
+}
+
+func (e *Endpoint) selectSample(sample *Sample) bool {
+	for key, f := range e.tagFilters {
+		value, ok := sample.Labels[key]
+		if !ok || !f.Match(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// endpointCollector is the prometheus.Collector of an Endpoint.
+type endpointCollector struct {
+	client   *PrometheusClient
+	endpoint *Endpoint
+}
+
+func (c *endpointCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.client.Describe(ch)
+}
+
+func (c *endpointCollector) Collect(ch chan<- prometheus.Metric) {
+	c.client.collect(ch, c.endpoint)
+}
+
 func (p *PrometheusClient) auth(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if p.BasicUsername != "" && p.BasicPassword != "" {
@@ -198,6 +276,28 @@ func (p *PrometheusClient) Connect() error {
 	mux.Handle(p.Path, p.auth(promhttp.HandlerFor(
 		registry, promhttp.HandlerOpts{ErrorHandling: promhttp.ContinueOnError})))
 
+	paths := map[string]bool{p.Path: true}
+	for _, endpoint := range p.Endpoints {
+		if endpoint.Path == "" {
+			return fmt.Errorf("endpoint path must be set")
+		}
+		if paths[endpoint.Path] {
+			return fmt.Errorf("endpoint path %s is already used", endpoint.Path)
+		}
+		paths[endpoint.Path] = true
+		if err := endpoint.compile(); err != nil {
+			return err
+		}
+
+		registry := prometheus.NewRegistry()
+		err := registry.Register(&endpointCollector{client: p, endpoint: endpoint})
+		if err != nil {
+			return err
+		}
+		mux.Handle(endpoint.Path, p.auth(promhttp.HandlerFor(
+			registry, promhttp.HandlerOpts{ErrorHandling: promhttp.ContinueOnError})))
+	}
+
 	tlsConfig, err := p.TLSConfig()
 	if err != nil {
 		return err
@@ -293,12 +393,22 @@ func (p *PrometheusClient) Expire() {
 
 // Collect implements prometheus.Collector
 func (p *PrometheusClient) Collect(ch chan<- prometheus.Metric) {
+	p.collect(ch, nil)
+}
+
+// collect sends the metrics selected by the endpoint, or all metrics when
+// the endpoint is nil.
+func (p *PrometheusClient) collect(ch chan<- prometheus.Metric, endpoint *Endpoint) {
 	p.Lock()
 	defer p.Unlock()
 
 	p.Expire()
 
 	for name, family := range p.fam {
+		if endpoint != nil && !endpoint.nameFilter.Match(name) {
+			continue
+		}
+
 		// Get list of all labels on MetricFamily
 		var labelNames []string
 		for k, v := range family.LabelSet {
@@ -309,6 +419,10 @@ func (p *PrometheusClient) Collect(ch chan<- prometheus.Metric) {
 		desc := prometheus.NewDesc(name, "Telegraf collected metric", labelNames, nil)
 
 		for _, sample := range family.Samples {
+			if endpoint != nil && !endpoint.selectSample(sample) {
+				continue
+			}
+
 			// Get labels for this sample; unset labels will be set to the
 			// empty string
 			var labels []string
@@ -381,18 +495,82 @@ func addSample(fam *MetricFamily, sample *Sample, sampleID SampleID) {
 }
 
 func (p *PrometheusClient) addMetricFamily(point telegraf.Metric, sample *Sample, mname string, sampleID SampleID) {
-	var fam *MetricFamily
-	var ok bool
-	if fam, ok = p.fam[mname]; !ok {
+	addSample(p.getMetricFamily(mname, point.Type()), sample, sampleID)
+}
+
+func (p *PrometheusClient) getMetricFamily(mname string, valueType telegraf.ValueType) *MetricFamily {
+	fam, ok := p.fam[mname]
+	if !ok {
 		fam = &MetricFamily{
 			Samples:           make(map[SampleID]*Sample),
-			TelegrafValueType: point.Type(),
+			TelegrafValueType: valueType,
 			LabelSet:          make(map[string]int),
 		}
 		p.fam[mname] = fam
 	}
+	return fam
+}
+
+// addBuckets adds the "_bucket" fields of a metric from the histogram
+// aggregator to the histogram of the field.  Each metric holds the
+// cumulative count of one bucket, with the upper bound in the "le" tag; the
+// "+Inf" bucket is the count of the histogram.  The aggregator has no sum
+// of the values, so the sum is always zero.
+func (p *PrometheusClient) addBuckets(point telegraf.Metric, le string, labels map[string]string, now time.Time) {
+	var bound float64
+	if le != bucketInf {
+		var err error
+		bound, err = strconv.ParseFloat(le, 64)
+		if err != nil {
+			return
+		}
+	}
+
+	tags := point.Tags()
+	delete(tags, bucketTag)
+	delete(labels, bucketTag)
+	sampleID := CreateSampleID(tags)
+
+	for fn, fv := range point.Fields() {
+		if !strings.HasSuffix(fn, bucketSuffix) {
+			continue
+		}
 
-	addSample(fam, sample, sampleID)
+		var count uint64
+		switch fv := fv.(type) {
+		case int64:
+			count = uint64(fv)
+		case uint64:
+			count = fv
+		case float64:
+			count = uint64(fv)
+		default:
+			continue
+		}
+
+		mname := sanitize(fmt.Sprintf("%s_%s", point.Name(), strings.TrimSuffix(fn, bucketSuffix)))
+		if !isValidTagName(mname) {
+			continue
+		}
+
+		fam := p.getMetricFamily(mname, telegraf.Histogram)
+		sample, ok := fam.Samples[sampleID]
+		if !ok || sample.HistogramValue == nil {
+			sample = &Sample{
+				Labels:         labels,
+				HistogramValue: make(map[float64]uint64),
+			}
+			addSample(fam, sample, sampleID)
+		}
+
+		if le == bucketInf {
+			sample.Count = count
+		} else {
+			sample.HistogramValue[bound] = count
+		}
+		sample.Timestamp = point.Time()
+		sample.Expiration = now.Add(p.ExpirationInterval.Duration)
+	}
 }
 
 // Sorted returns a copy of the metrics in time ascending order.  A copy is
@@ -443,6 +621,13 @@ func (p *PrometheusClient) Write(metrics []telegraf.Metric) error {
 			}
 		}
 
+		if p.BucketHistograms {
+			if le, ok := tags[bucketTag]; ok && point.Type() != telegraf.Histogram && point.Type() != telegraf.Summary {
+				p.addBuckets(point, le, labels, now)
+				continue
+			}
+		}
+
 		switch point.Type() {
 		case telegraf.Summary:
 			var mname string
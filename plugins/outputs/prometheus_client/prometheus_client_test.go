@@ -1,6 +1,9 @@
 package prometheus_client
 
 import (
+	"io/ioutil"
+	"net/http"
+	"strings"
 	"testing"
 	"time"
 
@@ -691,3 +694,105 @@ func setupPrometheus() (*PrometheusClient, *prometheus_input.Prometheus, error)
 
 	return pTesting, p, nil
 }
+
+func connectClient(t *testing.T, client *PrometheusClient) {
+	client.Listen = "127.0.0.1:0"
+	client.CollectorsExclude = []string{"gocollector", "process"}
+	require.NoError(t, client.Connect())
+}
+
+func getBody(t *testing.T, url string) string {
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestBucketHistograms(t *testing.T) {
+	client := NewClient()
+	client.BucketHistograms = true
+	connectClient(t, client)
+	defer client.Close()
+
+	now := time.Unix(0, 0)
+	metrics := []telegraf.Metric{
+		testutil.MustMetric("cpu",
+			map[string]string{"cpu": "cpu0", "le": "10"},
+			map[string]interface{}{"usage_idle_bucket": int64(1)}, now),
+		testutil.MustMetric("cpu",
+			map[string]string{"cpu": "cpu0", "le": "+Inf"},
+			map[string]interface{}{"usage_idle_bucket": int64(3)}, now),
+		testutil.MustMetric("cpu",
+			map[string]string{"cpu": "cpu0", "le": "20"},
+			map[string]interface{}{"usage_idle_bucket": int64(2)}, now),
+	}
+	require.NoError(t, client.Write(metrics))
+
+	require.Equal(t, `# HELP cpu_usage_idle Telegraf collected metric
+# TYPE cpu_usage_idle histogram
+cpu_usage_idle_bucket{cpu="cpu0",le="10"} 1
+cpu_usage_idle_bucket{cpu="cpu0",le="20"} 2
+cpu_usage_idle_bucket{cpu="cpu0",le="+Inf"} 3
+cpu_usage_idle_sum{cpu="cpu0"} 0
+cpu_usage_idle_count{cpu="cpu0"} 3
+`, getBody(t, client.URL()))
+
+	// without the option the buckets are gauges
+	client = NewClient()
+	require.NoError(t, client.Write(metrics[:1]))
+	fam, ok := client.fam["cpu_usage_idle_bucket"]
+	require.True(t, ok)
+	require.Equal(t, map[string]int{"cpu": 1, "le": 1}, fam.LabelSet)
+}
+
+func TestEndpoints(t *testing.T) {
+	client := NewClient()
+	client.Endpoints = []*Endpoint{
+		{Path: "/metrics/cpu", NamePass: []string{"cpu_*"}},
+		{Path: "/metrics/host_a", TagPass: map[string][]string{"host": {"a"}}},
+	}
+	connectClient(t, client)
+	defer client.Close()
+
+	now := time.Unix(0, 0)
+	require.NoError(t, client.Write([]telegraf.Metric{
+		testutil.MustMetric("cpu", map[string]string{"host": "a"},
+			map[string]interface{}{"usage_idle": 42.0}, now, telegraf.Gauge),
+		testutil.MustMetric("mem", map[string]string{"host": "a"},
+			map[string]interface{}{"free": 1.0}, now, telegraf.Gauge),
+		testutil.MustMetric("mem", map[string]string{"host": "b"},
+			map[string]interface{}{"free": 2.0}, now, telegraf.Gauge),
+	}))
+
+	base := strings.TrimSuffix(client.URL(), "/metrics")
+	require.Equal(t, `# HELP cpu_usage_idle Telegraf collected metric
+# TYPE cpu_usage_idle gauge
+cpu_usage_idle{host="a"} 42
+`, getBody(t, base+"/metrics/cpu"))
+
+	require.Equal(t, `# HELP cpu_usage_idle Telegraf collected metric
+# TYPE cpu_usage_idle gauge
+cpu_usage_idle{host="a"} 42
+# HELP mem_free Telegraf collected metric
+# TYPE mem_free gauge
+mem_free{host="a"} 1
+`, getBody(t, base+"/metrics/host_a"))
+
+	require.Contains(t, getBody(t, client.URL()), `mem_free{host="b"} 2`)
+}
+
+func TestEndpointInvalidPath(t *testing.T) {
+	client := NewClient()
+	client.Endpoints = []*Endpoint{{Path: "/metrics"}}
+	client.Listen = "127.0.0.1:0"
+	require.Error(t, client.Connect())
+
+	client = NewClient()
+	client.Endpoints = []*Endpoint{{Path: "/metrics/cpu"}, {Path: "/metrics/cpu"}}
+	client.Listen = "127.0.0.1:0"
+	require.Error(t, client.Connect())
+}
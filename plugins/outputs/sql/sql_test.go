@@ -0,0 +1,406 @@
+package sql
+
+import (
+	gosql "database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"math"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDriver records the executed statements and tracks the columns of the
+// created tables, it is registered as the "fake" driver using the "sqlite3"
+// dialect.
+type fakeDriver struct {
+	sync.Mutex
+	tables      map[string][][]string
+	statements  []string
+	args        [][]driver.Value
+	commits     int
+	rollbacks   int
+	failInsert  bool
+	failColumns bool
+}
+
+var fake = &fakeDriver{}
+
+func init() {
+	gosql.Register("fake", fake)
+	dialects["fake"] = dialects["sqlite3"]
+}
+
+func (d *fakeDriver) reset() {
+	d.Lock()
+	defer d.Unlock()
+	d.tables = make(map[string][][]string)
+	d.statements = nil
+	d.args = nil
+	d.commits = 0
+	d.rollbacks = 0
+	d.failInsert = false
+	d.failColumns = false
+}
+
+func (d *fakeDriver) Open(string) (driver.Conn, error) {
+	return &fakeConn{d: d}, nil
+}
+
+type fakeConn struct {
+	d *fakeDriver
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{d: c.d, query: query}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return &fakeTx{d: c.d}, nil
+}
+
+type fakeTx struct {
+	d *fakeDriver
+}
+
+func (t *fakeTx) Commit() error {
+	t.d.Lock()
+	defer t.d.Unlock()
+	t.d.commits++
+	return nil
+}
+
+func (t *fakeTx) Rollback() error {
+	t.d.Lock()
+	defer t.d.Unlock()
+	t.d.rollbacks++
+	return nil
+}
+
+var (
+	createRe = regexp.MustCompile(`^CREATE TABLE IF NOT EXISTS "([^"]*)" \((.*)\)`)
+	alterRe  = regexp.MustCompile(`^ALTER TABLE "([^"]*)" ADD COLUMN "([^"]*)" (.*)$`)
+	columnRe = regexp.MustCompile(`"([^"]*)" ([A-Z]+)`)
+)
+
+type fakeStmt struct {
+	d     *fakeDriver
+	query string
+}
+
+func (s *fakeStmt) Close() error {
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.Lock()
+	defer s.d.Unlock()
+
+	if s.d.failInsert && strings.HasPrefix(s.query, "INSERT INTO") {
+		return nil, errors.New("insert failed")
+	}
+
+	s.d.statements = append(s.d.statements, s.query)
+	s.d.args = append(s.d.args, args)
+
+	if m := createRe.FindStringSubmatch(s.query); m != nil {
+		var columns [][]string
+		for _, c := range columnRe.FindAllStringSubmatch(m[2], -1) {
+			columns = append(columns, []string{c[1], c[2]})
+		}
+		s.d.tables[m[1]] = columns
+	}
+	if m := alterRe.FindStringSubmatch(s.query); m != nil {
+		s.d.tables[m[1]] = append(s.d.tables[m[1]], []string{m[2], m[3]})
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.d.Lock()
+	defer s.d.Unlock()
+
+	if s.query != dialects["sqlite3"].columnsQuery {
+		return nil, errors.New("unsupported query")
+	}
+	if s.d.failColumns {
+		return nil, errors.New("connection lost")
+	}
+	return &fakeRows{columns: s.d.tables[args[0].(string)]}, nil
+}
+
+// fakeRows returns the name and type of columns.
+type fakeRows struct {
+	columns [][]string
+}
+
+func (r *fakeRows) Columns() []string {
+	return []string{"name", "type"}
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.columns) == 0 {
+		return io.EOF
+	}
+	dest[0] = r.columns[0][0]
+	dest[1] = r.columns[0][1]
+	r.columns = r.columns[1:]
+	return nil
+}
+
+func newTestSQL(t *testing.T) *SQL {
+	fake.reset()
+	s := newSQL()
+	s.Driver = "fake"
+	require.NoError(t, s.Connect())
+	return s
+}
+
+func TestWriteCreatesTables(t *testing.T) {
+	s := newTestSQL(t)
+	defer s.Close()
+
+	ts := time.Unix(1560540094, 0)
+	metrics := []telegraf.Metric{
+		testutil.MustMetric("cpu",
+			map[string]string{"host": "a"},
+			map[string]interface{}{"usage": 1.5, "count": int64(3), "up": true, "state": "ok", "bytes": uint64(7)},
+			ts),
+		testutil.MustMetric("cpu",
+			map[string]string{"host": "b"},
+			map[string]interface{}{"usage": 2.5},
+			ts),
+	}
+	require.NoError(t, s.Write(metrics))
+
+	require.Equal(t, []string{
+		`CREATE TABLE IF NOT EXISTS "cpu" ("time" TIMESTAMP, "host" TEXT, "bytes" INTEGER, "count" INTEGER, "state" TEXT, "up" BOOLEAN, "usage" REAL)`,
+		`INSERT INTO "cpu" ("time", "host", "bytes", "count", "state", "up", "usage") VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		`INSERT INTO "cpu" ("time", "host", "usage") VALUES (?, ?, ?)`,
+	}, fake.statements)
+	require.Equal(t, []driver.Value{ts.UTC(), "a", int64(7), int64(3), "ok", true, 1.5}, fake.args[1])
+	require.Equal(t, 1, fake.commits)
+
+	// new fields are added to the existing table
+	require.NoError(t, s.Write([]telegraf.Metric{
+		testutil.MustMetric("cpu",
+			map[string]string{"host": "a", "cpu": "0"},
+			map[string]interface{}{"usage": 1.5, "idle": 98.5},
+			ts),
+	}))
+	require.Equal(t, []string{
+		`ALTER TABLE "cpu" ADD COLUMN "cpu" TEXT`,
+		`ALTER TABLE "cpu" ADD COLUMN "idle" REAL`,
+		`INSERT INTO "cpu" ("time", "cpu", "host", "idle", "usage") VALUES (?, ?, ?, ?, ?)`,
+	}, fake.statements[3:])
+}
+
+func TestExistingTable(t *testing.T) {
+	s := newTestSQL(t)
+	defer s.Close()
+	fake.tables["mem"] = [][]string{{"time", "TIMESTAMP"}, {"host", "TEXT"}, {"used", "INTEGER"}}
+
+	require.NoError(t, s.Write([]telegraf.Metric{
+		testutil.MustMetric("mem",
+			map[string]string{"host": "a"},
+			map[string]interface{}{"used": int64(1), "free": int64(2)},
+			time.Unix(0, 0)),
+	}))
+	require.Equal(t, []string{
+		`ALTER TABLE "mem" ADD COLUMN "free" INTEGER`,
+		`INSERT INTO "mem" ("time", "host", "free", "used") VALUES (?, ?, ?, ?)`,
+	}, fake.statements)
+}
+
+func TestTagTable(t *testing.T) {
+	s := newTestSQL(t)
+	defer s.Close()
+	s.TagsAsForeignKeys = true
+
+	m := testutil.MustMetric("cpu",
+		map[string]string{"host": "a"},
+		map[string]interface{}{"usage": 1.5},
+		time.Unix(0, 0))
+	id := int64(m.HashID())
+
+	require.NoError(t, s.Write([]telegraf.Metric{m, m.Copy()}))
+	require.Equal(t, []string{
+		`CREATE TABLE IF NOT EXISTS "cpu_tag" ("tag_id" INTEGER PRIMARY KEY, "host" TEXT)`,
+		`CREATE TABLE IF NOT EXISTS "cpu" ("time" TIMESTAMP, "tag_id" INTEGER, "usage" REAL)`,
+		`INSERT OR IGNORE INTO "cpu_tag" ("tag_id", "host") VALUES (?, ?)`,
+		`INSERT INTO "cpu" ("time", "tag_id", "usage") VALUES (?, ?, ?)`,
+		`INSERT INTO "cpu" ("time", "tag_id", "usage") VALUES (?, ?, ?)`,
+	}, fake.statements)
+	require.Equal(t, []driver.Value{id, "a"}, fake.args[2])
+
+	// known series are not inserted again
+	require.NoError(t, s.Write([]telegraf.Metric{m}))
+	require.Equal(t, []string{
+		`INSERT INTO "cpu" ("time", "tag_id", "usage") VALUES (?, ?, ?)`,
+	}, fake.statements[5:])
+}
+
+func TestFailedInsertRollsBack(t *testing.T) {
+	s := newTestSQL(t)
+	defer s.Close()
+	fake.failInsert = true
+
+	require.Error(t, s.Write([]telegraf.Metric{
+		testutil.MustMetric("cpu", map[string]string{}, map[string]interface{}{"usage": 1.5}, time.Unix(0, 0)),
+	}))
+	require.Equal(t, 0, fake.commits)
+	require.Equal(t, 1, fake.rollbacks)
+}
+
+func TestFailedWriteReadsColumnsAgain(t *testing.T) {
+	s := newTestSQL(t)
+	defer s.Close()
+
+	m := testutil.MustMetric("cpu", map[string]string{}, map[string]interface{}{"usage": 1.5}, time.Unix(0, 0))
+	require.NoError(t, s.Write([]telegraf.Metric{m}))
+	require.Contains(t, s.tables, "cpu")
+
+	fake.failInsert = true
+	require.Error(t, s.Write([]telegraf.Metric{m}))
+	require.NotContains(t, s.tables, "cpu")
+
+	// a failed query of the columns is not taken for a missing table
+	fake.failInsert = false
+	fake.failColumns = true
+	fake.statements = nil
+	require.Error(t, s.Write([]telegraf.Metric{m}))
+	require.Empty(t, fake.statements)
+
+	fake.failColumns = false
+	require.NoError(t, s.Write([]telegraf.Metric{m}))
+	require.Equal(t, []string{
+		`INSERT INTO "cpu" ("time", "usage") VALUES (?, ?)`,
+	}, fake.statements)
+}
+
+func TestCollidingColumns(t *testing.T) {
+	s := newTestSQL(t)
+	defer s.Close()
+
+	require.NoError(t, s.Write([]telegraf.Metric{
+		testutil.MustMetric("cpu",
+			map[string]string{"host": "a", "time": "t"},
+			map[string]interface{}{"host": "b", "time": int64(1), "time_tag": "c"},
+			time.Unix(0, 0)),
+	}))
+	require.Equal(t, []string{
+		`CREATE TABLE IF NOT EXISTS "cpu" ("time" TIMESTAMP, "host" TEXT, "time_tag" TEXT, "host_field" TEXT, "time_field" INTEGER, "time_tag_field" TEXT)`,
+		`INSERT INTO "cpu" ("time", "host", "time_tag", "host_field", "time_field", "time_tag_field") VALUES (?, ?, ?, ?, ?, ?)`,
+	}, fake.statements)
+
+	fake.reset()
+	s.tables = make(map[string]map[string]string)
+	s.TagsAsForeignKeys = true
+	require.NoError(t, s.Write([]telegraf.Metric{
+		testutil.MustMetric("mem",
+			map[string]string{"tag_id": "x"},
+			map[string]interface{}{"tag_id": int64(1)},
+			time.Unix(0, 0)),
+	}))
+	require.Equal(t, []string{
+		`CREATE TABLE IF NOT EXISTS "mem_tag" ("tag_id" INTEGER PRIMARY KEY, "tag_id_tag" TEXT)`,
+		`CREATE TABLE IF NOT EXISTS "mem" ("time" TIMESTAMP, "tag_id" INTEGER, "tag_id_field" INTEGER)`,
+	}, fake.statements[:2])
+}
+
+func TestTypeMismatch(t *testing.T) {
+	s := newTestSQL(t)
+	defer s.Close()
+	fake.tables["mem"] = [][]string{{"time", "TIMESTAMP"}, {"i", "INTEGER"}, {"f", "REAL"}, {"s", "TEXT"}}
+
+	require.NoError(t, s.Write([]telegraf.Metric{
+		testutil.MustMetric("mem",
+			map[string]string{},
+			map[string]interface{}{"i": 2.0, "f": int64(3), "s": true},
+			time.Unix(0, 0)),
+		testutil.MustMetric("mem",
+			map[string]string{},
+			map[string]interface{}{"i": 2.5, "f": "x", "s": uint64(4)},
+			time.Unix(0, 0)),
+	}))
+	require.Equal(t, []string{
+		`INSERT INTO "mem" ("time", "f", "i", "s") VALUES (?, ?, ?, ?)`,
+		`INSERT INTO "mem" ("time", "s") VALUES (?, ?)`,
+	}, fake.statements)
+	require.Equal(t, []driver.Value{time.Unix(0, 0).UTC(), 3.0, int64(2), "true"}, fake.args[0])
+	require.Equal(t, []driver.Value{time.Unix(0, 0).UTC(), "4"}, fake.args[1])
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		typ      string
+		expected interface{}
+		ok       bool
+	}{
+		{uint64(math.MaxUint64), "NUMERIC(20)", "18446744073709551615", true},
+		{uint64(math.MaxUint64), "numeric", "18446744073709551615", true},
+		{uint64(math.MaxUint64), "INTEGER", nil, false},
+		{uint64(5), "bigint", int64(5), true},
+		{int64(5), "double precision", 5.0, true},
+		{1e19, "bigint", nil, false},
+		{true, "bigint", nil, false},
+		{"a", "boolean", nil, false},
+		{int64(1), "character varying", "1", true},
+	}
+	for _, tt := range tests {
+		value, ok := convert(tt.value, columnKind(tt.typ))
+		require.Equal(t, tt.ok, ok, "%v %s", tt.value, tt.typ)
+		require.Equal(t, tt.expected, value, "%v %s", tt.value, tt.typ)
+	}
+}
+
+func TestTableTemplate(t *testing.T) {
+	fake.reset()
+	s := newSQL()
+	s.Driver = "fake"
+	s.TableTemplate = `CREATE TABLE IF NOT EXISTS {{.Table}} ({{.Columns}}) WITHOUT ROWID`
+	require.NoError(t, s.Connect())
+	defer s.Close()
+
+	require.NoError(t, s.Write([]telegraf.Metric{
+		testutil.MustMetric("my \"table\"", map[string]string{}, map[string]interface{}{"v": 1.5}, time.Unix(0, 0)),
+	}))
+	require.Equal(t, `CREATE TABLE IF NOT EXISTS "my ""table""" ("time" TIMESTAMP, "v" REAL) WITHOUT ROWID`, fake.statements[0])
+}
+
+func TestInvalidConfig(t *testing.T) {
+	s := newSQL()
+	s.Driver = "oracle"
+	require.Error(t, s.Connect())
+
+	s = newSQL()
+	s.Driver = "fake"
+	s.TableTemplate = "{{.Table"
+	require.Error(t, s.Connect())
+}
+
+func TestPostgresDialect(t *testing.T) {
+	d := dialects["pgx"]
+	require.Equal(t, "$3", d.placeholder(3))
+	require.Equal(t, `INSERT INTO "t" ("a") VALUES ($1) ON CONFLICT DO NOTHING`, d.insertIgnore(`"t"`, `"a"`, "$1"))
+}
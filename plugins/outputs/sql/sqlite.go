@@ -0,0 +1,7 @@
+// +build sqlite
+
+package sql
+
+// The SQLite driver requires cgo and is only included when building with the
+// "sqlite" tag.
+import _ "github.com/mattn/go-sqlite3"
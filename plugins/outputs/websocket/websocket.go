@@ -0,0 +1,265 @@
+package websocket
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/tls"
+	"github.com/influxdata/telegraf/plugins/outputs"
+	"github.com/influxdata/telegraf/plugins/serializers"
+	"golang.org/x/net/websocket"
+)
+
+var sampleConfig = `
+  ## URL of the WebSocket endpoint, with the ws or wss scheme.
+  url = "ws://127.0.0.1:8080/telegraf"
+
+  ## Timeout for opening the connection and for writing a message.
+  # connect_timeout = "30s"
+  # write_timeout = "30s"
+
+  ## Delay before connecting again after the connection failed, doubled on
+  ## each failed attempt up to reconnect_max_delay.
+  # reconnect_min_delay = "1s"
+  # reconnect_max_delay = "1m"
+
+  ## Type of the WebSocket messages, "text" or "binary".
+  # message_type = "text"
+
+  ## Origin sent in the opening handshake, defaults to the url with the http
+  ## or https scheme.
+  # origin = ""
+
+  ## Additional HTTP headers of the opening handshake.
+  # [outputs.websocket.headers]
+  #   Authorization = "Bearer mytoken"
+
+  ## Optional TLS Config
+  # tls_ca = "/etc/telegraf/ca.pem"
+  # tls_cert = "/etc/telegraf/cert.pem"
+  # tls_key = "/etc/telegraf/key.pem"
+  ## Use TLS but skip chain & host verification
+  # insecure_skip_verify = false
+
+  ## Data format to output.
+  ## Each data format has it's own unique set of configuration options, read
+  ## more about them here:
+  ## https://github.com/influxdata/telegraf/blob/master/docs/DATA_FORMATS_OUTPUT.md
+  # data_format = "influx"
+`
+
+const (
+	defaultTimeout           = 30 * time.Second
+	defaultReconnectMinDelay = time.Second
+	defaultReconnectMaxDelay = time.Minute
+)
+
+type WebSocket struct {
+	URL               string            `toml:"url"`
+	ConnectTimeout    internal.Duration `toml:"connect_timeout"`
+	WriteTimeout      internal.Duration `toml:"write_timeout"`
+	ReconnectMinDelay internal.Duration `toml:"reconnect_min_delay"`
+	ReconnectMaxDelay internal.Duration `toml:"reconnect_max_delay"`
+	MessageType       string            `toml:"message_type"`
+	Origin            string            `toml:"origin"`
+	Headers           map[string]string `toml:"headers"`
+	tls.ClientConfig
+
+	serializer serializers.Serializer
+	config     *websocket.Config
+
+	sync.Mutex
+	conn *websocket.Conn
+	// no connection is opened before nextConnect; delay is the current
+	// backoff after failed connections.
+	nextConnect time.Time
+	delay       time.Duration
+
+	now func() time.Time
+}
+
+func (w *WebSocket) SetSerializer(serializer serializers.Serializer) {
+	w.serializer = serializer
+}
+
+func (w *WebSocket) Description() string {
+	return "Send metrics to a WebSocket endpoint"
+}
+
+func (w *WebSocket) SampleConfig() string {
+	return sampleConfig
+}
+
+func (w *WebSocket) Connect() error {
+	u, err := url.Parse(w.URL)
+	if err != nil {
+		return fmt.Errorf("invalid url %s: %v", w.URL, err)
+	}
+	if u.Scheme != "ws" && u.Scheme != "wss" {
+		return fmt.Errorf("unsupported scheme of url %s, must be ws or wss", w.URL)
+	}
+
+	switch w.MessageType {
+	case "":
+		w.MessageType = "text"
+	case "text", "binary":
+	default:
+		return fmt.Errorf("invalid message_type %q", w.MessageType)
+	}
+
+	origin := w.Origin
+	if origin == "" {
+		origin = "http" + strings.TrimPrefix(u.Scheme, "ws") + "://" + u.Host
+	}
+
+	w.config, err = websocket.NewConfig(w.URL, origin)
+	if err != nil {
+		return err
+	}
+
+	w.config.TlsConfig, err = w.ClientConfig.TLSConfig()
+	if err != nil {
+		return err
+	}
+
+	w.config.Header = make(http.Header)
+	for k, v := range w.Headers {
+		w.config.Header.Set(k, v)
+	}
+
+	if w.ConnectTimeout.Duration == 0 {
+		w.ConnectTimeout.Duration = defaultTimeout
+	}
+	if w.WriteTimeout.Duration == 0 {
+		w.WriteTimeout.Duration = defaultTimeout
+	}
+	if w.ReconnectMinDelay.Duration == 0 {
+		w.ReconnectMinDelay.Duration = defaultReconnectMinDelay
+	}
+	if w.ReconnectMaxDelay.Duration < w.ReconnectMinDelay.Duration {
+		w.ReconnectMaxDelay.Duration = w.ReconnectMinDelay.Duration
+	}
+	w.config.Dialer = &net.Dialer{Timeout: w.ConnectTimeout.Duration}
+
+	if w.now == nil {
+		w.now = time.Now
+	}
+
+	// The endpoint may not be reachable yet; the connection is opened again
+	// on the next write.
+	w.Lock()
+	defer w.Unlock()
+	if err := w.connect(); err != nil {
+		log.Printf("W! [outputs.websocket] %v", err)
+	}
+	return nil
+}
+
+// connect opens the connection unless waiting after a failed attempt.
+func (w *WebSocket) connect() error {
+	now := w.now()
+	if now.Before(w.nextConnect) {
+		return fmt.Errorf("not connected to %s, connecting again in %s",
+			w.URL, w.nextConnect.Sub(now).Round(time.Millisecond))
+	}
+
+	conn, err := websocket.DialConfig(w.config)
+	if err != nil {
+		if w.delay == 0 {
+			w.delay = w.ReconnectMinDelay.Duration
+		} else {
+			w.delay *= 2
+			if w.delay > w.ReconnectMaxDelay.Duration {
+				w.delay = w.ReconnectMaxDelay.Duration
+			}
+		}
+		w.nextConnect = now.Add(w.delay)
+		return fmt.Errorf("connecting to %s failed, connecting again in %s: %v", w.URL, w.delay, err)
+	}
+
+	if w.MessageType == "binary" {
+		conn.PayloadType = websocket.BinaryFrame
+	}
+
+	w.conn = conn
+	w.delay = 0
+	w.nextConnect = time.Time{}
+	go w.read(conn)
+
+	log.Printf("D! [outputs.websocket] Connected to %s", w.URL)
+	return nil
+}
+
+// read discards the messages sent by the server, answering its pings, and
+// closes the connection when the server closes it.
+func (w *WebSocket) read(conn *websocket.Conn) {
+	var msg []byte
+	for {
+		if err := websocket.Message.Receive(conn, &msg); err != nil {
+			w.Lock()
+			w.closeConn(conn)
+			w.Unlock()
+			return
+		}
+	}
+}
+
+func (w *WebSocket) closeConn(conn *websocket.Conn) {
+	conn.Close()
+	if w.conn == conn {
+		w.conn = nil
+	}
+}
+
+func (w *WebSocket) Write(metrics []telegraf.Metric) error {
+	w.Lock()
+	defer w.Unlock()
+
+	if w.conn == nil {
+		if err := w.connect(); err != nil {
+			return err
+		}
+	}
+
+	msg, err := w.serializer.SerializeBatch(metrics)
+	if err != nil {
+		return err
+	}
+
+	w.conn.SetWriteDeadline(time.Now().Add(w.WriteTimeout.Duration))
+	if _, err := w.conn.Write(msg); err != nil {
+		w.closeConn(w.conn)
+		return fmt.Errorf("writing to %s failed: %v", w.URL, err)
+	}
+	return nil
+}
+
+func (w *WebSocket) Close() error {
+	w.Lock()
+	defer w.Unlock()
+
+	if w.conn != nil {
+		w.closeConn(w.conn)
+	}
+	return nil
+}
+
+func init() {
+	outputs.Add("websocket", func() telegraf.Output {
+		return &WebSocket{
+			ConnectTimeout:    internal.Duration{Duration: defaultTimeout},
+			WriteTimeout:      internal.Duration{Duration: defaultTimeout},
+			ReconnectMinDelay: internal.Duration{Duration: defaultReconnectMinDelay},
+			ReconnectMaxDelay: internal.Duration{Duration: defaultReconnectMaxDelay},
+			MessageType:       "text",
+		}
+	})
+}
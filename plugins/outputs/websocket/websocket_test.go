@@ -0,0 +1,158 @@
+package websocket
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/serializers/influx"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+)
+
+type message struct {
+	data   string
+	header http.Header
+}
+
+func handler(messages chan message) websocket.Handler {
+	return websocket.Handler(func(ws *websocket.Conn) {
+		for {
+			var data []byte
+			if err := websocket.Message.Receive(ws, &data); err != nil {
+				return
+			}
+			messages <- message{string(data), ws.Request().Header}
+		}
+	})
+}
+
+// newServer returns a server sending the messages it receives on the
+// channel.
+func newServer(messages chan message) *httptest.Server {
+	return httptest.NewServer(handler(messages))
+}
+
+func wsURL(ts *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(ts.URL, "http") + "/telegraf"
+}
+
+func newWebSocket(url string) *WebSocket {
+	w := &WebSocket{
+		URL:     url,
+		Headers: map[string]string{"Authorization": "Bearer token"},
+	}
+	w.SetSerializer(influx.NewSerializer())
+	return w
+}
+
+func testMetrics() []telegraf.Metric {
+	return []telegraf.Metric{
+		testutil.MustMetric("cpu", map[string]string{}, map[string]interface{}{"value": 42.0}, time.Unix(0, 0)),
+		testutil.MustMetric("cpu", map[string]string{}, map[string]interface{}{"value": 43.0}, time.Unix(1, 0)),
+	}
+}
+
+func TestWrite(t *testing.T) {
+	messages := make(chan message, 10)
+	ts := newServer(messages)
+	defer ts.Close()
+
+	w := newWebSocket(wsURL(ts))
+	require.NoError(t, w.Connect())
+	defer w.Close()
+
+	require.NoError(t, w.Write(testMetrics()))
+	msg := <-messages
+	require.Equal(t, "cpu value=42 0\ncpu value=43 1000000000\n", msg.data)
+	require.Equal(t, "Bearer token", msg.header.Get("Authorization"))
+}
+
+func TestInvalidConfig(t *testing.T) {
+	w := newWebSocket("http://127.0.0.1:8080/telegraf")
+	require.Error(t, w.Connect())
+
+	w = newWebSocket("ws://127.0.0.1:8080/telegraf")
+	w.MessageType = "json"
+	require.Error(t, w.Connect())
+}
+
+func TestReconnectBackoff(t *testing.T) {
+	// reserve an address with nothing listening
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	l.Close()
+	url := "ws://" + addr + "/telegraf"
+
+	now := time.Unix(0, 0)
+	w := newWebSocket(url)
+	w.ReconnectMinDelay = internal.Duration{Duration: time.Second}
+	w.ReconnectMaxDelay = internal.Duration{Duration: 3 * time.Second}
+	w.now = func() time.Time { return now }
+
+	// the endpoint is down when starting
+	require.NoError(t, w.Connect())
+	require.Nil(t, w.conn)
+	require.Equal(t, time.Second, w.delay)
+
+	// no connection is opened while backing off
+	require.Error(t, w.Write(testMetrics()))
+	require.Equal(t, time.Second, w.delay)
+
+	now = now.Add(time.Second)
+	require.Error(t, w.Write(testMetrics()))
+	require.Equal(t, 2*time.Second, w.delay)
+
+	now = now.Add(2 * time.Second)
+	require.Error(t, w.Write(testMetrics()))
+	require.Equal(t, 3*time.Second, w.delay)
+
+	// the endpoint is back
+	messages := make(chan message, 10)
+	l, err = net.Listen("tcp", addr)
+	require.NoError(t, err)
+	ts := httptest.NewUnstartedServer(handler(messages))
+	ts.Listener = l
+	ts.Start()
+	defer ts.Close()
+
+	now = now.Add(3 * time.Second)
+	require.NoError(t, w.Write(testMetrics()))
+	require.Equal(t, time.Duration(0), w.delay)
+	<-messages
+	w.Close()
+}
+
+func TestServerClose(t *testing.T) {
+	closed := make(chan struct{})
+	ts := httptest.NewServer(websocket.Handler(func(ws *websocket.Conn) {
+		ws.Close()
+		close(closed)
+	}))
+	defer ts.Close()
+
+	w := newWebSocket(wsURL(ts))
+	require.NoError(t, w.Connect())
+	<-closed
+
+	// the closed connection is detected by the reader
+	for i := 0; i < 100; i++ {
+		w.Lock()
+		conn := w.conn
+		w.Unlock()
+		if conn == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	w.Lock()
+	require.Nil(t, w.conn)
+	w.Unlock()
+}
@@ -0,0 +1,163 @@
+package avro
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+var errShortBuffer = errors.New("unexpected end of data")
+
+// decoder reads values encoded with the Avro binary encoding.
+type decoder struct {
+	buf []byte
+	pos int
+}
+
+func (d *decoder) remaining() int {
+	return len(d.buf) - d.pos
+}
+
+func (d *decoder) decode(s *schema) (interface{}, error) {
+	switch s.Type {
+	case "null":
+		return nil, nil
+	case "boolean":
+		b, err := d.readBytes(1)
+		if err != nil {
+			return nil, err
+		}
+		return b[0] != 0, nil
+	case "int":
+		v, err := d.readLong()
+		return int32(v), err
+	case "long":
+		return d.readLong()
+	case "float":
+		b, err := d.readBytes(4)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float32frombits(binary.LittleEndian.Uint32(b)), nil
+	case "double":
+		b, err := d.readBytes(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(b)), nil
+	case "bytes":
+		return d.readLengthPrefixed()
+	case "string":
+		b, err := d.readLengthPrefixed()
+		return string(b), err
+	case "fixed":
+		return d.readBytes(s.Size)
+	case "enum":
+		i, err := d.readLong()
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || int(i) >= len(s.Symbols) {
+			return nil, fmt.Errorf("enum index %d out of range for %s", i, s.Name)
+		}
+		return s.Symbols[i], nil
+	case "union":
+		i, err := d.readLong()
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || int(i) >= len(s.Branches) {
+			return nil, fmt.Errorf("union index %d out of range", i)
+		}
+		return d.decode(s.Branches[i])
+	case "array":
+		values := make([]interface{}, 0)
+		err := d.readBlocks(func() error {
+			v, err := d.decode(s.Items)
+			values = append(values, v)
+			return err
+		})
+		return values, err
+	case "map":
+		values := make(map[string]interface{})
+		err := d.readBlocks(func() error {
+			key, err := d.readLengthPrefixed()
+			if err != nil {
+				return err
+			}
+			v, err := d.decode(s.Values)
+			values[string(key)] = v
+			return err
+		})
+		return values, err
+	case "record":
+		record := make(map[string]interface{}, len(s.Fields))
+		for _, f := range s.Fields {
+			v, err := d.decode(f.Schema)
+			if err != nil {
+				return nil, err
+			}
+			record[f.Name] = v
+		}
+		return record, nil
+	default:
+		return nil, fmt.Errorf("unsupported type %q", s.Type)
+	}
+}
+
+// readBlocks calls fn for each item of an array or map.
+func (d *decoder) readBlocks(fn func() error) error {
+	for {
+		count, err := d.readLong()
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return nil
+		}
+		if count < 0 {
+			// a negative count is followed by the block size in bytes
+			count = -count
+			if _, err := d.readLong(); err != nil {
+				return err
+			}
+		}
+		// Items encoding no data, such as nulls, would let a huge count
+		// spin without consuming the input.
+		if count < 0 || count > int64(d.remaining()) {
+			return fmt.Errorf("block count %d exceeds the remaining data", count)
+		}
+		for i := int64(0); i < count; i++ {
+			if err := fn(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (d *decoder) readLong() (int64, error) {
+	v, n := binary.Varint(d.buf[d.pos:])
+	if n <= 0 {
+		return 0, errShortBuffer
+	}
+	d.pos += n
+	return v, nil
+}
+
+func (d *decoder) readBytes(n int) ([]byte, error) {
+	if n < 0 || d.remaining() < n {
+		return nil, errShortBuffer
+	}
+	b := d.buf[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *decoder) readLengthPrefixed() ([]byte, error) {
+	n, err := d.readLong()
+	if err != nil {
+		return nil, err
+	}
+	return d.readBytes(int(n))
+}
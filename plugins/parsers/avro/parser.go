@@ -0,0 +1,138 @@
+package avro
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/parsers/internal/mapping"
+)
+
+// magicByte starts each message in the schema registry wire format, it is
+// followed by the 4 byte big endian schema id.
+const magicByte = 0
+
+type Config struct {
+	// SchemaFile is the path of the schema used to decode all messages.
+	SchemaFile string
+	// SchemaRegistry is the url of the schema registry used to look up the
+	// schema of each message.
+	SchemaRegistry string
+
+	MetricName       string
+	MeasurementField string
+	Tags             []string
+	Fields           []string
+	Timestamp        string
+	TimestampFormat  string
+	DefaultTags      map[string]string
+}
+
+// Parser decodes Avro binary encoded records.
+type Parser struct {
+	schema   *schema
+	registry *schemaRegistry
+	mapping  mapping.Mapping
+}
+
+func New(config *Config) (*Parser, error) {
+	p := &Parser{
+		mapping: mapping.Mapping{
+			MetricName:       config.MetricName,
+			MeasurementField: config.MeasurementField,
+			Tags:             config.Tags,
+			Fields:           config.Fields,
+			Timestamp:        config.Timestamp,
+			TimestampFormat:  config.TimestampFormat,
+			DefaultTags:      config.DefaultTags,
+			TimeFunc:         time.Now,
+		},
+	}
+
+	switch {
+	case config.SchemaRegistry != "":
+		p.registry = newSchemaRegistry(config.SchemaRegistry)
+	case config.SchemaFile != "":
+		buf, err := ioutil.ReadFile(config.SchemaFile)
+		if err != nil {
+			return nil, err
+		}
+		p.schema, err = parseSchema(string(buf))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", config.SchemaFile, err)
+		}
+	default:
+		return nil, errors.New("one of avro_schema_file or avro_schema_registry must be set")
+	}
+
+	return p, nil
+}
+
+// Parse decodes the records in buf.  When using a schema registry buf holds
+// a single message in the wire format, otherwise buf may hold any number of
+// concatenated records.
+func (p *Parser) Parse(buf []byte) ([]telegraf.Metric, error) {
+	s := p.schema
+	if p.registry != nil {
+		if len(buf) < 5 || buf[0] != magicByte {
+			return nil, errors.New("message is not in the schema registry wire format")
+		}
+		id := int32(binary.BigEndian.Uint32(buf[1:5]))
+
+		var err error
+		s, err = p.registry.getSchema(id)
+		if err != nil {
+			return nil, err
+		}
+		buf = buf[5:]
+	}
+
+	if s.Type != "record" {
+		return nil, fmt.Errorf("schema type must be a record, not %s", s.Type)
+	}
+
+	metrics := make([]telegraf.Metric, 0)
+	d := &decoder{buf: buf}
+	for d.remaining() > 0 {
+		pos := d.pos
+		v, err := d.decode(s)
+		if err != nil {
+			return nil, err
+		}
+		if d.pos == pos {
+			return nil, errors.New("record consumed no data")
+		}
+
+		m, err := p.mapping.Metric(v.(map[string]interface{}))
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics, nil
+}
+
+func (p *Parser) ParseLine(line string) (telegraf.Metric, error) {
+	metrics, err := p.Parse([]byte(line))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(metrics) != 1 {
+		return nil, errors.New("line contains multiple metrics")
+	}
+
+	return metrics[0], nil
+}
+
+func (p *Parser) SetDefaultTags(tags map[string]string) {
+	p.mapping.DefaultTags = tags
+}
+
+func (p *Parser) SetTimeFunc(fn metric.TimeFunc) {
+	p.mapping.TimeFunc = fn
+}
@@ -0,0 +1,254 @@
+package avro
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+const testSchema = `
+{
+  "type": "record",
+  "name": "Reading",
+  "namespace": "com.example",
+  "fields": [
+    {"name": "measurement", "type": "string"},
+    {"name": "timestamp", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+    {"name": "device", "type": {
+      "type": "record",
+      "name": "Device",
+      "fields": [
+        {"name": "site", "type": "string"},
+        {"name": "kind", "type": {"type": "enum", "name": "Kind", "symbols": ["SENSOR", "GATEWAY"]}}
+      ]
+    }},
+    {"name": "temperature", "type": "double"},
+    {"name": "humidity", "type": ["null", "float"]},
+    {"name": "count", "type": "int"},
+    {"name": "ok", "type": "boolean"},
+    {"name": "labels", "type": {"type": "map", "values": "string"}},
+    {"name": "samples", "type": {"type": "array", "items": "long"}}
+  ]
+}
+`
+
+type encoder []byte
+
+func (e encoder) long(v int64) encoder {
+	buf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutVarint(buf, v)
+	return append(e, buf[:n]...)
+}
+
+func (e encoder) str(s string) encoder {
+	return append(e.long(int64(len(s))), s...)
+}
+
+func (e encoder) double(v float64) encoder {
+	buf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buf, math.Float64bits(v))
+	return append(e, buf...)
+}
+
+func (e encoder) float(v float32) encoder {
+	buf := make([]byte, 4)
+	binary.LittleEndian.PutUint32(buf, math.Float32bits(v))
+	return append(e, buf...)
+}
+
+func testRecord() []byte {
+	var e encoder
+	e = e.str("climate")
+	e = e.long(1500000000000)
+	e = e.str("ams1").long(1)
+	e = e.double(21.5)
+	e = e.long(1).float(0.5)
+	e = e.long(42)
+	e = append(e, 1)
+	e = e.long(1).str("rack").str("r1").long(0)
+	e = e.long(2).long(3).long(4).long(0)
+	return e
+}
+
+func writeSchema(t *testing.T) string {
+	return writeSchemaString(t, testSchema)
+}
+
+func writeSchemaString(t *testing.T, schema string) string {
+	f, err := ioutil.TempFile("", "schema")
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = f.WriteString(schema)
+	require.NoError(t, err)
+	return f.Name()
+}
+
+func TestParseSchemaFile(t *testing.T) {
+	schemaFile := writeSchema(t)
+	defer os.Remove(schemaFile)
+
+	parser, err := New(&Config{
+		SchemaFile:       schemaFile,
+		MetricName:       "avro",
+		MeasurementField: "measurement",
+		Tags:             []string{"device.site", "device.kind"},
+		Timestamp:        "timestamp",
+		TimestampFormat:  "unix_ms",
+	})
+	require.NoError(t, err)
+
+	metrics, err := parser.Parse(testRecord())
+	require.NoError(t, err)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric(
+			"climate",
+			map[string]string{
+				"device_site": "ams1",
+				"device_kind": "GATEWAY",
+			},
+			map[string]interface{}{
+				"temperature": 21.5,
+				"humidity":    0.5,
+				"count":       int64(42),
+				"ok":          true,
+				"labels_rack": "r1",
+				"samples_0":   int64(3),
+				"samples_1":   int64(4),
+			},
+			time.Unix(1500000000, 0),
+		),
+	}
+	testutil.RequireMetricsEqual(t, expected, metrics)
+}
+
+func TestParseSelectedFields(t *testing.T) {
+	schemaFile := writeSchema(t)
+	defer os.Remove(schemaFile)
+
+	parser, err := New(&Config{
+		SchemaFile: schemaFile,
+		MetricName: "avro",
+		Tags:       []string{"device.site"},
+		Fields:     []string{"temperature", "samples.1"},
+	})
+	require.NoError(t, err)
+	parser.SetTimeFunc(func() time.Time { return time.Unix(42, 0) })
+
+	// two concatenated records
+	buf := append(testRecord(), testRecord()...)
+	metrics, err := parser.Parse(buf)
+	require.NoError(t, err)
+
+	m := testutil.MustMetric(
+		"avro",
+		map[string]string{
+			"device_site": "ams1",
+		},
+		map[string]interface{}{
+			"temperature": 21.5,
+			"samples_1":   int64(4),
+		},
+		time.Unix(42, 0),
+	)
+	testutil.RequireMetricsEqual(t, []telegraf.Metric{m, m}, metrics)
+}
+
+func TestParseTruncated(t *testing.T) {
+	schemaFile := writeSchema(t)
+	defer os.Remove(schemaFile)
+
+	parser, err := New(&Config{
+		SchemaFile: schemaFile,
+		MetricName: "avro",
+	})
+	require.NoError(t, err)
+
+	buf := testRecord()
+	_, err = parser.Parse(buf[:len(buf)-3])
+	require.Error(t, err)
+}
+
+func TestParseNoProgress(t *testing.T) {
+	newParser := func(schema string) *Parser {
+		schemaFile := writeSchemaString(t, schema)
+		defer os.Remove(schemaFile)
+
+		parser, err := New(&Config{
+			SchemaFile: schemaFile,
+			MetricName: "avro",
+		})
+		require.NoError(t, err)
+		return parser
+	}
+
+	// a record of nulls never consumes the data
+	parser := newParser(`{"type": "record", "name": "Empty", "fields": [{"name": "a", "type": "null"}]}`)
+	_, err := parser.Parse([]byte{0})
+	require.Error(t, err)
+
+	parser = newParser(`{"type": "record", "name": "Nulls", "fields": [
+		{"name": "a", "type": {"type": "array", "items": "null"}}]}`)
+
+	// an array of a huge number of nulls
+	_, err = parser.Parse(encoder{}.long(math.MaxInt64).long(0))
+	require.Error(t, err)
+	_, err = parser.Parse(encoder{}.long(math.MinInt64).long(0).long(0))
+	require.Error(t, err)
+
+	// arrays of nulls within the size of the data are decoded
+	metrics, err := parser.Parse(encoder{}.long(1).long(0))
+	require.NoError(t, err)
+	require.Len(t, metrics, 1)
+}
+
+func TestParseSchemaRegistry(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/schemas/ids/7" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"schema": "{\"type\": \"record\", \"name\": \"cpu\", \"fields\": [{\"name\": \"host\", \"type\": \"string\"}, {\"name\": \"usage\", \"type\": \"double\"}]}"}`))
+	}))
+	defer ts.Close()
+
+	parser, err := New(&Config{
+		SchemaRegistry: ts.URL,
+		MetricName:     "cpu",
+		Tags:           []string{"host"},
+	})
+	require.NoError(t, err)
+	parser.SetTimeFunc(func() time.Time { return time.Unix(42, 0) })
+
+	msg := encoder{magicByte, 0, 0, 0, 7}.str("server01").double(0.25)
+	for i := 0; i < 2; i++ {
+		metrics, err := parser.Parse(msg)
+		require.NoError(t, err)
+		testutil.RequireMetricsEqual(t, []telegraf.Metric{
+			testutil.MustMetric(
+				"cpu",
+				map[string]string{"host": "server01"},
+				map[string]interface{}{"usage": 0.25},
+				time.Unix(42, 0),
+			),
+		}, metrics)
+	}
+	require.Equal(t, 1, requests)
+
+	_, err = parser.Parse(encoder{magicByte, 0, 0, 0, 8})
+	require.Error(t, err)
+
+	_, err = parser.Parse([]byte("cpu usage=0.25"))
+	require.Error(t, err)
+}
@@ -0,0 +1,64 @@
+package avro
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// schemaRegistry fetches schemas by id from a Confluent compatible schema
+// registry.  Schemas are immutable once registered, so they are cached for
+// the lifetime of the parser.
+type schemaRegistry struct {
+	url    string
+	client *http.Client
+
+	mu      sync.Mutex
+	schemas map[int32]*schema
+}
+
+func newSchemaRegistry(url string) *schemaRegistry {
+	return &schemaRegistry{
+		url: strings.TrimRight(url, "/"),
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		schemas: make(map[int32]*schema),
+	}
+}
+
+func (r *schemaRegistry) getSchema(id int32) (*schema, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if s, ok := r.schemas[id]; ok {
+		return s, nil
+	}
+
+	resp, err := r.client.Get(fmt.Sprintf("%s/schemas/ids/%d", r.url, id))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("schema registry returned status %d for schema %d", resp.StatusCode, id)
+	}
+
+	var body struct {
+		Schema string `json:"schema"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	s, err := parseSchema(body.Schema)
+	if err != nil {
+		return nil, err
+	}
+	r.schemas[id] = s
+	return s, nil
+}
@@ -0,0 +1,166 @@
+package avro
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// schema is a node of a parsed Avro schema.
+type schema struct {
+	// Type is the primitive or complex type name, or "union".
+	Type string
+
+	// Name is the full name of a record, enum or fixed type.
+	Name string
+
+	// Fields of a record.
+	Fields []field
+	// Symbols of an enum.
+	Symbols []string
+	// Items is the element schema of an array.
+	Items *schema
+	// Values is the element schema of a map.
+	Values *schema
+	// Size of a fixed.
+	Size int
+	// Branches of a union.
+	Branches []*schema
+}
+
+type field struct {
+	Name   string
+	Schema *schema
+}
+
+// parseSchema parses the JSON representation of an Avro schema.
+func parseSchema(text string) (*schema, error) {
+	var v interface{}
+	if err := json.Unmarshal([]byte(text), &v); err != nil {
+		return nil, fmt.Errorf("invalid schema: %v", err)
+	}
+
+	p := &schemaParser{named: make(map[string]*schema)}
+	return p.parse(v, "")
+}
+
+type schemaParser struct {
+	named map[string]*schema
+}
+
+func (p *schemaParser) parse(v interface{}, namespace string) (*schema, error) {
+	switch node := v.(type) {
+	case string:
+		return p.parseName(node, namespace)
+	case []interface{}:
+		s := &schema{Type: "union"}
+		for _, branch := range node {
+			bs, err := p.parse(branch, namespace)
+			if err != nil {
+				return nil, err
+			}
+			s.Branches = append(s.Branches, bs)
+		}
+		return s, nil
+	case map[string]interface{}:
+		return p.parseComplex(node, namespace)
+	default:
+		return nil, fmt.Errorf("invalid schema: unexpected %T", v)
+	}
+}
+
+func (p *schemaParser) parseName(name, namespace string) (*schema, error) {
+	switch name {
+	case "null", "boolean", "int", "long", "float", "double", "bytes", "string":
+		return &schema{Type: name}, nil
+	}
+
+	if s, ok := p.named[fullName(name, namespace)]; ok {
+		return s, nil
+	}
+	if s, ok := p.named[name]; ok {
+		return s, nil
+	}
+	return nil, fmt.Errorf("invalid schema: unknown type %q", name)
+}
+
+func (p *schemaParser) parseComplex(node map[string]interface{}, namespace string) (*schema, error) {
+	typ, ok := node["type"]
+	if !ok {
+		return nil, fmt.Errorf("invalid schema: missing type")
+	}
+
+	typeName, ok := typ.(string)
+	if !ok {
+		// a type which is itself a schema, such as {"type": {"type": "array", ...}}
+		return p.parse(typ, namespace)
+	}
+
+	switch typeName {
+	case "record", "error", "enum", "fixed":
+	case "array":
+		items, err := p.parse(node["items"], namespace)
+		if err != nil {
+			return nil, err
+		}
+		return &schema{Type: "array", Items: items}, nil
+	case "map":
+		values, err := p.parse(node["values"], namespace)
+		if err != nil {
+			return nil, err
+		}
+		return &schema{Type: "map", Values: values}, nil
+	default:
+		// primitive with attributes such as a logicalType
+		return p.parseName(typeName, namespace)
+	}
+
+	name, _ := node["name"].(string)
+	if name == "" {
+		return nil, fmt.Errorf("invalid schema: %s without name", typeName)
+	}
+	if ns, ok := node["namespace"].(string); ok {
+		namespace = ns
+	}
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		namespace = name[:i]
+	}
+
+	s := &schema{Type: typeName, Name: fullName(name, namespace)}
+	p.named[s.Name] = s
+
+	switch typeName {
+	case "record", "error":
+		s.Type = "record"
+		fields, _ := node["fields"].([]interface{})
+		for _, f := range fields {
+			fnode, ok := f.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("invalid schema: field of %s is not an object", s.Name)
+			}
+			fname, _ := fnode["name"].(string)
+			fs, err := p.parse(fnode["type"], namespace)
+			if err != nil {
+				return nil, err
+			}
+			s.Fields = append(s.Fields, field{Name: fname, Schema: fs})
+		}
+	case "enum":
+		symbols, _ := node["symbols"].([]interface{})
+		for _, sym := range symbols {
+			str, _ := sym.(string)
+			s.Symbols = append(s.Symbols, str)
+		}
+	case "fixed":
+		size, _ := node["size"].(float64)
+		s.Size = int(size)
+	}
+	return s, nil
+}
+
+func fullName(name, namespace string) string {
+	if namespace == "" || strings.Contains(name, ".") {
+		return name
+	}
+	return namespace + "." + name
+}
@@ -0,0 +1,211 @@
+// Package mapping converts records decoded by the schema based parsers into
+// metrics.
+package mapping
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/metric"
+)
+
+// Mapping describes which values of a decoded record are used as the
+// measurement name, tags, fields and timestamp of the metric.
+//
+// Values are selected with a path, the names of the nested record fields
+// joined with a dot; array elements are selected by index.  For example the
+// path "device.sensors.0.value" selects the value field of the first element
+// in the sensors array of the device record.
+type Mapping struct {
+	// MetricName is used when MeasurementField is not set or not found.
+	MetricName string
+	// MeasurementField is the path of the value used as measurement name.
+	MeasurementField string
+	// Tags are the paths of the values added as tags.
+	Tags []string
+	// Fields are the paths of the values added as fields, if empty all values
+	// not used otherwise are added.
+	Fields []string
+	// Timestamp is the path of the value used as metric time, if empty the
+	// current time is used.
+	Timestamp string
+	// TimestampFormat is one of unix, unix_ms, unix_us, unix_ns or a Go time
+	// layout.
+	TimestampFormat string
+
+	DefaultTags map[string]string
+	TimeFunc    metric.TimeFunc
+}
+
+// Metric builds a metric from the record.
+func (m *Mapping) Metric(record map[string]interface{}) (telegraf.Metric, error) {
+	name := m.MetricName
+	if m.MeasurementField != "" {
+		if v, ok := Lookup(record, m.MeasurementField); ok && v != nil {
+			name = toString(v)
+		}
+	}
+
+	used := make(map[string]bool)
+	used[m.MeasurementField] = true
+	used[m.Timestamp] = true
+
+	tags := make(map[string]string, len(m.DefaultTags)+len(m.Tags))
+	for k, v := range m.DefaultTags {
+		tags[k] = v
+	}
+	for _, path := range m.Tags {
+		used[path] = true
+		v, ok := Lookup(record, path)
+		if !ok || v == nil {
+			continue
+		}
+		tags[keyName(path)] = toString(v)
+	}
+
+	fields := make(map[string]interface{})
+	if len(m.Fields) > 0 {
+		for _, path := range m.Fields {
+			v, ok := Lookup(record, path)
+			if !ok {
+				continue
+			}
+			flatten(fields, keyName(path), v, nil)
+		}
+	} else {
+		for k, v := range record {
+			flatten(fields, k, v, used)
+		}
+	}
+
+	tm := m.now()
+	if m.Timestamp != "" {
+		v, ok := Lookup(record, m.Timestamp)
+		if !ok {
+			return nil, fmt.Errorf("timestamp %q not found", m.Timestamp)
+		}
+		var err error
+		tm, err = m.parseTime(v)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return metric.New(name, tags, fields, tm)
+}
+
+func (m *Mapping) now() time.Time {
+	if m.TimeFunc == nil {
+		return time.Now()
+	}
+	return m.TimeFunc()
+}
+
+func (m *Mapping) parseTime(v interface{}) (time.Time, error) {
+	switch ts := v.(type) {
+	case time.Time:
+		return ts, nil
+	case int32:
+		v = int64(ts)
+	case uint32:
+		v = int64(ts)
+	case uint64:
+		v = int64(ts)
+	case float32:
+		v = float64(ts)
+	case []byte:
+		v = string(ts)
+	}
+
+	format := m.TimestampFormat
+	if format == "" {
+		format = "unix"
+	}
+	return internal.ParseTimestamp(v, format)
+}
+
+// Lookup returns the value at path in the record.
+func Lookup(record map[string]interface{}, path string) (interface{}, bool) {
+	var v interface{} = record
+	for _, key := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			child, ok := node[key]
+			if !ok {
+				return nil, false
+			}
+			v = child
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			v = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+func keyName(path string) string {
+	return strings.Replace(path, ".", "_", -1)
+}
+
+// flatten adds the value to fields under key, nested records and arrays are
+// added with the key of each element appended.  Paths in skip are ignored.
+func flatten(fields map[string]interface{}, key string, v interface{}, skip map[string]bool) {
+	flattenPath(fields, key, key, v, skip)
+}
+
+func flattenPath(fields map[string]interface{}, key, path string, v interface{}, skip map[string]bool) {
+	if skip[path] {
+		return
+	}
+
+	switch value := v.(type) {
+	case nil:
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for k := range value {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			flattenPath(fields, key+"_"+k, path+"."+k, value[k], skip)
+		}
+	case []interface{}:
+		for i, elem := range value {
+			index := strconv.Itoa(i)
+			flattenPath(fields, key+"_"+index, path+"."+index, elem, skip)
+		}
+	case int32:
+		fields[key] = int64(value)
+	case uint32:
+		fields[key] = uint64(value)
+	case float32:
+		fields[key] = float64(value)
+	case []byte:
+		fields[key] = string(value)
+	case time.Time:
+		fields[key] = value.UnixNano()
+	default:
+		fields[key] = value
+	}
+}
+
+func toString(v interface{}) string {
+	switch value := v.(type) {
+	case string:
+		return value
+	case []byte:
+		return string(value)
+	default:
+		return fmt.Sprint(value)
+	}
+}
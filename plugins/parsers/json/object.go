@@ -0,0 +1,245 @@
+package json
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/filter"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/tidwall/gjson"
+)
+
+// Object selects JSON objects in the document and describes how each of
+// them is converted into a metric.  All paths other than Path are GJSON paths
+// relative to the selected object.
+type Object struct {
+	// Path is a GJSON path to an object or array of objects, nested arrays
+	// are expanded so that each object becomes a metric.
+	Path string `toml:"path"`
+
+	MeasurementName     string `toml:"measurement_name"`
+	MeasurementNamePath string `toml:"measurement_name_path"`
+
+	TimestampPath     string `toml:"timestamp_path"`
+	TimestampFormat   string `toml:"timestamp_format"`
+	TimestampTimezone string `toml:"timestamp_timezone"`
+
+	Tags []string `toml:"tags"`
+	// Fields maps paths to the type of the field, one of int, uint, float,
+	// string or bool.
+	Fields map[string]string `toml:"fields"`
+
+	IncludedKeys []string `toml:"included_keys"`
+	ExcludedKeys []string `toml:"excluded_keys"`
+}
+
+type objectParser struct {
+	Object
+
+	// allKeys is set when every value not otherwise used is added as field.
+	allKeys   bool
+	keyFilter filter.Filter
+	used      map[string]bool
+}
+
+func newObjectParser(o Object) (*objectParser, error) {
+	for path, typ := range o.Fields {
+		switch typ {
+		case "int", "uint", "float", "string", "bool":
+		default:
+			return nil, fmt.Errorf("invalid type %q for field %q", typ, path)
+		}
+	}
+
+	if o.TimestampPath != "" && o.TimestampFormat == "" {
+		return nil, fmt.Errorf("use of 'timestamp_path' requires 'timestamp_format'")
+	}
+
+	keyFilter, err := filter.NewIncludeExcludeFilter(o.IncludedKeys, o.ExcludedKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	used := map[string]bool{
+		keyName(o.MeasurementNamePath): true,
+		keyName(o.TimestampPath):       true,
+	}
+	for _, path := range o.Tags {
+		used[keyName(path)] = true
+	}
+	for path := range o.Fields {
+		used[keyName(path)] = true
+	}
+
+	return &objectParser{
+		Object:    o,
+		allKeys:   len(o.Fields) == 0 || len(o.IncludedKeys) > 0,
+		keyFilter: keyFilter,
+		used:      used,
+	}, nil
+}
+
+// parse returns a metric for each object selected in the document.
+func (p *objectParser) parse(buf []byte, metricName string, defaultTags map[string]string) ([]telegraf.Metric, error) {
+	var result gjson.Result
+	if p.Path == "" {
+		result = gjson.ParseBytes(buf)
+	} else {
+		result = gjson.GetBytes(buf, p.Path)
+		if !result.Exists() {
+			return nil, nil
+		}
+	}
+
+	metrics := make([]telegraf.Metric, 0)
+	err := p.expand(result, func(obj gjson.Result) error {
+		m, err := p.parseObject(obj, metricName, defaultTags)
+		if err != nil {
+			return err
+		}
+		metrics = append(metrics, m)
+		return nil
+	})
+	return metrics, err
+}
+
+// expand calls fn for every object in result, descending into arrays.
+func (p *objectParser) expand(result gjson.Result, fn func(gjson.Result) error) error {
+	switch {
+	case result.IsObject():
+		return fn(result)
+	case result.IsArray():
+		for _, elem := range result.Array() {
+			if err := p.expand(elem, fn); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return ErrWrongType
+	}
+}
+
+func (p *objectParser) parseObject(obj gjson.Result, metricName string, defaultTags map[string]string) (telegraf.Metric, error) {
+	name := metricName
+	if p.MeasurementName != "" {
+		name = p.MeasurementName
+	}
+	if p.MeasurementNamePath != "" {
+		if r := obj.Get(p.MeasurementNamePath); r.Exists() && r.String() != "" {
+			name = r.String()
+		}
+	}
+
+	tags := make(map[string]string, len(defaultTags)+len(p.Tags))
+	for k, v := range defaultTags {
+		tags[k] = v
+	}
+	for _, path := range p.Tags {
+		if r := obj.Get(path); r.Exists() && r.Type != gjson.Null {
+			tags[keyName(path)] = r.String()
+		}
+	}
+
+	fields := make(map[string]interface{})
+	if p.allKeys {
+		f := JSONFlattener{}
+		err := f.FullFlattenJSON("", obj.Value(), true, true)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range f.Fields {
+			if p.used[k] || !p.keyFilter.Match(k) {
+				continue
+			}
+			fields[k] = v
+		}
+	}
+
+	for path, typ := range p.Fields {
+		r := obj.Get(path)
+		if !r.Exists() || r.Type == gjson.Null {
+			continue
+		}
+		v, err := convertField(r, typ)
+		if err != nil {
+			return nil, fmt.Errorf("field %q: %v", path, err)
+		}
+		fields[keyName(path)] = v
+	}
+
+	tm := time.Now().UTC()
+	if p.TimestampPath != "" {
+		r := obj.Get(p.TimestampPath)
+		if !r.Exists() {
+			return nil, fmt.Errorf("JSON time key could not be found")
+		}
+
+		var ts interface{} = r.String()
+		if r.Type == gjson.Number {
+			// keep the full precision of large integer timestamps
+			ts = r.Raw
+		}
+
+		var err error
+		tm, err = internal.ParseTimestampWithLocation(ts, p.TimestampFormat, p.TimestampTimezone)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return metric.New(name, tags, fields, tm)
+}
+
+func convertField(r gjson.Result, typ string) (interface{}, error) {
+	switch typ {
+	case "string":
+		return r.String(), nil
+	case "bool":
+		return r.Bool(), nil
+	}
+
+	var s string
+	switch r.Type {
+	case gjson.Number:
+		s = r.Raw
+	case gjson.String:
+		s = r.Str
+	case gjson.True:
+		s = "1"
+	case gjson.False:
+		s = "0"
+	default:
+		return nil, fmt.Errorf("cannot convert %s to %s", r.Raw, typ)
+	}
+
+	switch typ {
+	case "int":
+		if v, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return v, nil
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		return int64(v), err
+	case "uint":
+		if v, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return v, nil
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		if v < 0 {
+			return nil, fmt.Errorf("cannot convert %s to uint", s)
+		}
+		return uint64(v), err
+	default:
+		return strconv.ParseFloat(s, 64)
+	}
+}
+
+// keyName returns the tag or field key for a path, matching the keys of
+// flattened objects.
+func keyName(path string) string {
+	return strings.Replace(path, ".", "_", -1)
+}
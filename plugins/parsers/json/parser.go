@@ -32,6 +32,9 @@ type Config struct {
 	TimeFormat   string
 	Timezone     string
 	DefaultTags  map[string]string
+
+	// Objects replaces the options above with object selectors when set.
+	Objects []Object
 }
 
 type Parser struct {
@@ -44,6 +47,7 @@ type Parser struct {
 	timeFormat   string
 	timezone     string
 	defaultTags  map[string]string
+	objects      []*objectParser
 }
 
 func New(config *Config) (*Parser, error) {
@@ -52,6 +56,15 @@ func New(config *Config) (*Parser, error) {
 		return nil, err
 	}
 
+	objects := make([]*objectParser, 0, len(config.Objects))
+	for _, o := range config.Objects {
+		op, err := newObjectParser(o)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, op)
+	}
+
 	return &Parser{
 		metricName:   config.MetricName,
 		tagKeys:      config.TagKeys,
@@ -62,6 +75,7 @@ func New(config *Config) (*Parser, error) {
 		timeFormat:   config.TimeFormat,
 		timezone:     config.Timezone,
 		defaultTags:  config.DefaultTags,
+		objects:      objects,
 	}, nil
 }
 
@@ -182,6 +196,10 @@ func (p *Parser) switchFieldToTag(tags map[string]string, fields map[string]inte
 }
 
 func (p *Parser) Parse(buf []byte) ([]telegraf.Metric, error) {
+	if len(p.objects) > 0 {
+		return p.parseObjects(buf)
+	}
+
 	if p.query != "" {
 		result := gjson.GetBytes(buf, p.query)
 		buf = []byte(result.Raw)
@@ -213,6 +231,28 @@ func (p *Parser) Parse(buf []byte) ([]telegraf.Metric, error) {
 	}
 }
 
+func (p *Parser) parseObjects(buf []byte) ([]telegraf.Metric, error) {
+	buf = bytes.TrimSpace(buf)
+	buf = bytes.TrimPrefix(buf, utf8BOM)
+	if len(buf) == 0 {
+		return make([]telegraf.Metric, 0), nil
+	}
+
+	if !gjson.ValidBytes(buf) {
+		return nil, errors.New("invalid JSON")
+	}
+
+	metrics := make([]telegraf.Metric, 0)
+	for _, o := range p.objects {
+		m, err := o.parse(buf, p.metricName, p.defaultTags)
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, m...)
+	}
+	return metrics, nil
+}
+
 func (p *Parser) ParseLine(line string) (telegraf.Metric, error) {
 	metrics, err := p.Parse([]byte(line + "\n"))
 
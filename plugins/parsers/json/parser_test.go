@@ -886,3 +886,167 @@ func TestParseArrayWithWrongType(t *testing.T) {
 	_, err = parser.Parse([]byte(data))
 	require.Error(t, err)
 }
+
+const nestedArraysJSON = `
+{
+    "site": "ams1",
+    "devices": [
+        {
+            "id": "dev1",
+            "sensors": [
+                {"type": "temperature", "ts": 1555745371410, "value": "21.5", "count": 3, "ok": true, "unit": "C"},
+                {"type": "humidity", "ts": 1555745371450, "value": 45, "count": 4, "ok": false, "unit": "%"}
+            ]
+        },
+        {
+            "id": "dev2",
+            "sensors": [
+                {"type": "temperature", "ts": 1555745371500, "value": 20, "count": 5, "ok": true, "unit": "C"}
+            ]
+        }
+    ]
+}
+`
+
+func TestObjectNestedArrays(t *testing.T) {
+	parser, err := New(&Config{
+		MetricName: "json",
+		Objects: []Object{
+			{
+				Path:                "devices.#.sensors",
+				MeasurementNamePath: "type",
+				TimestampPath:       "ts",
+				TimestampFormat:     "unix_ms",
+				Tags:                []string{"unit"},
+				Fields: map[string]string{
+					"value": "float",
+					"count": "int",
+					"ok":    "bool",
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	actual, err := parser.Parse([]byte(nestedArraysJSON))
+	require.NoError(t, err)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric(
+			"temperature",
+			map[string]string{"unit": "C"},
+			map[string]interface{}{
+				"value": 21.5,
+				"count": int64(3),
+				"ok":    true,
+			},
+			time.Unix(0, 1555745371410*int64(time.Millisecond)),
+		),
+		testutil.MustMetric(
+			"humidity",
+			map[string]string{"unit": "%"},
+			map[string]interface{}{
+				"value": 45.0,
+				"count": int64(4),
+				"ok":    false,
+			},
+			time.Unix(0, 1555745371450*int64(time.Millisecond)),
+		),
+		testutil.MustMetric(
+			"temperature",
+			map[string]string{"unit": "C"},
+			map[string]interface{}{
+				"value": 20.0,
+				"count": int64(5),
+				"ok":    true,
+			},
+			time.Unix(0, 1555745371500*int64(time.Millisecond)),
+		),
+	}
+	testutil.RequireMetricsEqual(t, expected, actual)
+}
+
+func TestObjectIncludeExcludeKeys(t *testing.T) {
+	parser, err := New(&Config{
+		MetricName:  "json",
+		DefaultTags: map[string]string{"source": "test"},
+		Objects: []Object{
+			{
+				Path:            "devices",
+				MeasurementName: "device",
+				Tags:            []string{"id"},
+				ExcludedKeys:    []string{"sensors_*_ts", "sensors_*_unit", "sensors_*_type"},
+			},
+			{
+				MeasurementName: "site",
+				Tags:            []string{"site"},
+				Fields:          map[string]string{"devices.#": "uint"},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	actual, err := parser.Parse([]byte(nestedArraysJSON))
+	require.NoError(t, err)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric(
+			"device",
+			map[string]string{"id": "dev1", "source": "test"},
+			map[string]interface{}{
+				"sensors_0_value": "21.5",
+				"sensors_0_count": 3.0,
+				"sensors_0_ok":    true,
+				"sensors_1_value": 45.0,
+				"sensors_1_count": 4.0,
+				"sensors_1_ok":    false,
+			},
+			time.Unix(0, 0),
+		),
+		testutil.MustMetric(
+			"device",
+			map[string]string{"id": "dev2", "source": "test"},
+			map[string]interface{}{
+				"sensors_0_value": 20.0,
+				"sensors_0_count": 5.0,
+				"sensors_0_ok":    true,
+			},
+			time.Unix(0, 0),
+		),
+		testutil.MustMetric(
+			"site",
+			map[string]string{"site": "ams1", "source": "test"},
+			map[string]interface{}{
+				"devices_#": uint64(2),
+			},
+			time.Unix(0, 0),
+		),
+	}
+	testutil.RequireMetricsEqual(t, expected, actual, testutil.IgnoreTime())
+}
+
+func TestObjectErrors(t *testing.T) {
+	_, err := New(&Config{
+		Objects: []Object{{Fields: map[string]string{"a": "integer"}}},
+	})
+	require.Error(t, err)
+
+	_, err = New(&Config{
+		Objects: []Object{{TimestampPath: "ts"}},
+	})
+	require.Error(t, err)
+
+	parser, err := New(&Config{
+		Objects: []Object{{Fields: map[string]string{"a": "int"}}},
+	})
+	require.NoError(t, err)
+
+	_, err = parser.Parse([]byte(`{"a": "five"}`))
+	require.Error(t, err)
+
+	_, err = parser.Parse([]byte(`{"a": 5`))
+	require.Error(t, err)
+
+	_, err = parser.Parse([]byte(`[{"a": 5}, 6]`))
+	require.Error(t, err)
+}
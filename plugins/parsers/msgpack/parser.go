@@ -0,0 +1,338 @@
+package msgpack
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+)
+
+var errInvalid = errors.New("invalid msgpack metric")
+
+// Parser decodes metrics written by the msgpack serializer, a sequence of
+// MessagePack arrays of [name, time, tags, fields, type].
+type Parser struct {
+	DefaultTags map[string]string
+}
+
+func NewParser() *Parser {
+	return &Parser{}
+}
+
+func (p *Parser) Parse(buf []byte) ([]telegraf.Metric, error) {
+	metrics := make([]telegraf.Metric, 0)
+	for len(buf) > 0 {
+		m, n, err := p.decode(buf)
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, m)
+		buf = buf[n:]
+	}
+	return metrics, nil
+}
+
+func (p *Parser) ParseLine(line string) (telegraf.Metric, error) {
+	metrics, err := p.Parse([]byte(line))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(metrics) < 1 {
+		return nil, fmt.Errorf("can not parse the line: %s, for data format: msgpack", line)
+	}
+
+	return metrics[0], nil
+}
+
+func (p *Parser) SetDefaultTags(tags map[string]string) {
+	p.DefaultTags = tags
+}
+
+// SplitFrame is a bufio.SplitFunc returning one encoded metric at a time, it
+// is used to read metrics from stream sockets.
+func (p *Parser) SplitFrame(data []byte, atEOF bool) (int, []byte, error) {
+	if len(data) == 0 {
+		return 0, nil, nil
+	}
+
+	_, n, err := p.decode(data)
+	if err == io.ErrUnexpectedEOF && !atEOF {
+		// request more data
+		return 0, nil, nil
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	return n, data[:n], nil
+}
+
+// decode returns the first metric of buf and the number of bytes read.
+func (p *Parser) decode(buf []byte) (telegraf.Metric, int, error) {
+	d := &decoder{buf: buf}
+
+	n, err := d.arrayHeader()
+	if err != nil {
+		return nil, 0, err
+	}
+	if n != 5 {
+		return nil, 0, errInvalid
+	}
+
+	name, err := d.string()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ts, err := d.int()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ntags, err := d.mapHeader()
+	if err != nil {
+		return nil, 0, err
+	}
+	// the header is untrusted, every entry takes at least two bytes
+	tags := make(map[string]string, d.capacity(ntags, 2)+len(p.DefaultTags))
+	for i := 0; i < ntags; i++ {
+		key, err := d.string()
+		if err != nil {
+			return nil, 0, err
+		}
+		value, err := d.string()
+		if err != nil {
+			return nil, 0, err
+		}
+		tags[key] = value
+	}
+
+	nfields, err := d.mapHeader()
+	if err != nil {
+		return nil, 0, err
+	}
+	fields := make(map[string]interface{}, d.capacity(nfields, 2))
+	for i := 0; i < nfields; i++ {
+		key, err := d.string()
+		if err != nil {
+			return nil, 0, err
+		}
+		value, err := d.value()
+		if err != nil {
+			return nil, 0, err
+		}
+		if value != nil {
+			fields[key] = value
+		}
+	}
+
+	tp, err := d.int()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for k, v := range p.DefaultTags {
+		if _, ok := tags[k]; !ok {
+			tags[k] = v
+		}
+	}
+
+	m, err := metric.New(name, tags, fields, time.Unix(0, ts), telegraf.ValueType(tp))
+	if err != nil {
+		return nil, 0, err
+	}
+	return m, d.pos, nil
+}
+
+type decoder struct {
+	buf []byte
+	pos int
+}
+
+func (d *decoder) next(n int) ([]byte, error) {
+	if d.pos+n > len(d.buf) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := d.buf[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+// capacity limits a count read from the input to the number of items of
+// size bytes that fit in the remaining input.
+func (d *decoder) capacity(n int, size int) int {
+	if max := (len(d.buf) - d.pos) / size; n > max {
+		return max
+	}
+	return n
+}
+
+func (d *decoder) byte() (byte, error) {
+	b, err := d.next(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+func (d *decoder) uint(size int) (uint64, error) {
+	b, err := d.next(size)
+	if err != nil {
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	default:
+		return binary.BigEndian.Uint64(b), nil
+	}
+}
+
+func (d *decoder) arrayHeader() (int, error) {
+	c, err := d.byte()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case c&0xf0 == 0x90:
+		return int(c & 0x0f), nil
+	case c == 0xdc:
+		n, err := d.uint(2)
+		return int(n), err
+	case c == 0xdd:
+		n, err := d.uint(4)
+		return int(n), err
+	}
+	return 0, errInvalid
+}
+
+func (d *decoder) mapHeader() (int, error) {
+	c, err := d.byte()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case c&0xf0 == 0x80:
+		return int(c & 0x0f), nil
+	case c == 0xde:
+		n, err := d.uint(2)
+		return int(n), err
+	case c == 0xdf:
+		n, err := d.uint(4)
+		return int(n), err
+	}
+	return 0, errInvalid
+}
+
+func (d *decoder) string() (string, error) {
+	v, err := d.value()
+	if err != nil {
+		return "", err
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", errInvalid
+	}
+	return s, nil
+}
+
+func (d *decoder) int() (int64, error) {
+	v, err := d.value()
+	if err != nil {
+		return 0, err
+	}
+	i, ok := v.(int64)
+	if !ok {
+		return 0, errInvalid
+	}
+	return i, nil
+}
+
+// value decodes a scalar value.  Fixint and the signed formats are returned
+// as int64, the unsigned formats as uint64 and nil for a nil value.
+func (d *decoder) value() (interface{}, error) {
+	c, err := d.byte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xe0 == 0xa0:
+		return d.str(int(c & 0x1f))
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xca:
+		v, err := d.uint(4)
+		return float64(math.Float32frombits(uint32(v))), err
+	case 0xcb:
+		v, err := d.uint(8)
+		return math.Float64frombits(v), err
+	case 0xcc:
+		return d.uint(1)
+	case 0xcd:
+		return d.uint(2)
+	case 0xce:
+		return d.uint(4)
+	case 0xcf:
+		return d.uint(8)
+	case 0xd0:
+		v, err := d.uint(1)
+		return int64(int8(v)), err
+	case 0xd1:
+		v, err := d.uint(2)
+		return int64(int16(v)), err
+	case 0xd2:
+		v, err := d.uint(4)
+		return int64(int32(v)), err
+	case 0xd3:
+		v, err := d.uint(8)
+		return int64(v), err
+	case 0xd9:
+		n, err := d.uint(1)
+		if err != nil {
+			return nil, err
+		}
+		return d.str(int(n))
+	case 0xda:
+		n, err := d.uint(2)
+		if err != nil {
+			return nil, err
+		}
+		return d.str(int(n))
+	case 0xdb:
+		n, err := d.uint(4)
+		if err != nil {
+			return nil, err
+		}
+		return d.str(int(n))
+	}
+	return nil, errInvalid
+}
+
+func (d *decoder) str(n int) (string, error) {
+	b, err := d.next(n)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
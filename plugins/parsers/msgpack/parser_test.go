@@ -0,0 +1,148 @@
+package msgpack
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/serializers/msgpack"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func testMetrics(t *testing.T) []telegraf.Metric {
+	m1, err := metric.New(
+		"cpu",
+		map[string]string{
+			"host": "server01",
+			"cpu":  "cpu0",
+		},
+		map[string]interface{}{
+			"usage_idle": 98.5,
+			"int":        int64(-42),
+			"small":      int64(3),
+			"big":        int64(math.MaxInt64),
+			"uint":       uint64(math.MaxUint64),
+			"ok":         true,
+			"state":      "a string that is longer than thirty one characters",
+		},
+		time.Unix(1556813561, 123456789),
+		telegraf.Counter,
+	)
+	require.NoError(t, err)
+
+	m2, err := metric.New(
+		"mem",
+		map[string]string{},
+		map[string]interface{}{
+			"used": uint64(7),
+		},
+		time.Unix(0, -1),
+	)
+	require.NoError(t, err)
+
+	return []telegraf.Metric{m1, m2}
+}
+
+func requireMetricsExactlyEqual(t *testing.T, expected, actual []telegraf.Metric) {
+	testutil.RequireMetricsEqual(t, expected, actual)
+	for i := range expected {
+		require.Equal(t, expected[i].Type(), actual[i].Type())
+		require.Equal(t, expected[i].Time().UnixNano(), actual[i].Time().UnixNano())
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	s, err := msgpack.NewSerializer()
+	require.NoError(t, err)
+
+	metrics := testMetrics(t)
+	buf, err := s.SerializeBatch(metrics)
+	require.NoError(t, err)
+
+	p := NewParser()
+	actual, err := p.Parse(buf)
+	require.NoError(t, err)
+	requireMetricsExactlyEqual(t, metrics, actual)
+}
+
+func TestDefaultTags(t *testing.T) {
+	s, err := msgpack.NewSerializer()
+	require.NoError(t, err)
+
+	buf, err := s.Serialize(testMetrics(t)[0])
+	require.NoError(t, err)
+
+	p := NewParser()
+	p.SetDefaultTags(map[string]string{"host": "default", "region": "eu"})
+	m, err := p.ParseLine(string(buf))
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"host": "server01", "cpu": "cpu0", "region": "eu"}, m.Tags())
+}
+
+func TestSplitFrame(t *testing.T) {
+	s, err := msgpack.NewSerializer()
+	require.NoError(t, err)
+
+	metrics := testMetrics(t)
+	var stream []byte
+	for _, m := range metrics {
+		buf, err := s.Serialize(m)
+		require.NoError(t, err)
+		stream = append(stream, buf...)
+	}
+
+	p := NewParser()
+
+	// the data is read one byte at a time to exercise partial frames
+	scanner := bufio.NewScanner(&oneByteReader{data: stream})
+	scanner.Split(p.SplitFrame)
+
+	var actual []telegraf.Metric
+	for scanner.Scan() {
+		m, err := p.Parse(scanner.Bytes())
+		require.NoError(t, err)
+		actual = append(actual, m...)
+	}
+	require.NoError(t, scanner.Err())
+	requireMetricsExactlyEqual(t, metrics, actual)
+}
+
+func TestParseErrors(t *testing.T) {
+	s, err := msgpack.NewSerializer()
+	require.NoError(t, err)
+
+	buf, err := s.Serialize(testMetrics(t)[0])
+	require.NoError(t, err)
+
+	p := NewParser()
+	_, err = p.Parse(buf[:len(buf)-1])
+	require.Error(t, err)
+
+	_, err = p.Parse([]byte{0x93, 0x01, 0x02, 0x03})
+	require.Error(t, err)
+
+	_, _, err = p.SplitFrame([]byte{0xc1}, false)
+	require.Error(t, err)
+
+	// map header with 2^32-1 entries and no data
+	_, err = p.Parse([]byte{0x95, 0xa1, 'm', 0x01, 0xdf, 0xff, 0xff, 0xff, 0xff})
+	require.Equal(t, io.ErrUnexpectedEOF, err)
+}
+
+type oneByteReader struct {
+	data []byte
+}
+
+func (r *oneByteReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, io.EOF
+	}
+	p[0] = r.data[0]
+	r.data = r.data[1:]
+	return 1, nil
+}
@@ -0,0 +1,282 @@
+package protobuf
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errShortBuffer = errors.New("unexpected end of data")
+
+// registry holds the message and enum types of a descriptor set by their
+// fully qualified name, prefixed with a dot as used in type references.
+type registry struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+}
+
+func newRegistry(set *descriptor.FileDescriptorSet) *registry {
+	r := &registry{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+	}
+	for _, file := range set.GetFile() {
+		prefix := ""
+		if file.GetPackage() != "" {
+			prefix = "." + file.GetPackage()
+		}
+		for _, msg := range file.GetMessageType() {
+			r.addMessage(prefix, msg)
+		}
+		for _, enum := range file.GetEnumType() {
+			r.enums[prefix+"."+enum.GetName()] = enum
+		}
+	}
+	return r
+}
+
+func (r *registry) addMessage(prefix string, msg *descriptor.DescriptorProto) {
+	name := prefix + "." + msg.GetName()
+	r.messages[name] = msg
+	for _, nested := range msg.GetNestedType() {
+		r.addMessage(name, nested)
+	}
+	for _, enum := range msg.GetEnumType() {
+		r.enums[name+"."+enum.GetName()] = enum
+	}
+}
+
+// decodeMessage decodes the wire format of msg into a record keyed by field
+// name.  Fields not in the descriptor are skipped.
+func (r *registry) decodeMessage(msg *descriptor.DescriptorProto, buf []byte) (map[string]interface{}, error) {
+	fields := make(map[int32]*descriptor.FieldDescriptorProto, len(msg.GetField()))
+	for _, f := range msg.GetField() {
+		fields[f.GetNumber()] = f
+	}
+
+	record := make(map[string]interface{})
+	for len(buf) > 0 {
+		tag, n := binary.Uvarint(buf)
+		if n <= 0 {
+			return nil, errShortBuffer
+		}
+		buf = buf[n:]
+
+		number, wireType := int32(tag>>3), int(tag&7)
+		raw, rest, err := readRaw(buf, wireType)
+		if err != nil {
+			return nil, err
+		}
+		buf = rest
+
+		f, ok := fields[number]
+		if !ok {
+			continue
+		}
+
+		values, err := r.decodeField(f, wireType, raw)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %v", f.GetName(), err)
+		}
+
+		if f.GetLabel() != descriptor.FieldDescriptorProto_LABEL_REPEATED {
+			record[f.GetName()] = values[len(values)-1]
+			continue
+		}
+
+		if entry, ok := r.messages[f.GetTypeName()]; ok && entry.GetOptions().GetMapEntry() {
+			m, _ := record[f.GetName()].(map[string]interface{})
+			if m == nil {
+				m = make(map[string]interface{})
+				record[f.GetName()] = m
+			}
+			for _, v := range values {
+				kv := v.(map[string]interface{})
+				m[fmt.Sprint(kv["key"])] = kv["value"]
+			}
+			continue
+		}
+
+		list, _ := record[f.GetName()].([]interface{})
+		record[f.GetName()] = append(list, values...)
+	}
+	return record, nil
+}
+
+// decodeField decodes the raw value of a field, packed repeated fields may
+// hold several values.
+func (r *registry) decodeField(f *descriptor.FieldDescriptorProto, wireType int, raw []byte) ([]interface{}, error) {
+	switch f.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_STRING,
+		descriptor.FieldDescriptorProto_TYPE_BYTES,
+		descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		if wireType != wireBytes {
+			return nil, fmt.Errorf("wire type %d does not match type %s", wireType, f.GetType())
+		}
+	}
+
+	switch f.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		return []interface{}{string(raw)}, nil
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return []interface{}{raw}, nil
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		msg, ok := r.messages[f.GetTypeName()]
+		if !ok {
+			return nil, fmt.Errorf("unknown message type %s", f.GetTypeName())
+		}
+		v, err := r.decodeMessage(msg, raw)
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{v}, nil
+	case descriptor.FieldDescriptorProto_TYPE_GROUP:
+		return nil, errors.New("groups are not supported")
+	}
+
+	if wireType != wireBytes {
+		if wireType != scalarWireType(f.GetType()) {
+			return nil, fmt.Errorf("wire type %d does not match type %s", wireType, f.GetType())
+		}
+		v, err := r.decodeScalar(f, raw)
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{v}, nil
+	}
+
+	// packed repeated scalars
+	elemWireType := scalarWireType(f.GetType())
+	values := make([]interface{}, 0)
+	for len(raw) > 0 {
+		elem, rest, err := readRaw(raw, elemWireType)
+		if err != nil {
+			return nil, err
+		}
+		raw = rest
+
+		v, err := r.decodeScalar(f, elem)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func (r *registry) decodeScalar(f *descriptor.FieldDescriptorProto, raw []byte) (interface{}, error) {
+	switch scalarWireType(f.GetType()) {
+	case wireFixed64:
+		if len(raw) < 8 {
+			return nil, errShortBuffer
+		}
+	case wireFixed32:
+		if len(raw) < 4 {
+			return nil, errShortBuffer
+		}
+	}
+
+	switch f.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		return math.Float64frombits(binary.LittleEndian.Uint64(raw)), nil
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return math.Float32frombits(binary.LittleEndian.Uint32(raw)), nil
+	case descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		return binary.LittleEndian.Uint64(raw), nil
+	case descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return int64(binary.LittleEndian.Uint64(raw)), nil
+	case descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		return binary.LittleEndian.Uint32(raw), nil
+	case descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return int32(binary.LittleEndian.Uint32(raw)), nil
+	}
+
+	v, _ := binary.Uvarint(raw)
+	switch f.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_INT64:
+		return int64(v), nil
+	case descriptor.FieldDescriptorProto_TYPE_UINT64:
+		return v, nil
+	case descriptor.FieldDescriptorProto_TYPE_INT32:
+		return int32(v), nil
+	case descriptor.FieldDescriptorProto_TYPE_UINT32:
+		return uint32(v), nil
+	case descriptor.FieldDescriptorProto_TYPE_SINT32:
+		return int32(zigzag(v)), nil
+	case descriptor.FieldDescriptorProto_TYPE_SINT64:
+		return zigzag(v), nil
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return v != 0, nil
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		if enum, ok := r.enums[f.GetTypeName()]; ok {
+			for _, ev := range enum.GetValue() {
+				if int64(ev.GetNumber()) == int64(int32(v)) {
+					return ev.GetName(), nil
+				}
+			}
+		}
+		return int64(int32(v)), nil
+	default:
+		return nil, fmt.Errorf("unsupported type %s", f.GetType())
+	}
+}
+
+func scalarWireType(t descriptor.FieldDescriptorProto_Type) int {
+	switch t {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE,
+		descriptor.FieldDescriptorProto_TYPE_FIXED64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return wireFixed64
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT,
+		descriptor.FieldDescriptorProto_TYPE_FIXED32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return wireFixed32
+	default:
+		return wireVarint
+	}
+}
+
+// readRaw splits the value of the given wire type from the start of buf.
+func readRaw(buf []byte, wireType int) ([]byte, []byte, error) {
+	switch wireType {
+	case wireVarint:
+		_, n := binary.Uvarint(buf)
+		if n <= 0 {
+			return nil, nil, errShortBuffer
+		}
+		return buf[:n], buf[n:], nil
+	case wireFixed64:
+		if len(buf) < 8 {
+			return nil, nil, errShortBuffer
+		}
+		return buf[:8], buf[8:], nil
+	case wireFixed32:
+		if len(buf) < 4 {
+			return nil, nil, errShortBuffer
+		}
+		return buf[:4], buf[4:], nil
+	case wireBytes:
+		size, n := binary.Uvarint(buf)
+		if n <= 0 || uint64(len(buf)-n) < size {
+			return nil, nil, errShortBuffer
+		}
+		end := n + int(size)
+		return buf[n:end], buf[end:], nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported wire type %d", wireType)
+	}
+}
+
+func zigzag(v uint64) int64 {
+	return int64(v>>1) ^ -int64(v&1)
+}
@@ -0,0 +1,109 @@
+package protobuf
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/parsers/internal/mapping"
+)
+
+type Config struct {
+	// DescriptorFile is the path of a FileDescriptorSet, as written by
+	// protoc --include_imports --descriptor_set_out.
+	DescriptorFile string
+	// MessageType is the fully qualified name of the message to decode.
+	MessageType string
+
+	MetricName       string
+	MeasurementField string
+	Tags             []string
+	Fields           []string
+	Timestamp        string
+	TimestampFormat  string
+	DefaultTags      map[string]string
+}
+
+// Parser decodes Protobuf encoded messages of a single message type.
+type Parser struct {
+	registry *registry
+	message  *descriptor.DescriptorProto
+	mapping  mapping.Mapping
+}
+
+func New(config *Config) (*Parser, error) {
+	if config.DescriptorFile == "" {
+		return nil, errors.New("protobuf_descriptor_file must be set")
+	}
+	if config.MessageType == "" {
+		return nil, errors.New("protobuf_message_type must be set")
+	}
+
+	buf, err := ioutil.ReadFile(config.DescriptorFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var set descriptor.FileDescriptorSet
+	if err := proto.Unmarshal(buf, &set); err != nil {
+		return nil, fmt.Errorf("%s: %v", config.DescriptorFile, err)
+	}
+
+	r := newRegistry(&set)
+	msg, ok := r.messages["."+strings.TrimPrefix(config.MessageType, ".")]
+	if !ok {
+		return nil, fmt.Errorf("message type %s not found in %s", config.MessageType, config.DescriptorFile)
+	}
+
+	return &Parser{
+		registry: r,
+		message:  msg,
+		mapping: mapping.Mapping{
+			MetricName:       config.MetricName,
+			MeasurementField: config.MeasurementField,
+			Tags:             config.Tags,
+			Fields:           config.Fields,
+			Timestamp:        config.Timestamp,
+			TimestampFormat:  config.TimestampFormat,
+			DefaultTags:      config.DefaultTags,
+			TimeFunc:         time.Now,
+		},
+	}, nil
+}
+
+// Parse decodes buf as a single message, the Protobuf encoding does not
+// delimit messages.
+func (p *Parser) Parse(buf []byte) ([]telegraf.Metric, error) {
+	record, err := p.registry.decodeMessage(p.message, buf)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := p.mapping.Metric(record)
+	if err != nil {
+		return nil, err
+	}
+	return []telegraf.Metric{m}, nil
+}
+
+func (p *Parser) ParseLine(line string) (telegraf.Metric, error) {
+	metrics, err := p.Parse([]byte(line))
+	if err != nil {
+		return nil, err
+	}
+	return metrics[0], nil
+}
+
+func (p *Parser) SetDefaultTags(tags map[string]string) {
+	p.mapping.DefaultTags = tags
+}
+
+func (p *Parser) SetTimeFunc(fn metric.TimeFunc) {
+	p.mapping.TimeFunc = fn
+}
@@ -0,0 +1,276 @@
+package protobuf
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func field(name string, number int32, typ descriptor.FieldDescriptorProto_Type, typeName string) *descriptor.FieldDescriptorProto {
+	f := &descriptor.FieldDescriptorProto{
+		Name:   proto.String(name),
+		Number: proto.Int32(number),
+		Label:  descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:   typ.Enum(),
+	}
+	if typeName != "" {
+		f.TypeName = proto.String(typeName)
+	}
+	return f
+}
+
+func repeated(f *descriptor.FieldDescriptorProto) *descriptor.FieldDescriptorProto {
+	f.Label = descriptor.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	return f
+}
+
+// writeDescriptor writes the descriptor set of:
+//
+//	package example;
+//	message Reading {
+//	  message Device { string site = 1; }
+//	  enum Status { UNKNOWN = 0; OK = 1; }
+//	  string name = 1;
+//	  int64 time = 2;
+//	  Device device = 3;
+//	  double value = 4;
+//	  repeated sint32 samples = 5;
+//	  map<string, string> labels = 6;
+//	  Status status = 7;
+//	  uint64 bytes = 8;
+//	}
+func writeDescriptor(t *testing.T) string {
+	set := &descriptor.FileDescriptorSet{
+		File: []*descriptor.FileDescriptorProto{
+			{
+				Name:    proto.String("example.proto"),
+				Package: proto.String("example"),
+				MessageType: []*descriptor.DescriptorProto{
+					{
+						Name: proto.String("Reading"),
+						Field: []*descriptor.FieldDescriptorProto{
+							field("name", 1, descriptor.FieldDescriptorProto_TYPE_STRING, ""),
+							field("time", 2, descriptor.FieldDescriptorProto_TYPE_INT64, ""),
+							field("device", 3, descriptor.FieldDescriptorProto_TYPE_MESSAGE, ".example.Reading.Device"),
+							field("value", 4, descriptor.FieldDescriptorProto_TYPE_DOUBLE, ""),
+							repeated(field("samples", 5, descriptor.FieldDescriptorProto_TYPE_SINT32, "")),
+							repeated(field("labels", 6, descriptor.FieldDescriptorProto_TYPE_MESSAGE, ".example.Reading.LabelsEntry")),
+							field("status", 7, descriptor.FieldDescriptorProto_TYPE_ENUM, ".example.Reading.Status"),
+							field("bytes", 8, descriptor.FieldDescriptorProto_TYPE_UINT64, ""),
+						},
+						NestedType: []*descriptor.DescriptorProto{
+							{
+								Name: proto.String("Device"),
+								Field: []*descriptor.FieldDescriptorProto{
+									field("site", 1, descriptor.FieldDescriptorProto_TYPE_STRING, ""),
+								},
+							},
+							{
+								Name: proto.String("LabelsEntry"),
+								Field: []*descriptor.FieldDescriptorProto{
+									field("key", 1, descriptor.FieldDescriptorProto_TYPE_STRING, ""),
+									field("value", 2, descriptor.FieldDescriptorProto_TYPE_STRING, ""),
+								},
+								Options: &descriptor.MessageOptions{MapEntry: proto.Bool(true)},
+							},
+						},
+						EnumType: []*descriptor.EnumDescriptorProto{
+							{
+								Name: proto.String("Status"),
+								Value: []*descriptor.EnumValueDescriptorProto{
+									{Name: proto.String("UNKNOWN"), Number: proto.Int32(0)},
+									{Name: proto.String("OK"), Number: proto.Int32(1)},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	buf, err := proto.Marshal(set)
+	require.NoError(t, err)
+
+	f, err := ioutil.TempFile("", "descriptor")
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = f.Write(buf)
+	require.NoError(t, err)
+	return f.Name()
+}
+
+type encoder []byte
+
+func (e encoder) varint(v uint64) encoder {
+	buf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(buf, v)
+	return append(e, buf[:n]...)
+}
+
+func (e encoder) key(number int, wireType int) encoder {
+	return e.varint(uint64(number<<3 | wireType))
+}
+
+func (e encoder) bytes(number int, b []byte) encoder {
+	return append(e.key(number, wireBytes).varint(uint64(len(b))), b...)
+}
+
+func (e encoder) double(number int, v float64) encoder {
+	buf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buf, math.Float64bits(v))
+	return append(e.key(number, wireFixed64), buf...)
+}
+
+func testMessage() []byte {
+	var e encoder
+	e = e.bytes(1, []byte("climate"))
+	e = e.key(2, wireVarint).varint(1500000000)
+	e = e.bytes(3, encoder{}.bytes(1, []byte("ams1")))
+	e = e.double(4, 21.5)
+	// packed sint32 -1, 2
+	e = e.bytes(5, encoder{}.varint(1).varint(4))
+	e = e.bytes(6, encoder{}.bytes(1, []byte("rack")).bytes(2, []byte("r1")))
+	e = e.key(7, wireVarint).varint(1)
+	e = e.key(8, wireVarint).varint(math.MaxUint64)
+	// unknown field
+	e = e.key(99, wireVarint).varint(1)
+	return e
+}
+
+func TestParse(t *testing.T) {
+	descriptorFile := writeDescriptor(t)
+	defer os.Remove(descriptorFile)
+
+	parser, err := New(&Config{
+		DescriptorFile:   descriptorFile,
+		MessageType:      "example.Reading",
+		MetricName:       "protobuf",
+		MeasurementField: "name",
+		Tags:             []string{"device.site", "status"},
+		Timestamp:        "time",
+		TimestampFormat:  "unix",
+	})
+	require.NoError(t, err)
+
+	metrics, err := parser.Parse(testMessage())
+	require.NoError(t, err)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric(
+			"climate",
+			map[string]string{
+				"device_site": "ams1",
+				"status":      "OK",
+			},
+			map[string]interface{}{
+				"value":       21.5,
+				"samples_0":   int64(-1),
+				"samples_1":   int64(2),
+				"labels_rack": "r1",
+				"bytes":       uint64(math.MaxUint64),
+			},
+			time.Unix(1500000000, 0),
+		),
+	}
+	testutil.RequireMetricsEqual(t, expected, metrics)
+}
+
+func TestParseUnpackedRepeated(t *testing.T) {
+	descriptorFile := writeDescriptor(t)
+	defer os.Remove(descriptorFile)
+
+	parser, err := New(&Config{
+		DescriptorFile: descriptorFile,
+		MessageType:    ".example.Reading",
+		MetricName:     "protobuf",
+		Fields:         []string{"samples"},
+	})
+	require.NoError(t, err)
+	parser.SetTimeFunc(func() time.Time { return time.Unix(42, 0) })
+
+	var e encoder
+	e = e.key(5, wireVarint).varint(1)
+	e = e.key(5, wireVarint).varint(4)
+
+	metrics, err := parser.Parse(e)
+	require.NoError(t, err)
+
+	testutil.RequireMetricsEqual(t, []telegraf.Metric{
+		testutil.MustMetric(
+			"protobuf",
+			map[string]string{},
+			map[string]interface{}{
+				"samples_0": int64(-1),
+				"samples_1": int64(2),
+			},
+			time.Unix(42, 0),
+		),
+	}, metrics)
+}
+
+func TestUnknownMessageType(t *testing.T) {
+	descriptorFile := writeDescriptor(t)
+	defer os.Remove(descriptorFile)
+
+	_, err := New(&Config{
+		DescriptorFile: descriptorFile,
+		MessageType:    "example.Missing",
+	})
+	require.Error(t, err)
+}
+
+func TestParseTruncated(t *testing.T) {
+	descriptorFile := writeDescriptor(t)
+	defer os.Remove(descriptorFile)
+
+	parser, err := New(&Config{
+		DescriptorFile: descriptorFile,
+		MessageType:    "example.Reading",
+		MetricName:     "protobuf",
+	})
+	require.NoError(t, err)
+
+	buf := testMessage()
+	_, err = parser.Parse(buf[:len(buf)-5])
+	require.Error(t, err)
+}
+
+func TestParseWireTypeMismatch(t *testing.T) {
+	descriptorFile := writeDescriptor(t)
+	defer os.Remove(descriptorFile)
+
+	parser, err := New(&Config{
+		DescriptorFile: descriptorFile,
+		MessageType:    "example.Reading",
+		MetricName:     "protobuf",
+	})
+	require.NoError(t, err)
+
+	for _, buf := range [][]byte{
+		// double value as varint
+		{4<<3 | wireVarint, 0x01},
+		// double value as fixed32
+		{4<<3 | wireFixed32, 0x01, 0x02, 0x03, 0x04},
+		// string name as varint
+		{1<<3 | wireVarint, 0x01},
+		// int64 time as fixed64
+		{2<<3 | wireFixed64, 1, 2, 3, 4, 5, 6, 7, 8},
+	} {
+		_, err = parser.Parse(buf)
+		require.Error(t, err)
+	}
+
+	// packed doubles with a truncated element
+	_, err = parser.Parse([]byte{4<<3 | wireBytes, 0x03, 0x01, 0x02, 0x03})
+	require.Error(t, err)
+}
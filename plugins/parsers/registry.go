@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/parsers/avro"
 	"github.com/influxdata/telegraf/plugins/parsers/collectd"
 	"github.com/influxdata/telegraf/plugins/parsers/csv"
 	"github.com/influxdata/telegraf/plugins/parsers/dropwizard"
@@ -14,7 +15,9 @@ import (
 	"github.com/influxdata/telegraf/plugins/parsers/influx"
 	"github.com/influxdata/telegraf/plugins/parsers/json"
 	"github.com/influxdata/telegraf/plugins/parsers/logfmt"
+	"github.com/influxdata/telegraf/plugins/parsers/msgpack"
 	"github.com/influxdata/telegraf/plugins/parsers/nagios"
+	"github.com/influxdata/telegraf/plugins/parsers/protobuf"
 	"github.com/influxdata/telegraf/plugins/parsers/value"
 	"github.com/influxdata/telegraf/plugins/parsers/wavefront"
 )
@@ -57,6 +60,16 @@ type Parser interface {
 	SetDefaultTags(tags map[string]string)
 }
 
+// FramedParser is implemented by parsers of binary formats that are not
+// newline delimited.  Stream based inputs use SplitFrame to split the
+// incoming data into units that can be passed to Parse.
+type FramedParser interface {
+	Parser
+
+	// SplitFrame is a bufio.SplitFunc returning a single frame.
+	SplitFrame(data []byte, atEOF bool) (advance int, token []byte, err error)
+}
+
 // Config is a struct that covers the data types needed for all parser types,
 // and can be used to instantiate _any_ of the parsers.
 type Config struct {
@@ -89,6 +102,9 @@ type Config struct {
 	// default timezone
 	JSONTimezone string `toml:"json_timezone"`
 
+	// object selectors for json parser, replaces the other json options
+	JSONObjects []json.Object `toml:"json_object"`
+
 	// Authentication file for collectd
 	CollectdAuthFile string `toml:"collectd_auth_file"`
 	// One of none (default), sign, or encrypt
@@ -145,6 +161,24 @@ type Config struct {
 
 	// FormData configuration
 	FormUrlencodedTagKeys []string `toml:"form_urlencoded_tag_keys"`
+
+	// avro configuration
+	AvroSchemaFile       string   `toml:"avro_schema_file"`
+	AvroSchemaRegistry   string   `toml:"avro_schema_registry"`
+	AvroMeasurementField string   `toml:"avro_measurement_field"`
+	AvroTags             []string `toml:"avro_tags"`
+	AvroFields           []string `toml:"avro_fields"`
+	AvroTimestamp        string   `toml:"avro_timestamp"`
+	AvroTimestampFormat  string   `toml:"avro_timestamp_format"`
+
+	// protobuf configuration
+	ProtobufDescriptorFile   string   `toml:"protobuf_descriptor_file"`
+	ProtobufMessageType      string   `toml:"protobuf_message_type"`
+	ProtobufMeasurementField string   `toml:"protobuf_measurement_field"`
+	ProtobufTags             []string `toml:"protobuf_tags"`
+	ProtobufFields           []string `toml:"protobuf_fields"`
+	ProtobufTimestamp        string   `toml:"protobuf_timestamp"`
+	ProtobufTimestampFormat  string   `toml:"protobuf_timestamp_format"`
 }
 
 // NewParser returns a Parser interface based on the given config.
@@ -164,6 +198,7 @@ func NewParser(config *Config) (Parser, error) {
 				TimeFormat:   config.JSONTimeFormat,
 				Timezone:     config.JSONTimezone,
 				DefaultTags:  config.DefaultTags,
+				Objects:      config.JSONObjects,
 			},
 		)
 	case "value":
@@ -223,6 +258,36 @@ func NewParser(config *Config) (Parser, error) {
 			config.DefaultTags,
 			config.FormUrlencodedTagKeys,
 		)
+	case "avro":
+		parser, err = avro.New(
+			&avro.Config{
+				SchemaFile:       config.AvroSchemaFile,
+				SchemaRegistry:   config.AvroSchemaRegistry,
+				MetricName:       config.MetricName,
+				MeasurementField: config.AvroMeasurementField,
+				Tags:             config.AvroTags,
+				Fields:           config.AvroFields,
+				Timestamp:        config.AvroTimestamp,
+				TimestampFormat:  config.AvroTimestampFormat,
+				DefaultTags:      config.DefaultTags,
+			},
+		)
+	case "protobuf":
+		parser, err = protobuf.New(
+			&protobuf.Config{
+				DescriptorFile:   config.ProtobufDescriptorFile,
+				MessageType:      config.ProtobufMessageType,
+				MetricName:       config.MetricName,
+				MeasurementField: config.ProtobufMeasurementField,
+				Tags:             config.ProtobufTags,
+				Fields:           config.ProtobufFields,
+				Timestamp:        config.ProtobufTimestamp,
+				TimestampFormat:  config.ProtobufTimestampFormat,
+				DefaultTags:      config.DefaultTags,
+			},
+		)
+	case "msgpack":
+		parser, err = NewMsgpackParser(config.DefaultTags)
 	default:
 		err = fmt.Errorf("Invalid data format: %s", config.DataFormat)
 	}
@@ -373,6 +438,12 @@ func NewLogFmtParser(metricName string, defaultTags map[string]string) (Parser,
 	return logfmt.NewParser(metricName, defaultTags), nil
 }
 
+func NewMsgpackParser(defaultTags map[string]string) (Parser, error) {
+	parser := msgpack.NewParser()
+	parser.SetDefaultTags(defaultTags)
+	return parser, nil
+}
+
 func NewWavefrontParser(defaultTags map[string]string) (Parser, error) {
 	return wavefront.NewWavefrontParser(defaultTags), nil
 }
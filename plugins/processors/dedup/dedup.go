@@ -0,0 +1,100 @@
+package dedup
+
+import (
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/processors"
+)
+
+var sampleConfig = `
+  ## Maximum time to suppress output of an unchanged series, after this
+  ## interval the metric is emitted even if its fields did not change.
+  dedup_interval = "600s"
+`
+
+type Dedup struct {
+	DedupInterval internal.Duration `toml:"dedup_interval"`
+
+	cache       map[uint64]*series
+	lastCleanup time.Time
+	now         func() time.Time
+}
+
+// series is the last metric emitted for a series.
+type series struct {
+	fields map[string]interface{}
+	time   time.Time
+}
+
+func (d *Dedup) SampleConfig() string {
+	return sampleConfig
+}
+
+func (d *Dedup) Description() string {
+	return "Filter metrics with repeating field values"
+}
+
+func (d *Dedup) Apply(in ...telegraf.Metric) []telegraf.Metric {
+	d.cleanup()
+
+	out := in[:0]
+	for _, m := range in {
+		id := m.HashID()
+		last, ok := d.cache[id]
+		if ok && m.Time().Sub(last.time) < d.DedupInterval.Duration && sameFields(last.fields, m) {
+			m.Drop()
+			continue
+		}
+
+		d.cache[id] = &series{fields: m.Fields(), time: m.Time()}
+		out = append(out, m)
+	}
+	return out
+}
+
+// cleanup removes the series not emitted within the dedup interval, their next
+// metric is emitted anyway so the entry is no longer needed.  This bounds the
+// cache to the series seen during the last interval.
+func (d *Dedup) cleanup() {
+	now := d.now()
+	if now.Sub(d.lastCleanup) < d.DedupInterval.Duration {
+		return
+	}
+	d.lastCleanup = now
+
+	for id, s := range d.cache {
+		if now.Sub(s.time) >= d.DedupInterval.Duration {
+			delete(d.cache, id)
+		}
+	}
+}
+
+func sameFields(fields map[string]interface{}, m telegraf.Metric) bool {
+	list := m.FieldList()
+	if len(list) != len(fields) {
+		return false
+	}
+	for _, field := range list {
+		v, ok := fields[field.Key]
+		if !ok || v != field.Value {
+			return false
+		}
+	}
+	return true
+}
+
+func newDedup() *Dedup {
+	return &Dedup{
+		DedupInterval: internal.Duration{Duration: 10 * time.Minute},
+		cache:         make(map[uint64]*series),
+		now:           time.Now,
+	}
+}
+
+func init() {
+	processors.Add("dedup", func() telegraf.Processor {
+		return newDedup()
+	})
+}
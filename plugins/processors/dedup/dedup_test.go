@@ -0,0 +1,72 @@
+package dedup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestDedup() *Dedup {
+	d := newDedup()
+	d.now = func() time.Time { return start }
+	return d
+}
+
+func cpu(host string, value interface{}, ts time.Time) telegraf.Metric {
+	return testutil.MustMetric(
+		"cpu",
+		map[string]string{"host": host},
+		map[string]interface{}{"value": value},
+		ts,
+	)
+}
+
+func TestDropUnchanged(t *testing.T) {
+	d := newTestDedup()
+
+	out := d.Apply(cpu("a", 42.0, start), cpu("b", 42.0, start))
+	require.Len(t, out, 2)
+
+	out = d.Apply(cpu("a", 42.0, start.Add(time.Minute)), cpu("b", 43.0, start.Add(time.Minute)))
+	testutil.RequireMetricsEqual(t, []telegraf.Metric{cpu("b", 43.0, start.Add(time.Minute))}, out)
+}
+
+func TestFieldTypeAndSetChanges(t *testing.T) {
+	d := newTestDedup()
+
+	require.Len(t, d.Apply(cpu("a", int64(42), start)), 1)
+	require.Len(t, d.Apply(cpu("a", 42.0, start.Add(time.Second))), 1)
+
+	m := cpu("a", 42.0, start.Add(2*time.Second))
+	m.AddField("other", "x")
+	require.Len(t, d.Apply(m), 1)
+}
+
+func TestRefreshAfterInterval(t *testing.T) {
+	d := newTestDedup()
+
+	require.Len(t, d.Apply(cpu("a", 42.0, start)), 1)
+	require.Len(t, d.Apply(cpu("a", 42.0, start.Add(5*time.Minute))), 0)
+	require.Len(t, d.Apply(cpu("a", 42.0, start.Add(9*time.Minute))), 0)
+
+	// the interval is measured from the last emitted metric
+	require.Len(t, d.Apply(cpu("a", 42.0, start.Add(10*time.Minute))), 1)
+	require.Len(t, d.Apply(cpu("a", 42.0, start.Add(11*time.Minute))), 0)
+}
+
+func TestExpireIdleSeries(t *testing.T) {
+	d := newTestDedup()
+
+	d.Apply(cpu("a", 42.0, start), cpu("b", 42.0, start))
+	require.Len(t, d.cache, 2)
+
+	d.now = func() time.Time { return start.Add(10 * time.Minute) }
+	d.Apply(cpu("b", 43.0, start.Add(10*time.Minute)))
+	require.Len(t, d.cache, 1)
+	require.Contains(t, d.cache, cpu("b", 0.0, start).HashID())
+}
@@ -0,0 +1,425 @@
+package expression
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var (
+	errDivisionByZero = errors.New("division by zero")
+	errNotFinite      = errors.New("result is not a finite number")
+)
+
+// node is a node of a parsed expression.
+type node interface {
+	eval(fields map[string]interface{}) (float64, error)
+}
+
+type number float64
+
+func (n number) eval(map[string]interface{}) (float64, error) {
+	return float64(n), nil
+}
+
+type fieldRef string
+
+func (f fieldRef) eval(fields map[string]interface{}) (float64, error) {
+	value, ok := fields[string(f)]
+	if !ok {
+		return 0, fmt.Errorf("field %q not found", string(f))
+	}
+
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case int64:
+		return float64(v), nil
+	case uint64:
+		return float64(v), nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return 0, fmt.Errorf("field %q is not numeric", string(f))
+}
+
+type negate struct {
+	x node
+}
+
+func (n negate) eval(fields map[string]interface{}) (float64, error) {
+	x, err := n.x.eval(fields)
+	return -x, err
+}
+
+type binary struct {
+	op   byte
+	x, y node
+}
+
+func (b binary) eval(fields map[string]interface{}) (float64, error) {
+	x, err := b.x.eval(fields)
+	if err != nil {
+		return 0, err
+	}
+	y, err := b.y.eval(fields)
+	if err != nil {
+		return 0, err
+	}
+
+	switch b.op {
+	case '+':
+		return x + y, nil
+	case '-':
+		return x - y, nil
+	case '*':
+		return x * y, nil
+	case '/':
+		if y == 0 {
+			return 0, errDivisionByZero
+		}
+		return x / y, nil
+	case '%':
+		if y == 0 {
+			return 0, errDivisionByZero
+		}
+		return math.Mod(x, y), nil
+	case '^':
+		return math.Pow(x, y), nil
+	}
+	return 0, fmt.Errorf("unknown operator %q", b.op)
+}
+
+type function struct {
+	minArgs int
+	maxArgs int // -1 for any number of arguments
+	fn      func(args []float64) float64
+}
+
+var functions = map[string]function{
+	"abs":   {minArgs: 1, maxArgs: 1, fn: func(a []float64) float64 { return math.Abs(a[0]) }},
+	"ceil":  {minArgs: 1, maxArgs: 1, fn: func(a []float64) float64 { return math.Ceil(a[0]) }},
+	"exp":   {minArgs: 1, maxArgs: 1, fn: func(a []float64) float64 { return math.Exp(a[0]) }},
+	"floor": {minArgs: 1, maxArgs: 1, fn: func(a []float64) float64 { return math.Floor(a[0]) }},
+	"log":   {minArgs: 1, maxArgs: 1, fn: func(a []float64) float64 { return math.Log(a[0]) }},
+	"log10": {minArgs: 1, maxArgs: 1, fn: func(a []float64) float64 { return math.Log10(a[0]) }},
+	"log2":  {minArgs: 1, maxArgs: 1, fn: func(a []float64) float64 { return math.Log2(a[0]) }},
+	"pow":   {minArgs: 2, maxArgs: 2, fn: func(a []float64) float64 { return math.Pow(a[0], a[1]) }},
+	"round": {minArgs: 1, maxArgs: 1, fn: func(a []float64) float64 { return math.Round(a[0]) }},
+	"sqrt":  {minArgs: 1, maxArgs: 1, fn: func(a []float64) float64 { return math.Sqrt(a[0]) }},
+	"max": {minArgs: 1, maxArgs: -1, fn: func(a []float64) float64 {
+		m := a[0]
+		for _, v := range a[1:] {
+			m = math.Max(m, v)
+		}
+		return m
+	}},
+	"min": {minArgs: 1, maxArgs: -1, fn: func(a []float64) float64 {
+		m := a[0]
+		for _, v := range a[1:] {
+			m = math.Min(m, v)
+		}
+		return m
+	}},
+}
+
+type call struct {
+	fn   function
+	args []node
+}
+
+func (c call) eval(fields map[string]interface{}) (float64, error) {
+	args := make([]float64, len(c.args))
+	for i, arg := range c.args {
+		v, err := arg.eval(fields)
+		if err != nil {
+			return 0, err
+		}
+		args[i] = v
+	}
+	return c.fn.fn(args), nil
+}
+
+// expression is a compiled arithmetic expression over the fields of a
+// metric.
+type expression struct {
+	root node
+}
+
+// eval evaluates the expression, it returns an error if a field is missing,
+// on division by zero, or if the result is infinite or NaN.
+func (e *expression) eval(fields map[string]interface{}) (float64, error) {
+	v, err := e.root.eval(fields)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, errNotFinite
+	}
+	return v, nil
+}
+
+// parse compiles an expression.  The grammar is:
+//
+//	expr    = term { ("+" | "-") term }
+//	term    = unary { ("*" | "/" | "%") unary }
+//	unary   = "-" unary | power
+//	power   = primary [ "^" unary ]
+//	primary = number | field | name "(" expr { "," expr } ")" | "(" expr ")"
+//
+// Fields are identifiers, or any name quoted in backticks.
+func parse(s string) (*expression, error) {
+	p := &parser{input: s}
+	p.next()
+	root, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.errorf("unexpected %s", p.tok)
+	}
+	return &expression{root: root}, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokIdent
+	tokField
+	tokOp
+	tokError
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	pos   int
+	value float64
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of expression"
+	}
+	return strconv.Quote(t.text)
+}
+
+type parser struct {
+	input string
+	pos   int
+	tok   token
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s at position %d in %q", fmt.Sprintf(format, args...), p.tok.pos+1, p.input)
+}
+
+func isIdentStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func isIdent(r rune) bool {
+	return r == '_' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// next reads the next token into p.tok.
+func (p *parser) next() {
+	for p.pos < len(p.input) && unicode.IsSpace(rune(p.input[p.pos])) {
+		p.pos++
+	}
+
+	start := p.pos
+	if p.pos >= len(p.input) {
+		p.tok = token{kind: tokEOF, pos: start}
+		return
+	}
+
+	c := p.input[p.pos]
+	switch {
+	case c >= '0' && c <= '9' || c == '.':
+		end := p.pos
+		for end < len(p.input) && (p.input[end] >= '0' && p.input[end] <= '9' || p.input[end] == '.') {
+			end++
+		}
+		// exponent
+		if end < len(p.input) && (p.input[end] == 'e' || p.input[end] == 'E') {
+			e := end + 1
+			if e < len(p.input) && (p.input[e] == '+' || p.input[e] == '-') {
+				e++
+			}
+			if e < len(p.input) && p.input[e] >= '0' && p.input[e] <= '9' {
+				for e < len(p.input) && p.input[e] >= '0' && p.input[e] <= '9' {
+					e++
+				}
+				end = e
+			}
+		}
+		text := p.input[p.pos:end]
+		p.pos = end
+		v, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			p.tok = token{kind: tokError, text: text, pos: start}
+			return
+		}
+		p.tok = token{kind: tokNumber, text: text, pos: start, value: v}
+	case c == '`':
+		end := strings.IndexByte(p.input[p.pos+1:], '`')
+		if end < 0 {
+			p.tok = token{kind: tokError, text: p.input[p.pos:], pos: start}
+			p.pos = len(p.input)
+			return
+		}
+		p.tok = token{kind: tokField, text: p.input[p.pos+1 : p.pos+1+end], pos: start}
+		p.pos += end + 2
+	case isIdentStart(rune(c)):
+		end := strings.IndexFunc(p.input[p.pos:], func(r rune) bool { return !isIdent(r) })
+		if end < 0 {
+			end = len(p.input) - p.pos
+		}
+		p.tok = token{kind: tokIdent, text: p.input[p.pos : p.pos+end], pos: start}
+		p.pos += end
+	case strings.IndexByte("+-*/%^(),", c) >= 0:
+		p.tok = token{kind: tokOp, text: string(c), pos: start}
+		p.pos++
+	default:
+		p.tok = token{kind: tokError, text: string(c), pos: start}
+		p.pos++
+	}
+}
+
+func (p *parser) isOp(op string) bool {
+	return p.tok.kind == tokOp && p.tok.text == op
+}
+
+func (p *parser) expr() (node, error) {
+	x, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("+") || p.isOp("-") {
+		op := p.tok.text[0]
+		p.next()
+		y, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		x = binary{op: op, x: x, y: y}
+	}
+	return x, nil
+}
+
+func (p *parser) term() (node, error) {
+	x, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("*") || p.isOp("/") || p.isOp("%") {
+		op := p.tok.text[0]
+		p.next()
+		y, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		x = binary{op: op, x: x, y: y}
+	}
+	return x, nil
+}
+
+func (p *parser) unary() (node, error) {
+	if p.isOp("-") {
+		p.next()
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return negate{x: x}, nil
+	}
+	return p.power()
+}
+
+func (p *parser) power() (node, error) {
+	x, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	if p.isOp("^") {
+		p.next()
+		y, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		x = binary{op: '^', x: x, y: y}
+	}
+	return x, nil
+}
+
+func (p *parser) primary() (node, error) {
+	tok := p.tok
+	switch tok.kind {
+	case tokNumber:
+		p.next()
+		return number(tok.value), nil
+	case tokField:
+		p.next()
+		return fieldRef(tok.text), nil
+	case tokIdent:
+		p.next()
+		if !p.isOp("(") {
+			return fieldRef(tok.text), nil
+		}
+
+		fn, ok := functions[tok.text]
+		if !ok {
+			p.tok = tok
+			return nil, p.errorf("unknown function %s", tok)
+		}
+		p.next()
+
+		var args []node
+		if !p.isOp(")") {
+			for {
+				arg, err := p.expr()
+				if err != nil {
+					return nil, err
+				}
+				args = append(args, arg)
+				if !p.isOp(",") {
+					break
+				}
+				p.next()
+			}
+		}
+		if !p.isOp(")") {
+			return nil, p.errorf("expected \")\" but found %s", p.tok)
+		}
+		p.next()
+
+		if len(args) < fn.minArgs || (fn.maxArgs >= 0 && len(args) > fn.maxArgs) {
+			p.tok = tok
+			return nil, p.errorf("wrong number of arguments for %s", tok.text)
+		}
+		return call{fn: fn, args: args}, nil
+	case tokOp:
+		if tok.text == "(" {
+			p.next()
+			x, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			if !p.isOp(")") {
+				return nil, p.errorf("expected \")\" but found %s", p.tok)
+			}
+			p.next()
+			return x, nil
+		}
+	}
+	return nil, p.errorf("unexpected %s", tok)
+}
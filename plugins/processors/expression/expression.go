@@ -0,0 +1,169 @@
+package expression
+
+import (
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/processors"
+)
+
+var sampleConfig = `
+  ## Unit conversions, they are applied before the expressions.  The field
+  ## is replaced unless dest is set, values are converted to float.
+  # [[processors.expression.convert]]
+  #   field = "temp_input"
+  #   from = "K"
+  #   to = "degC"
+  #   dest = ""
+
+  ## Fields set to the result of an arithmetic expression over the fields
+  ## of the metric, an existing field is replaced.  Expressions are
+  ## evaluated in order and may use the results of earlier expressions.
+  ##
+  ## Available are the operators + - * / % ^ and the functions abs, ceil,
+  ## exp, floor, log, log10, log2, max, min, pow, round and sqrt.  Field
+  ## names not valid as identifiers are quoted in backticks.
+  ##
+  ## If a field is missing or not numeric, on division by zero, or if the
+  ## result is not a finite number the field is not set.
+  [[processors.expression.field]]
+    name = "bits_recv"
+    expression = "bytes_recv * 8"
+    ## Type of the result, "float" or "integer".  Integers are rounded.
+    # type = "float"
+
+  # [[processors.expression.field]]
+  #   name = "used_percent"
+  #   expression = "used / total * 100"
+`
+
+type Conversion struct {
+	Field string `toml:"field"`
+	From  string `toml:"from"`
+	To    string `toml:"to"`
+	Dest  string `toml:"dest"`
+
+	conversion *conversion
+}
+
+type Field struct {
+	Name       string `toml:"name"`
+	Expression string `toml:"expression"`
+	Type       string `toml:"type"`
+
+	expression *expression
+}
+
+type Expression struct {
+	Conversions []*Conversion `toml:"convert"`
+	Fields      []*Field      `toml:"field"`
+}
+
+func (e *Expression) SampleConfig() string {
+	return sampleConfig
+}
+
+func (e *Expression) Description() string {
+	return "Set fields to arithmetic expressions and convert units"
+}
+
+func (e *Expression) Init() error {
+	for _, c := range e.Conversions {
+		if c.Field == "" {
+			return fmt.Errorf("field must be set in convert")
+		}
+		conv, err := newConversion(c.From, c.To)
+		if err != nil {
+			return err
+		}
+		c.conversion = conv
+	}
+
+	for _, f := range e.Fields {
+		if f.Name == "" {
+			return fmt.Errorf("name must be set in field")
+		}
+		switch f.Type {
+		case "", "float", "integer":
+		default:
+			return fmt.Errorf("invalid type %q for field %q", f.Type, f.Name)
+		}
+
+		expr, err := parse(f.Expression)
+		if err != nil {
+			return fmt.Errorf("invalid expression for field %q: %v", f.Name, err)
+		}
+		f.expression = expr
+	}
+	return nil
+}
+
+func (e *Expression) Apply(in ...telegraf.Metric) []telegraf.Metric {
+	for _, metric := range in {
+		for _, c := range e.Conversions {
+			e.convert(metric, c)
+		}
+
+		if len(e.Fields) == 0 {
+			continue
+		}
+
+		fields := metric.Fields()
+		for _, f := range e.Fields {
+			v, err := f.expression.eval(fields)
+			if err != nil {
+				log.Printf("D! [processors.expression] Cannot set field %q of %q: %v",
+					f.Name, metric.Name(), err)
+				continue
+			}
+
+			var value interface{} = v
+			if f.Type == "integer" {
+				v = math.Round(v)
+				if v < math.MinInt64 || v >= math.MaxInt64 {
+					log.Printf("D! [processors.expression] Cannot set field %q of %q: %v out of range",
+						f.Name, metric.Name(), v)
+					continue
+				}
+				value = int64(v)
+			}
+
+			metric.AddField(f.Name, value)
+			fields[f.Name] = value
+		}
+	}
+	return in
+}
+
+func (e *Expression) convert(metric telegraf.Metric, c *Conversion) {
+	value, ok := metric.GetField(c.Field)
+	if !ok {
+		return
+	}
+
+	var v float64
+	switch value := value.(type) {
+	case float64:
+		v = value
+	case int64:
+		v = float64(value)
+	case uint64:
+		v = float64(value)
+	default:
+		return
+	}
+
+	dest := c.Dest
+	if dest == "" {
+		dest = c.Field
+	}
+	metric.AddField(dest, c.conversion.convert(v))
+}
+
+func init() {
+	processors.Add("expression", func() telegraf.Processor {
+		return &Expression{}
+	})
+}
@@ -0,0 +1,199 @@
+package expression
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEval(t *testing.T) {
+	fields := map[string]interface{}{
+		"a":         int64(6),
+		"b":         uint64(4),
+		"c":         -2.5,
+		"ok":        true,
+		"name":      "x",
+		"zero":      0.0,
+		"disk.used": int64(3),
+		"used-mem":  1.5,
+	}
+
+	tests := []struct {
+		expr     string
+		expected float64
+		err      bool
+	}{
+		{expr: "a + b * 2", expected: 14},
+		{expr: "(a + b) * 2", expected: 20},
+		{expr: "a / b * 100", expected: 150},
+		{expr: "a - b - 1", expected: 1},
+		{expr: "a % b", expected: 2},
+		{expr: "-a + -c", expected: -3.5},
+		{expr: "2 ^ 3 ^ 2", expected: 512},
+		{expr: "-2 ^ 2", expected: -4},
+		{expr: "abs(c)", expected: 2.5},
+		{expr: "min(a, b, c)", expected: -2.5},
+		{expr: "max(a, b)", expected: 6},
+		{expr: "log(exp(2))", expected: 2},
+		{expr: "log10(1000) + log2(8)", expected: 6},
+		{expr: "sqrt(pow(b, 2))", expected: 4},
+		{expr: "round(c) + floor(1.9) + ceil(1.1)", expected: 0},
+		{expr: "ok * 10", expected: 10},
+		{expr: "disk.used + `used-mem`", expected: 4.5},
+		{expr: "1.5e3 + .5", expected: 1500.5},
+		{expr: "a / zero", err: true},
+		{expr: "a % zero", err: true},
+		{expr: "log(zero)", err: true},
+		{expr: "sqrt(c)", err: true},
+		{expr: "missing + 1", err: true},
+		{expr: "name + 1", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			e, err := parse(tt.expr)
+			require.NoError(t, err)
+
+			v, err := e.eval(fields)
+			if tt.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.InDelta(t, tt.expected, v, 1e-9)
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"a +",
+		"(a + b",
+		"a b",
+		"foo(a)",
+		"abs(a, b)",
+		"pow(a)",
+		"min()",
+		"a $ b",
+		"`a",
+		"1..2",
+	} {
+		_, err := parse(expr)
+		require.Error(t, err, expr)
+	}
+}
+
+func TestConversion(t *testing.T) {
+	tests := []struct {
+		from, to string
+		in, out  float64
+	}{
+		{"K", "degC", 300, 26.85},
+		{"degC", "degF", 100, 212},
+		{"degF", "K", 32, 273.15},
+		{"ms", "s", 1500, 1.5},
+		{"h", "min", 2, 120},
+		{"KiB", "B", 2, 2048},
+		{"B", "bit", 3, 24},
+		{"Mbit", "MB", 8, 1},
+		{"GHz", "MHz", 1.2, 1200},
+		{"kWh", "J", 1, 3.6e6},
+		{"ratio", "percent", 0.25, 25},
+	}
+	for _, tt := range tests {
+		c, err := newConversion(tt.from, tt.to)
+		require.NoError(t, err)
+		require.InDelta(t, tt.out, c.convert(tt.in), 1e-9, "%s to %s", tt.from, tt.to)
+	}
+
+	_, err := newConversion("K", "s")
+	require.Error(t, err)
+	_, err = newConversion("parsec", "s")
+	require.Error(t, err)
+}
+
+func TestApply(t *testing.T) {
+	e := &Expression{
+		Conversions: []*Conversion{
+			{Field: "temp_input", From: "K", To: "degC"},
+			{Field: "uptime_ms", From: "ms", To: "s", Dest: "uptime"},
+		},
+		Fields: []*Field{
+			{Name: "bits", Expression: "bytes * 8", Type: "integer"},
+			{Name: "used_percent", Expression: "used / total * 100"},
+			{Name: "free_percent", Expression: "100 - used_percent"},
+			{Name: "missing", Expression: "nope * 2"},
+		},
+	}
+	require.NoError(t, e.Init())
+
+	in := []telegraf.Metric{
+		testutil.MustMetric("sensors",
+			map[string]string{"chip": "k10temp"},
+			map[string]interface{}{
+				"temp_input": 273.15,
+				"uptime_ms":  int64(2500),
+				"bytes":      int64(1024),
+				"used":       int64(1),
+				"total":      int64(4),
+			},
+			time.Unix(0, 0)),
+		testutil.MustMetric("mem",
+			map[string]string{},
+			map[string]interface{}{"used": int64(1), "total": int64(0)},
+			time.Unix(0, 0)),
+	}
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric("sensors",
+			map[string]string{"chip": "k10temp"},
+			map[string]interface{}{
+				"temp_input":   0.0,
+				"uptime_ms":    int64(2500),
+				"uptime":       2.5,
+				"bytes":        int64(1024),
+				"bits":         int64(8192),
+				"used":         int64(1),
+				"total":        int64(4),
+				"used_percent": 25.0,
+				"free_percent": 75.0,
+			},
+			time.Unix(0, 0)),
+		testutil.MustMetric("mem",
+			map[string]string{},
+			map[string]interface{}{"used": int64(1), "total": int64(0)},
+			time.Unix(0, 0)),
+	}
+
+	out := e.Apply(in...)
+	testutil.RequireMetricsEqual(t, expected, out)
+}
+
+func TestIntegerOutOfRange(t *testing.T) {
+	e := &Expression{
+		Fields: []*Field{{Name: "big", Expression: "a * 1e30", Type: "integer"}},
+	}
+	require.NoError(t, e.Init())
+
+	m := testutil.MustMetric("m", map[string]string{},
+		map[string]interface{}{"a": math.MaxFloat32}, time.Unix(0, 0))
+	e.Apply(m)
+	require.False(t, m.HasField("big"))
+}
+
+func TestInvalidConfig(t *testing.T) {
+	for _, e := range []*Expression{
+		{Fields: []*Field{{Name: "a", Expression: "b +"}}},
+		{Fields: []*Field{{Expression: "b"}}},
+		{Fields: []*Field{{Name: "a", Expression: "b", Type: "string"}}},
+		{Conversions: []*Conversion{{Field: "a", From: "K", To: "B"}}},
+		{Conversions: []*Conversion{{From: "K", To: "degC"}}},
+	} {
+		require.Error(t, e.Init())
+	}
+}
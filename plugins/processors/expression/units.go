@@ -0,0 +1,97 @@
+package expression
+
+import (
+	"fmt"
+)
+
+// unit converts a value to the base unit of its dimension by multiplying
+// with factor and adding offset.
+type unit struct {
+	dimension string
+	factor    float64
+	offset    float64
+}
+
+var units = map[string]unit{
+	// temperature, base kelvin
+	"K":    {"temperature", 1, 0},
+	"degC": {"temperature", 1, 273.15},
+	"degF": {"temperature", 5.0 / 9.0, 273.15 - 32*5.0/9.0},
+
+	// time, base second
+	"ns":  {"time", 1e-9, 0},
+	"us":  {"time", 1e-6, 0},
+	"ms":  {"time", 1e-3, 0},
+	"s":   {"time", 1, 0},
+	"min": {"time", 60, 0},
+	"h":   {"time", 3600, 0},
+	"d":   {"time", 86400, 0},
+
+	// data, base byte
+	"bit":  {"data", 1.0 / 8, 0},
+	"kbit": {"data", 1e3 / 8, 0},
+	"Mbit": {"data", 1e6 / 8, 0},
+	"Gbit": {"data", 1e9 / 8, 0},
+	"B":    {"data", 1, 0},
+	"kB":   {"data", 1e3, 0},
+	"MB":   {"data", 1e6, 0},
+	"GB":   {"data", 1e9, 0},
+	"TB":   {"data", 1e12, 0},
+	"PB":   {"data", 1e15, 0},
+	"KiB":  {"data", 1 << 10, 0},
+	"MiB":  {"data", 1 << 20, 0},
+	"GiB":  {"data", 1 << 30, 0},
+	"TiB":  {"data", 1 << 40, 0},
+	"PiB":  {"data", 1 << 50, 0},
+
+	// frequency, base hertz
+	"Hz":  {"frequency", 1, 0},
+	"kHz": {"frequency", 1e3, 0},
+	"MHz": {"frequency", 1e6, 0},
+	"GHz": {"frequency", 1e9, 0},
+
+	// power, base watt
+	"mW": {"power", 1e-3, 0},
+	"W":  {"power", 1, 0},
+	"kW": {"power", 1e3, 0},
+
+	// energy, base joule
+	"J":   {"energy", 1, 0},
+	"kJ":  {"energy", 1e3, 0},
+	"Wh":  {"energy", 3600, 0},
+	"kWh": {"energy", 3.6e6, 0},
+
+	// ratio, base fraction
+	"ratio":   {"ratio", 1, 0},
+	"percent": {"ratio", 0.01, 0},
+}
+
+// conversion converts values from one unit to another.
+type conversion struct {
+	factor float64
+	offset float64
+}
+
+func newConversion(from, to string) (*conversion, error) {
+	f, ok := units[from]
+	if !ok {
+		return nil, fmt.Errorf("unknown unit %q", from)
+	}
+	t, ok := units[to]
+	if !ok {
+		return nil, fmt.Errorf("unknown unit %q", to)
+	}
+	if f.dimension != t.dimension {
+		return nil, fmt.Errorf("cannot convert %s (%s) to %s (%s)", from, f.dimension, to, t.dimension)
+	}
+
+	// to base: v*f.factor + f.offset, from base: (b - t.offset) / t.factor
+	return &conversion{
+		factor: f.factor / t.factor,
+		offset: (f.offset - t.offset) / t.factor,
+	}, nil
+}
+
+func (c *conversion) convert(v float64) float64 {
+	return v*c.factor + c.offset
+}
@@ -0,0 +1,56 @@
+package geoip
+
+import "container/list"
+
+// cache is a least recently used cache of lookup results by IP address.
+type cache struct {
+	size    int
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+type cacheEntry struct {
+	ip     string
+	values map[string]interface{}
+}
+
+func newCache(size int) *cache {
+	return &cache{
+		size:    size,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+func (c *cache) get(ip string) (map[string]interface{}, bool) {
+	elem, ok := c.entries[ip]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*cacheEntry).values, true
+}
+
+func (c *cache) add(ip string, values map[string]interface{}) {
+	if c.size <= 0 {
+		return
+	}
+
+	if elem, ok := c.entries[ip]; ok {
+		elem.Value.(*cacheEntry).values = values
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[ip] = c.order.PushFront(&cacheEntry{ip: ip, values: values})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).ip)
+	}
+}
+
+func (c *cache) clear() {
+	c.entries = make(map[string]*list.Element)
+	c.order.Init()
+}
@@ -0,0 +1,322 @@
+package geoip
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/processors"
+)
+
+var sampleConfig = `
+  ## MaxMind DB files to query, such as the GeoLite2 or GeoIP2 City,
+  ## Country and ASN databases.  The values of the first database containing
+  ## the address are used.
+  databases = ["/var/lib/GeoIP/GeoLite2-City.mmdb", "/var/lib/GeoIP/GeoLite2-ASN.mmdb"]
+
+  ## Tags and fields containing the IP addresses to look up.
+  ip_tags = ["client_ip"]
+  # ip_fields = []
+
+  ## Values to add, the key of the added tag or field is the key of the IP
+  ## address followed by an underscore and the name of the value, e.g.
+  ## "client_ip_country_code".
+  ## Available are: "continent_code", "country_code", "country_name",
+  ## "region", "city", "postal_code", "latitude", "longitude", "asn" and
+  ## "as_org".
+  # lookups = ["country_code", "city", "asn", "latitude", "longitude"]
+
+  ## Add the values as "tags" or "fields".
+  # add_as = "tags"
+
+  ## Language of the country and city names.
+  # language = "en"
+
+  ## Interval to check the databases for changes and reload them.
+  # reload_interval = "1m"
+
+  ## Maximum number of lookup results to cache.
+  # cache_size = 1000
+`
+
+type GeoIP struct {
+	Databases      []string          `toml:"databases"`
+	IPTags         []string          `toml:"ip_tags"`
+	IPFields       []string          `toml:"ip_fields"`
+	Lookups        []string          `toml:"lookups"`
+	AddAs          string            `toml:"add_as"`
+	Language       string            `toml:"language"`
+	ReloadInterval internal.Duration `toml:"reload_interval"`
+	CacheSize      int               `toml:"cache_size"`
+
+	readers   []*reader
+	fileTimes map[string]time.Time
+	lastCheck time.Time
+	cache     *cache
+	now       func() time.Time
+}
+
+func (g *GeoIP) SampleConfig() string {
+	return sampleConfig
+}
+
+func (g *GeoIP) Description() string {
+	return "Add location and network information of IP addresses from local MaxMind DB files"
+}
+
+// lookups are the values that can be added.
+var lookups = map[string]bool{
+	"continent_code": true,
+	"country_code":   true,
+	"country_name":   true,
+	"region":         true,
+	"city":           true,
+	"postal_code":    true,
+	"latitude":       true,
+	"longitude":      true,
+	"asn":            true,
+	"as_org":         true,
+}
+
+func (g *GeoIP) Init() error {
+	if g.AddAs != "tags" && g.AddAs != "fields" {
+		return fmt.Errorf("invalid add_as: %s", g.AddAs)
+	}
+	for _, name := range g.Lookups {
+		if !lookups[name] {
+			return fmt.Errorf("invalid lookup: %s", name)
+		}
+	}
+	if len(g.Databases) == 0 {
+		return fmt.Errorf("databases must not be empty")
+	}
+
+	modTimes, err := g.modTimes()
+	if err != nil {
+		return err
+	}
+	readers, err := g.load()
+	if err != nil {
+		return err
+	}
+
+	g.readers = readers
+	g.fileTimes = modTimes
+	g.lastCheck = g.now()
+	g.cache = newCache(g.CacheSize)
+	return nil
+}
+
+func (g *GeoIP) Apply(in ...telegraf.Metric) []telegraf.Metric {
+	g.reloadIfChanged()
+
+	for _, m := range in {
+		for _, key := range g.IPTags {
+			if value, ok := m.GetTag(key); ok {
+				g.add(m, key, value)
+			}
+		}
+		for _, key := range g.IPFields {
+			if value, ok := m.GetField(key); ok {
+				if s, ok := value.(string); ok {
+					g.add(m, key, s)
+				}
+			}
+		}
+	}
+	return in
+}
+
+func (g *GeoIP) add(m telegraf.Metric, key, address string) {
+	values, ok := g.cache.get(address)
+	if !ok {
+		values = g.lookup(address)
+		g.cache.add(address, values)
+	}
+
+	for name, value := range values {
+		if g.AddAs == "fields" {
+			m.AddField(key+"_"+name, value)
+		} else {
+			m.AddTag(key+"_"+name, toString(value))
+		}
+	}
+}
+
+// lookup returns the configured values of the address, for every value the
+// first database containing it is used.
+func (g *GeoIP) lookup(address string) map[string]interface{} {
+	values := make(map[string]interface{})
+
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return values
+	}
+
+	for _, r := range g.readers {
+		record, err := r.lookup(ip)
+		if err != nil {
+			log.Printf("E! [processors.geoip] Looking up %s: %v", address, err)
+			continue
+		}
+		if record == nil {
+			continue
+		}
+
+		for _, name := range g.Lookups {
+			if _, ok := values[name]; ok {
+				continue
+			}
+			if value, ok := extract(record, name, g.Language); ok {
+				values[name] = value
+			}
+		}
+	}
+	return values
+}
+
+// extract returns a value from a City, Country or ASN database record.
+func extract(record map[string]interface{}, name, language string) (interface{}, bool) {
+	var value interface{}
+	switch name {
+	case "continent_code":
+		value = path(record, "continent", "code")
+	case "country_code":
+		value = path(record, "country", "iso_code")
+	case "country_name":
+		value = path(record, "country", "names", language)
+	case "region":
+		if subdivisions, ok := record["subdivisions"].([]interface{}); ok && len(subdivisions) > 0 {
+			if subdivision, ok := subdivisions[0].(map[string]interface{}); ok {
+				value = path(subdivision, "iso_code")
+			}
+		}
+	case "city":
+		value = path(record, "city", "names", language)
+	case "postal_code":
+		value = path(record, "postal", "code")
+	case "latitude":
+		value = path(record, "location", "latitude")
+	case "longitude":
+		value = path(record, "location", "longitude")
+	case "asn":
+		value = path(record, "autonomous_system_number")
+	case "as_org":
+		value = path(record, "autonomous_system_organization")
+	}
+
+	switch value.(type) {
+	case string, float64, uint64, int64:
+		return value, true
+	}
+	return nil, false
+}
+
+func path(record map[string]interface{}, keys ...string) interface{} {
+	var value interface{} = record
+	for _, key := range keys {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = m[key]
+	}
+	return value
+}
+
+// reloadIfChanged opens the databases when one of them was modified, at most
+// once per reload interval.  On errors the previous databases are kept.
+func (g *GeoIP) reloadIfChanged() {
+	now := g.now()
+	if now.Sub(g.lastCheck) < g.ReloadInterval.Duration {
+		return
+	}
+	g.lastCheck = now
+
+	modTimes, err := g.modTimes()
+	if err != nil {
+		log.Printf("E! [processors.geoip] %v", err)
+		return
+	}
+	changed := false
+	for filename, modTime := range modTimes {
+		if !modTime.Equal(g.fileTimes[filename]) {
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+
+	readers, err := g.load()
+	if err != nil {
+		log.Printf("E! [processors.geoip] %v", err)
+		return
+	}
+
+	g.readers = readers
+	g.fileTimes = modTimes
+	g.cache.clear()
+}
+
+// modTimes returns the modification time of each database.
+func (g *GeoIP) modTimes() (map[string]time.Time, error) {
+	modTimes := make(map[string]time.Time, len(g.Databases))
+	for _, filename := range g.Databases {
+		stat, err := os.Stat(filename)
+		if err != nil {
+			return nil, err
+		}
+		modTimes[filename] = stat.ModTime()
+	}
+	return modTimes, nil
+}
+
+// load opens all databases.
+func (g *GeoIP) load() ([]*reader, error) {
+	readers := make([]*reader, 0, len(g.Databases))
+	for _, filename := range g.Databases {
+		r, err := openReader(filename)
+		if err != nil {
+			return nil, fmt.Errorf("opening %s: %v", filename, err)
+		}
+		log.Printf("D! [processors.geoip] Loaded %s database %s", r.dbType, filename)
+		readers = append(readers, r)
+	}
+	return readers, nil
+}
+
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func newGeoIP() *GeoIP {
+	return &GeoIP{
+		Lookups:        []string{"country_code", "city", "asn", "latitude", "longitude"},
+		AddAs:          "tags",
+		Language:       "en",
+		ReloadInterval: internal.Duration{Duration: time.Minute},
+		CacheSize:      1000,
+		now:            time.Now,
+	}
+}
+
+func init() {
+	processors.Add("geoip", func() telegraf.Processor {
+		return newGeoIP()
+	})
+}
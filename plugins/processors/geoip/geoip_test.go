@@ -0,0 +1,448 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// dbWriter creates MaxMind DB files for testing.
+type dbWriter struct {
+	ipVersion  int
+	recordSize int
+	dbType     string
+
+	// nodes of the search tree, a record is either a node index, empty (-1)
+	// or the index of a record in data (-2 - index)
+	nodes   [][2]int
+	data    bytes.Buffer
+	offsets []int
+}
+
+func newDBWriter(ipVersion, recordSize int, dbType string) *dbWriter {
+	return &dbWriter{
+		ipVersion:  ipVersion,
+		recordSize: recordSize,
+		dbType:     dbType,
+		nodes:      [][2]int{{-1, -1}},
+	}
+}
+
+func (w *dbWriter) insert(t *testing.T, cidr string, record map[string]interface{}) {
+	_, network, err := net.ParseCIDR(cidr)
+	require.NoError(t, err)
+
+	ip := network.IP
+	ones, _ := network.Mask.Size()
+	if w.ipVersion == 6 {
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = append(make(net.IP, 12), ip4...)
+			ones += 96
+		} else {
+			ip = ip.To16()
+		}
+	}
+
+	w.offsets = append(w.offsets, w.data.Len())
+	encodeValue(&w.data, record)
+	leaf := -2 - (len(w.offsets) - 1)
+
+	node := 0
+	for i := 0; i < ones; i++ {
+		bit := int(ip[i>>3]>>(7-uint(i&7))) & 1
+		if i == ones-1 {
+			w.nodes[node][bit] = leaf
+			break
+		}
+		next := w.nodes[node][bit]
+		if next < 0 {
+			// split the enclosing network, if any
+			w.nodes = append(w.nodes, [2]int{next, next})
+			next = len(w.nodes) - 1
+			w.nodes[node][bit] = next
+		}
+		node = next
+	}
+}
+
+func (w *dbWriter) bytes() []byte {
+	nodeCount := len(w.nodes)
+	var buf bytes.Buffer
+	for _, node := range w.nodes {
+		var records [2]uint32
+		for i, r := range node {
+			switch {
+			case r == -1:
+				records[i] = uint32(nodeCount)
+			case r < -1:
+				records[i] = uint32(nodeCount + 16 + w.offsets[-2-r])
+			default:
+				records[i] = uint32(r)
+			}
+		}
+
+		switch w.recordSize {
+		case 24:
+			buf.Write([]byte{byte(records[0] >> 16), byte(records[0] >> 8), byte(records[0])})
+			buf.Write([]byte{byte(records[1] >> 16), byte(records[1] >> 8), byte(records[1])})
+		case 28:
+			buf.Write([]byte{byte(records[0] >> 16), byte(records[0] >> 8), byte(records[0])})
+			buf.WriteByte(byte(records[0]>>24)<<4 | byte(records[1]>>24))
+			buf.Write([]byte{byte(records[1] >> 16), byte(records[1] >> 8), byte(records[1])})
+		case 32:
+			binary.Write(&buf, binary.BigEndian, records)
+		}
+	}
+	buf.Write(make([]byte, 16))
+	buf.Write(w.data.Bytes())
+	buf.Write(metadataStart)
+	encodeValue(&buf, map[string]interface{}{
+		"node_count":                  uint32(nodeCount),
+		"record_size":                 uint16(w.recordSize),
+		"ip_version":                  uint16(w.ipVersion),
+		"database_type":               w.dbType,
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(1560540094),
+		"languages":                   []interface{}{"en"},
+		"description":                 map[string]interface{}{"en": "test"},
+	})
+	return buf.Bytes()
+}
+
+func writeControl(buf *bytes.Buffer, typ int, size int) {
+	var ctrl byte
+	var extra []byte
+	switch {
+	case size < 29:
+		ctrl = byte(size)
+	case size < 285:
+		ctrl = 29
+		extra = []byte{byte(size - 29)}
+	default:
+		ctrl = 30
+		extra = []byte{byte((size - 285) >> 8), byte(size - 285)}
+	}
+
+	if typ > 7 {
+		buf.WriteByte(ctrl)
+		buf.WriteByte(byte(typ - 7))
+	} else {
+		buf.WriteByte(byte(typ)<<5 | ctrl)
+	}
+	buf.Write(extra)
+}
+
+func encodeValue(buf *bytes.Buffer, value interface{}) {
+	switch v := value.(type) {
+	case string:
+		writeControl(buf, typeString, len(v))
+		buf.WriteString(v)
+	case float64:
+		writeControl(buf, typeDouble, 8)
+		binary.Write(buf, binary.BigEndian, math.Float64bits(v))
+	case uint16:
+		writeControl(buf, typeUint16, 2)
+		binary.Write(buf, binary.BigEndian, v)
+	case uint32:
+		writeControl(buf, typeUint32, 4)
+		binary.Write(buf, binary.BigEndian, v)
+	case uint64:
+		writeControl(buf, typeUint64, 8)
+		binary.Write(buf, binary.BigEndian, v)
+	case bool:
+		size := 0
+		if v {
+			size = 1
+		}
+		writeControl(buf, typeBool, size)
+	case []interface{}:
+		writeControl(buf, typeArray, len(v))
+		for _, e := range v {
+			encodeValue(buf, e)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		writeControl(buf, typeMap, len(v))
+		for _, k := range keys {
+			encodeValue(buf, k)
+			encodeValue(buf, v[k])
+		}
+	default:
+		panic("unsupported type")
+	}
+}
+
+func cityRecord(country, city string, lat, lon float64) map[string]interface{} {
+	return map[string]interface{}{
+		"continent": map[string]interface{}{"code": "EU"},
+		"country": map[string]interface{}{
+			"iso_code": country,
+			"names":    map[string]interface{}{"en": country + " name", "de": country + " Name"},
+		},
+		"city": map[string]interface{}{
+			"names": map[string]interface{}{"en": city},
+		},
+		"subdivisions": []interface{}{
+			map[string]interface{}{"iso_code": "BE"},
+		},
+		"location": map[string]interface{}{
+			"latitude":  lat,
+			"longitude": lon,
+		},
+		"is_in_european_union": true,
+	}
+}
+
+func writeDatabases(t *testing.T, dir string) (string, string) {
+	city := newDBWriter(6, 28, "GeoLite2-City")
+	city.insert(t, "81.2.69.0/24", cityRecord("GB", "London", 51.5142, -0.0931))
+	city.insert(t, "2001:db8::/32", cityRecord("DE", "Berlin", 52.52, 13.405))
+	cityFile := filepath.Join(dir, "city.mmdb")
+	require.NoError(t, ioutil.WriteFile(cityFile, city.bytes(), 0644))
+
+	asn := newDBWriter(4, 24, "GeoLite2-ASN")
+	asn.insert(t, "81.2.64.0/20", map[string]interface{}{
+		"autonomous_system_number":       uint32(20712),
+		"autonomous_system_organization": "Andrews & Arnold Ltd",
+	})
+	asnFile := filepath.Join(dir, "asn.mmdb")
+	require.NoError(t, ioutil.WriteFile(asnFile, asn.bytes(), 0644))
+
+	return cityFile, asnFile
+}
+
+func TestReaderRecordSizes(t *testing.T) {
+	for _, recordSize := range []int{24, 28, 32} {
+		for _, ipVersion := range []int{4, 6} {
+			w := newDBWriter(ipVersion, recordSize, "test")
+			w.insert(t, "10.0.0.0/8", map[string]interface{}{"name": "ten"})
+			w.insert(t, "10.1.0.0/16", map[string]interface{}{"name": "ten-one"})
+			w.insert(t, "192.168.1.0/24", map[string]interface{}{"name": "private"})
+
+			r, err := newReader(w.bytes())
+			require.NoError(t, err)
+
+			for ip, expected := range map[string]interface{}{
+				"10.2.3.4":    "ten",
+				"10.1.3.4":    "ten-one",
+				"192.168.1.7": "private",
+				"192.168.2.7": nil,
+				"8.8.8.8":     nil,
+			} {
+				record, err := r.lookup(net.ParseIP(ip))
+				require.NoError(t, err)
+				if expected == nil {
+					require.Nil(t, record, "%s in %d/%d", ip, recordSize, ipVersion)
+					continue
+				}
+				require.Equal(t, expected, record["name"], "%s in %d/%d", ip, recordSize, ipVersion)
+			}
+		}
+	}
+}
+
+func TestInvalidDatabase(t *testing.T) {
+	_, err := newReader([]byte("not a database"))
+	require.Error(t, err)
+
+	w := newDBWriter(4, 24, "test")
+	w.insert(t, "10.0.0.0/8", map[string]interface{}{"name": "ten"})
+	buf := w.bytes()
+	_, err = newReader(buf[len(buf)-40:])
+	require.Error(t, err)
+}
+
+func TestCyclicData(t *testing.T) {
+	// a map with a value pointing back to the map
+	d := decoder{buf: []byte{typeMap<<5 | 1, typeString<<5 | 1, 'a', typePointer << 5, 0}}
+	_, _, err := d.decode(0, 0)
+	require.Error(t, err)
+}
+
+func TestInitErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geoip")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cityFile, _ := writeDatabases(t, dir)
+	brokenFile := filepath.Join(dir, "broken.mmdb")
+	require.NoError(t, ioutil.WriteFile(brokenFile, []byte("not a database"), 0644))
+
+	tests := []struct {
+		name   string
+		modify func(g *GeoIP)
+	}{
+		{"add_as", func(g *GeoIP) { g.AddAs = "field" }},
+		{"lookups", func(g *GeoIP) { g.Lookups = []string{"country"} }},
+		{"no databases", func(g *GeoIP) { g.Databases = nil }},
+		{"missing database", func(g *GeoIP) { g.Databases = []string{filepath.Join(dir, "missing.mmdb")} }},
+		{"broken database", func(g *GeoIP) { g.Databases = []string{cityFile, brokenFile} }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newGeoIP()
+			g.Databases = []string{cityFile}
+			g.IPTags = []string{"ip"}
+			tt.modify(g)
+			require.Error(t, g.Init())
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geoip")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cityFile, asnFile := writeDatabases(t, dir)
+
+	g := newGeoIP()
+	g.Databases = []string{cityFile, asnFile}
+	g.IPTags = []string{"client_ip"}
+	g.IPFields = []string{"remote"}
+	g.Lookups = []string{"country_code", "country_name", "region", "city", "asn", "as_org", "latitude"}
+	require.NoError(t, g.Init())
+
+	m1 := testutil.MustMetric(
+		"nginx",
+		map[string]string{"client_ip": "81.2.69.160"},
+		map[string]interface{}{"remote": "2001:db8::1", "bytes": int64(10)},
+		time.Unix(0, 0),
+	)
+	m2 := testutil.MustMetric(
+		"nginx",
+		map[string]string{"client_ip": "not an ip"},
+		map[string]interface{}{"bytes": int64(10)},
+		time.Unix(0, 0),
+	)
+	out := g.Apply(m1, m2)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric(
+			"nginx",
+			map[string]string{
+				"client_ip":              "81.2.69.160",
+				"client_ip_country_code": "GB",
+				"client_ip_country_name": "GB name",
+				"client_ip_region":       "BE",
+				"client_ip_city":         "London",
+				"client_ip_asn":          "20712",
+				"client_ip_as_org":       "Andrews & Arnold Ltd",
+				"client_ip_latitude":     "51.5142",
+				"remote_country_code":    "DE",
+				"remote_country_name":    "DE name",
+				"remote_region":          "BE",
+				"remote_city":            "Berlin",
+				"remote_latitude":        "52.52",
+			},
+			map[string]interface{}{"remote": "2001:db8::1", "bytes": int64(10)},
+			time.Unix(0, 0),
+		),
+		m2,
+	}
+	testutil.RequireMetricsEqual(t, expected, out)
+}
+
+func TestFieldsAndLanguage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geoip")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cityFile, asnFile := writeDatabases(t, dir)
+
+	g := newGeoIP()
+	g.Databases = []string{cityFile, asnFile}
+	g.IPTags = []string{"ip"}
+	g.Lookups = []string{"country_name", "asn", "longitude"}
+	g.AddAs = "fields"
+	g.Language = "de"
+	require.NoError(t, g.Init())
+
+	out := g.Apply(testutil.MustMetric("m", map[string]string{"ip": "81.2.69.1"},
+		map[string]interface{}{"v": 1.0}, time.Unix(0, 0)))
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric(
+			"m",
+			map[string]string{"ip": "81.2.69.1"},
+			map[string]interface{}{
+				"v":               1.0,
+				"ip_country_name": "GB Name",
+				"ip_asn":          uint64(20712),
+				"ip_longitude":    -0.0931,
+			},
+			time.Unix(0, 0),
+		),
+	}
+	testutil.RequireMetricsEqual(t, expected, out)
+}
+
+func TestReloadClearsCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geoip")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	filename := filepath.Join(dir, "country.mmdb")
+	w := newDBWriter(4, 24, "GeoLite2-Country")
+	w.insert(t, "10.0.0.0/8", map[string]interface{}{"country": map[string]interface{}{"iso_code": "AA"}})
+	require.NoError(t, ioutil.WriteFile(filename, w.bytes(), 0644))
+
+	g := newGeoIP()
+	g.now = func() time.Time { return now }
+	g.Databases = []string{filename}
+	g.IPTags = []string{"ip"}
+	g.Lookups = []string{"country_code"}
+	require.NoError(t, g.Init())
+
+	apply := func() string {
+		m := testutil.MustMetric("m", map[string]string{"ip": "10.0.0.1"},
+			map[string]interface{}{"v": 1.0}, time.Unix(0, 0))
+		g.Apply(m)
+		value, _ := m.GetTag("ip_country_code")
+		return value
+	}
+	require.Equal(t, "AA", apply())
+
+	w = newDBWriter(4, 24, "GeoLite2-Country")
+	w.insert(t, "10.0.0.0/8", map[string]interface{}{"country": map[string]interface{}{"iso_code": "BB"}})
+	require.NoError(t, ioutil.WriteFile(filename, w.bytes(), 0644))
+	require.NoError(t, os.Chtimes(filename, now.Add(time.Hour), now.Add(time.Hour)))
+
+	// cached until the database is reloaded
+	require.Equal(t, "AA", apply())
+	now = now.Add(time.Minute)
+	require.Equal(t, "BB", apply())
+}
+
+func TestCache(t *testing.T) {
+	c := newCache(2)
+	c.add("a", map[string]interface{}{"v": 1})
+	c.add("b", map[string]interface{}{"v": 2})
+	_, ok := c.get("a")
+	require.True(t, ok)
+
+	c.add("c", map[string]interface{}{"v": 3})
+	_, ok = c.get("b")
+	require.False(t, ok)
+	_, ok = c.get("a")
+	require.True(t, ok)
+	_, ok = c.get("c")
+	require.True(t, ok)
+}
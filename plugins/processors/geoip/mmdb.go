@@ -0,0 +1,341 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"net"
+)
+
+// metadataStart marks the beginning of the metadata section of a MaxMind DB
+// file, it is searched from the end of the file.
+var metadataStart = []byte("\xab\xcd\xefMaxMind.com")
+
+var errInvalidDatabase = errors.New("invalid MaxMind DB file")
+
+// reader looks up networks in a MaxMind DB file, see
+// https://maxmind.github.io/MaxMind-DB/ for the format.
+type reader struct {
+	buf        []byte
+	data       []byte
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	ipv4Start  uint
+	dbType     string
+}
+
+func openReader(filename string) (*reader, error) {
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return newReader(buf)
+}
+
+func newReader(buf []byte) (*reader, error) {
+	i := bytes.LastIndex(buf, metadataStart)
+	if i < 0 {
+		return nil, errInvalidDatabase
+	}
+	metaStart := i + len(metadataStart)
+
+	d := decoder{buf: buf[metaStart:]}
+	value, _, err := d.decode(0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("reading metadata: %v", err)
+	}
+	metadata, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, errInvalidDatabase
+	}
+
+	r := &reader{buf: buf}
+	r.nodeCount = uint(toUint(metadata["node_count"]))
+	r.recordSize = uint(toUint(metadata["record_size"]))
+	r.ipVersion = uint(toUint(metadata["ip_version"]))
+	r.dbType, _ = metadata["database_type"].(string)
+
+	switch r.recordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("unsupported record size: %d", r.recordSize)
+	}
+
+	treeSize := r.recordSize * 2 / 8 * r.nodeCount
+	if treeSize+16 > uint(i) {
+		return nil, errInvalidDatabase
+	}
+	r.data = buf[treeSize+16 : i]
+
+	// IPv4 addresses are looked up in the ::/96 subtree of IPv6 databases
+	if r.ipVersion == 6 {
+		node := uint(0)
+		for j := 0; j < 96 && node < r.nodeCount; j++ {
+			node = r.readNode(node, 0)
+		}
+		r.ipv4Start = node
+	}
+	return r, nil
+}
+
+func toUint(v interface{}) uint64 {
+	switch v := v.(type) {
+	case uint64:
+		return v
+	case int64:
+		return uint64(v)
+	}
+	return 0
+}
+
+func (r *reader) readNode(node uint, bit uint) uint {
+	size := r.recordSize / 4
+	b := r.buf[node*size : node*size+size]
+	switch r.recordSize {
+	case 24:
+		b = b[bit*3 : bit*3+3]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		if bit == 0 {
+			return uint(b[3]&0xf0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0f)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		return uint(binary.BigEndian.Uint32(b[bit*4 : bit*4+4]))
+	}
+}
+
+// lookup returns the record of the network containing ip, or nil when there
+// is none.
+func (r *reader) lookup(ip net.IP) (map[string]interface{}, error) {
+	node := uint(0)
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+		if r.ipVersion == 6 {
+			node = r.ipv4Start
+		}
+	} else if r.ipVersion == 4 {
+		return nil, nil
+	}
+
+	bits := uint(len(ip) * 8)
+	for i := uint(0); i < bits && node < r.nodeCount; i++ {
+		bit := uint(ip[i>>3]>>(7-(i&7))) & 1
+		node = r.readNode(node, bit)
+	}
+
+	if node == r.nodeCount {
+		return nil, nil
+	}
+	if node < r.nodeCount {
+		return nil, errInvalidDatabase
+	}
+
+	offset := node - r.nodeCount - 16
+	if offset >= uint(len(r.data)) {
+		return nil, errInvalidDatabase
+	}
+
+	d := decoder{buf: r.data}
+	value, _, err := d.decode(offset, 0)
+	if err != nil {
+		return nil, err
+	}
+	record, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, errInvalidDatabase
+	}
+	return record, nil
+}
+
+// Data section types.
+const (
+	typeExtended  = 0
+	typePointer   = 1
+	typeString    = 2
+	typeDouble    = 3
+	typeBytes     = 4
+	typeUint16    = 5
+	typeUint32    = 6
+	typeMap       = 7
+	typeInt32     = 8
+	typeUint64    = 9
+	typeUint128   = 10
+	typeArray     = 11
+	typeContainer = 12
+	typeEnd       = 13
+	typeBool      = 14
+	typeFloat     = 15
+)
+
+type decoder struct {
+	buf []byte
+}
+
+func (d *decoder) bytes(offset, n uint) ([]byte, error) {
+	if offset+n > uint(len(d.buf)) || offset+n < offset {
+		return nil, errInvalidDatabase
+	}
+	return d.buf[offset : offset+n], nil
+}
+
+// maxDepth is the maximum nesting of maps, arrays and pointers, it stops
+// corrupt data from recursing without end.
+const maxDepth = 512
+
+// decode returns the value at offset and the offset following it, depth is
+// the nesting of the value.
+func (d *decoder) decode(offset uint, depth int) (interface{}, uint, error) {
+	if depth > maxDepth {
+		return nil, 0, errInvalidDatabase
+	}
+
+	b, err := d.bytes(offset, 1)
+	if err != nil {
+		return nil, 0, err
+	}
+	ctrl := b[0]
+	offset++
+
+	typ := uint(ctrl >> 5)
+	if typ == typePointer {
+		return d.decodePointer(ctrl, offset, depth)
+	}
+	if typ == typeExtended {
+		b, err := d.bytes(offset, 1)
+		if err != nil {
+			return nil, 0, err
+		}
+		typ = 7 + uint(b[0])
+		offset++
+	}
+
+	size := uint(ctrl & 0x1f)
+	if size >= 29 {
+		n := size - 28
+		b, err := d.bytes(offset, n)
+		if err != nil {
+			return nil, 0, err
+		}
+		offset += n
+		switch n {
+		case 1:
+			size = 29 + uint(b[0])
+		case 2:
+			size = 285 + (uint(b[0])<<8 | uint(b[1]))
+		default:
+			size = 65821 + (uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2]))
+		}
+	}
+
+	switch typ {
+	case typeMap:
+		m := make(map[string]interface{}, size)
+		for i := uint(0); i < size; i++ {
+			var key, value interface{}
+			key, offset, err = d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			value, offset, err = d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			k, ok := key.(string)
+			if !ok {
+				return nil, 0, errInvalidDatabase
+			}
+			m[k] = value
+		}
+		return m, offset, nil
+	case typeArray:
+		a := make([]interface{}, 0, size)
+		for i := uint(0); i < size; i++ {
+			var value interface{}
+			value, offset, err = d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, value)
+		}
+		return a, offset, nil
+	case typeBool:
+		return size != 0, offset, nil
+	}
+
+	b, err = d.bytes(offset, size)
+	if err != nil {
+		return nil, 0, err
+	}
+	offset += size
+
+	switch typ {
+	case typeString:
+		return string(b), offset, nil
+	case typeBytes:
+		return append([]byte(nil), b...), offset, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, errInvalidDatabase
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), offset, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, errInvalidDatabase
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), offset, nil
+	case typeUint16, typeUint32, typeUint64:
+		var v uint64
+		for _, c := range b {
+			v = v<<8 | uint64(c)
+		}
+		return v, offset, nil
+	case typeInt32:
+		var v uint32
+		for _, c := range b {
+			v = v<<8 | uint32(c)
+		}
+		return int64(int32(v)), offset, nil
+	case typeUint128:
+		return new(big.Int).SetBytes(b), offset, nil
+	}
+	return nil, 0, fmt.Errorf("unsupported data type %d", typ)
+}
+
+func (d *decoder) decodePointer(ctrl byte, offset uint, depth int) (interface{}, uint, error) {
+	n := uint(ctrl>>3)&0x3 + 1
+	b, err := d.bytes(offset, n)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	v := uint(ctrl & 0x7)
+	var pointer uint
+	switch n {
+	case 1:
+		pointer = v<<8 | uint(b[0])
+	case 2:
+		pointer = (v<<16 | uint(b[0])<<8 | uint(b[1])) + 2048
+	case 3:
+		pointer = (v<<24 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])) + 526336
+	default:
+		pointer = uint(binary.BigEndian.Uint32(b))
+	}
+
+	// a pointer must not point to another pointer
+	target, err := d.bytes(pointer, 1)
+	if err != nil {
+		return nil, 0, err
+	}
+	if uint(target[0]>>5) == typePointer {
+		return nil, 0, errInvalidDatabase
+	}
+
+	value, _, err := d.decode(pointer, depth+1)
+	return value, offset + n, err
+}
@@ -0,0 +1,218 @@
+package lookup
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/processors"
+)
+
+var sampleConfig = `
+  ## Mapping tables to load, later files take precedence over earlier ones
+  ## for the same key.  CSV files have a header row, the first columns are
+  ## the key and the remaining columns the values to add.  JSON files are an
+  ## object of keys to objects of values.
+  files = ["/etc/telegraf/hosts.csv"]
+
+  ## Format of the files, "csv" or "json".  By default the format is detected
+  ## from the file extension.
+  # format = ""
+
+  ## Tags forming the key, several tags form a composite key by joining
+  ## their values with the separator.  In CSV files each tag is a column.
+  key_tags = ["host"]
+  # key_separator = ":"
+
+  ## Add the values as "tags" or "fields".
+  # add_as = "tags"
+
+  ## Interval to check the files for changes and reload them.
+  # reload_interval = "1m"
+
+  ## Values added for metrics with no matching entry.
+  # [processors.lookup.default]
+  #   team = "unknown"
+`
+
+type Lookup struct {
+	Files          []string          `toml:"files"`
+	Format         string            `toml:"format"`
+	KeyTags        []string          `toml:"key_tags"`
+	KeySeparator   string            `toml:"key_separator"`
+	AddAs          string            `toml:"add_as"`
+	ReloadInterval internal.Duration `toml:"reload_interval"`
+	Default        map[string]string `toml:"default"`
+
+	table     table
+	fileTimes map[string]time.Time
+	lastCheck time.Time
+	now       func() time.Time
+}
+
+func (l *Lookup) SampleConfig() string {
+	return sampleConfig
+}
+
+func (l *Lookup) Description() string {
+	return "Add tags or fields from mapping tables loaded from files"
+}
+
+func (l *Lookup) Apply(in ...telegraf.Metric) []telegraf.Metric {
+	l.reloadIfChanged()
+
+	for _, m := range in {
+		values, ok := l.lookup(m)
+		if !ok {
+			l.addDefault(m)
+			continue
+		}
+
+		for name, value := range values {
+			if l.AddAs == "fields" {
+				m.AddField(name, value)
+			} else {
+				m.AddTag(name, toString(value))
+			}
+		}
+	}
+	return in
+}
+
+func (l *Lookup) lookup(m telegraf.Metric) (map[string]interface{}, bool) {
+	parts := make([]string, 0, len(l.KeyTags))
+	for _, key := range l.KeyTags {
+		value, ok := m.GetTag(key)
+		if !ok {
+			return nil, false
+		}
+		parts = append(parts, value)
+	}
+
+	values, ok := l.table[strings.Join(parts, l.KeySeparator)]
+	return values, ok
+}
+
+func (l *Lookup) addDefault(m telegraf.Metric) {
+	for name, value := range l.Default {
+		if l.AddAs == "fields" {
+			m.AddField(name, value)
+		} else {
+			m.AddTag(name, value)
+		}
+	}
+}
+
+func (l *Lookup) Init() error {
+	if l.AddAs != "tags" && l.AddAs != "fields" {
+		return fmt.Errorf("invalid add_as: %s", l.AddAs)
+	}
+	if len(l.KeyTags) == 0 {
+		return fmt.Errorf("key_tags must not be empty")
+	}
+
+	modTimes, err := l.modTimes()
+	if err != nil {
+		return err
+	}
+	t, err := l.load()
+	if err != nil {
+		return err
+	}
+
+	l.table = t
+	l.fileTimes = modTimes
+	l.lastCheck = l.now()
+	return nil
+}
+
+// reloadIfChanged reloads all files when one of them was modified, at most
+// once per reload interval.  On errors the previous table is kept.
+func (l *Lookup) reloadIfChanged() {
+	now := l.now()
+	if now.Sub(l.lastCheck) < l.ReloadInterval.Duration {
+		return
+	}
+	l.lastCheck = now
+
+	modTimes, err := l.modTimes()
+	if err != nil {
+		log.Printf("E! [processors.lookup] %v", err)
+		return
+	}
+	changed := false
+	for filename, modTime := range modTimes {
+		if !modTime.Equal(l.fileTimes[filename]) {
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+
+	t, err := l.load()
+	if err != nil {
+		log.Printf("E! [processors.lookup] %v", err)
+		return
+	}
+
+	log.Printf("D! [processors.lookup] Loaded %d entries", len(t))
+	l.table = t
+	l.fileTimes = modTimes
+}
+
+// modTimes returns the modification time of each file.
+func (l *Lookup) modTimes() (map[string]time.Time, error) {
+	modTimes := make(map[string]time.Time, len(l.Files))
+	for _, filename := range l.Files {
+		stat, err := os.Stat(filename)
+		if err != nil {
+			return nil, err
+		}
+		modTimes[filename] = stat.ModTime()
+	}
+	return modTimes, nil
+}
+
+func (l *Lookup) load() (table, error) {
+	t := make(table)
+	for _, filename := range l.Files {
+		if err := loadFile(t, filename, l.Format, len(l.KeyTags), l.KeySeparator); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func newLookup() *Lookup {
+	return &Lookup{
+		KeySeparator:   ":",
+		AddAs:          "tags",
+		ReloadInterval: internal.Duration{Duration: time.Minute},
+		now:            time.Now,
+	}
+}
+
+func init() {
+	processors.Add("lookup", func() telegraf.Processor {
+		return newLookup()
+	})
+}
@@ -0,0 +1,178 @@
+package lookup
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	filename := filepath.Join(dir, name)
+	require.NoError(t, ioutil.WriteFile(filename, []byte(content), 0644))
+	return filename
+}
+
+func cpu(tags map[string]string) telegraf.Metric {
+	return testutil.MustMetric("cpu", tags, map[string]interface{}{"value": 42.0}, time.Unix(0, 0))
+}
+
+func TestCSVCompositeKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lookup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	l := newLookup()
+	l.Files = []string{writeFile(t, dir, "hosts.csv", `# comment
+host,dc,team,owner
+a,eu,web,alice
+a,us,db,
+`)}
+	l.KeyTags = []string{"host", "dc"}
+	l.Default = map[string]string{"team": "unknown"}
+	require.NoError(t, l.Init())
+
+	out := l.Apply(
+		cpu(map[string]string{"host": "a", "dc": "eu"}),
+		cpu(map[string]string{"host": "a", "dc": "us"}),
+		cpu(map[string]string{"host": "b", "dc": "eu"}),
+		cpu(map[string]string{"host": "a"}),
+	)
+
+	expected := []telegraf.Metric{
+		cpu(map[string]string{"host": "a", "dc": "eu", "team": "web", "owner": "alice"}),
+		cpu(map[string]string{"host": "a", "dc": "us", "team": "db"}),
+		cpu(map[string]string{"host": "b", "dc": "eu", "team": "unknown"}),
+		cpu(map[string]string{"host": "a", "team": "unknown"}),
+	}
+	testutil.RequireMetricsEqual(t, expected, out)
+}
+
+func TestJSONFields(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lookup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	l := newLookup()
+	l.Files = []string{writeFile(t, dir, "hosts.json", `{
+		"a": {"rack": 12, "critical": true, "team": "web"}
+	}`)}
+	l.KeyTags = []string{"host"}
+	l.AddAs = "fields"
+	require.NoError(t, l.Init())
+
+	out := l.Apply(cpu(map[string]string{"host": "a"}))
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric(
+			"cpu",
+			map[string]string{"host": "a"},
+			map[string]interface{}{"value": 42.0, "rack": 12.0, "critical": true, "team": "web"},
+			time.Unix(0, 0),
+		),
+	}
+	testutil.RequireMetricsEqual(t, expected, out)
+}
+
+func TestJSONTagsAndOverride(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lookup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	l := newLookup()
+	l.Files = []string{
+		writeFile(t, dir, "a.json", `{"a": {"rack": 12}, "b": {"rack": 1}}`),
+		writeFile(t, dir, "b.json", `{"b": {"rack": 2}}`),
+	}
+	l.KeyTags = []string{"host"}
+	require.NoError(t, l.Init())
+
+	out := l.Apply(cpu(map[string]string{"host": "a"}), cpu(map[string]string{"host": "b"}))
+
+	expected := []telegraf.Metric{
+		cpu(map[string]string{"host": "a", "rack": "12"}),
+		cpu(map[string]string{"host": "b", "rack": "2"}),
+	}
+	testutil.RequireMetricsEqual(t, expected, out)
+}
+
+func TestReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lookup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	l := newLookup()
+	l.now = func() time.Time { return now }
+	filename := writeFile(t, dir, "hosts.csv", "host,team\na,web\n")
+	l.Files = []string{filename}
+	l.KeyTags = []string{"host"}
+	require.NoError(t, l.Init())
+
+	out := l.Apply(cpu(map[string]string{"host": "a"}))
+	testutil.RequireMetricsEqual(t, []telegraf.Metric{cpu(map[string]string{"host": "a", "team": "web"})}, out)
+
+	writeFile(t, dir, "hosts.csv", "host,team\na,db\n")
+	require.NoError(t, os.Chtimes(filename, now.Add(time.Hour), now.Add(time.Hour)))
+
+	// not reloaded before the reload interval
+	out = l.Apply(cpu(map[string]string{"host": "a"}))
+	testutil.RequireMetricsEqual(t, []telegraf.Metric{cpu(map[string]string{"host": "a", "team": "web"})}, out)
+
+	now = now.Add(time.Minute)
+	out = l.Apply(cpu(map[string]string{"host": "a"}))
+	testutil.RequireMetricsEqual(t, []telegraf.Metric{cpu(map[string]string{"host": "a", "team": "db"})}, out)
+
+	// an invalid file keeps the previous table
+	writeFile(t, dir, "hosts.csv", "host,team\na,db,extra\n")
+	require.NoError(t, os.Chtimes(filename, now.Add(2*time.Hour), now.Add(2*time.Hour)))
+	now = now.Add(time.Minute)
+	out = l.Apply(cpu(map[string]string{"host": "a"}))
+	testutil.RequireMetricsEqual(t, []telegraf.Metric{cpu(map[string]string{"host": "a", "team": "db"})}, out)
+}
+
+func TestInitErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lookup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	l := newLookup()
+	l.Files = []string{"/nonexistent/hosts.csv"}
+	l.KeyTags = []string{"host"}
+	require.Error(t, l.Init())
+
+	l = newLookup()
+	l.Files = []string{writeFile(t, dir, "hosts.csv", "host,team\na,web,extra\n")}
+	l.KeyTags = []string{"host"}
+	require.Error(t, l.Init())
+
+	l = newLookup()
+	l.Files = []string{writeFile(t, dir, "hosts.json", "{}")}
+	l.KeyTags = []string{"host"}
+	l.AddAs = "labels"
+	require.Error(t, l.Init())
+}
+
+func TestMissingFileOnReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lookup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	l := newLookup()
+	l.now = func() time.Time { return now }
+	filename := writeFile(t, dir, "hosts.csv", "host,team\na,web\n")
+	l.Files = []string{filename}
+	l.KeyTags = []string{"host"}
+	require.NoError(t, l.Init())
+
+	require.NoError(t, os.Remove(filename))
+	now = now.Add(time.Minute)
+	out := l.Apply(cpu(map[string]string{"host": "a"}))
+	testutil.RequireMetricsEqual(t, []telegraf.Metric{cpu(map[string]string{"host": "a", "team": "web"})}, out)
+}
@@ -0,0 +1,94 @@
+package lookup
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// table maps a key to the values added on a match.
+type table map[string]map[string]interface{}
+
+// loadFile loads a mapping table from a CSV or JSON file into t.
+//
+// A CSV file has a header row, its first keyColumns columns are the parts of
+// the key and the remaining columns the values named by the header.  A JSON
+// file is an object of keys to objects of values.
+func loadFile(t table, filename, format string, keyColumns int, separator string) error {
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(filename)), ".")
+	}
+
+	switch format {
+	case "csv":
+		return loadCSV(t, filename, keyColumns, separator)
+	case "json":
+		return loadJSON(t, filename)
+	default:
+		return fmt.Errorf("unknown format of %q, use csv or json", filename)
+	}
+}
+
+func loadCSV(t table, filename string, keyColumns int, separator string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.Comment = '#'
+	r.TrimLeadingSpace = true
+
+	records, err := r.ReadAll()
+	if err != nil {
+		return fmt.Errorf("reading %q: %v", filename, err)
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	header := records[0]
+	if len(header) <= keyColumns {
+		return fmt.Errorf("%q needs %d key columns and at least one value column", filename, keyColumns)
+	}
+
+	for _, record := range records[1:] {
+		values := make(map[string]interface{}, len(header)-keyColumns)
+		for i, name := range header[keyColumns:] {
+			if value := record[keyColumns+i]; value != "" {
+				values[name] = value
+			}
+		}
+		t[strings.Join(record[:keyColumns], separator)] = values
+	}
+	return nil
+}
+
+func loadJSON(t table, filename string) error {
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	var entries map[string]map[string]interface{}
+	if err := json.Unmarshal(buf, &entries); err != nil {
+		return fmt.Errorf("reading %q: %v", filename, err)
+	}
+
+	for key, values := range entries {
+		for name, value := range values {
+			switch value.(type) {
+			case string, float64, bool:
+			default:
+				return fmt.Errorf("%q: value %q of key %q must be a string, number or boolean", filename, name, key)
+			}
+		}
+		t[key] = values
+	}
+	return nil
+}
@@ -1,16 +1,21 @@
 package regex
 
 import (
+	"fmt"
 	"regexp"
+	"strconv"
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/plugins/processors"
 )
 
 type Regex struct {
-	Tags       []converter
-	Fields     []converter
-	regexCache map[string]*regexp.Regexp
+	Tags         []converter
+	Fields       []converter
+	TagRename    []converter
+	FieldRename  []converter
+	MetricRename []converter
+	regexCache   map[string]*regexp.Regexp
 }
 
 type converter struct {
@@ -19,8 +24,16 @@ type converter struct {
 	Replacement string
 	ResultKey   string
 	Append      bool
+	Conflict    string
 }
 
+// Policies for renaming to a key that already exists.
+const (
+	conflictKeep      = "keep"
+	conflictOverwrite = "overwrite"
+	conflictSuffix    = "suffix"
+)
+
 const sampleConfig = `
   ## Tag and field conversions defined in a separate sub-tables
   # [[processors.regex.tags]]
@@ -50,6 +63,25 @@ const sampleConfig = `
   #   pattern = ".*category=(\\w+).*"
   #   replacement = "${1}"
   #   result_key = "search_category"
+
+  ## Rename tag keys, field keys and measurement names matching the
+  ## pattern, all matches are replaced.
+  # [[processors.regex.tag_rename]]
+  #   pattern = "^(\\w+)\\.(\\w+)$"
+  #   replacement = "${1}_${2}"
+  #   ## Action when the new key already exists: "keep" leaves the key
+  #   ## unchanged, "overwrite" replaces the existing key, and "suffix"
+  #   ## appends "_1", "_2", ... to the new key until it is unique.
+  #   # conflict = "keep"
+
+  # [[processors.regex.field_rename]]
+  #   pattern = "^HeapMemoryUsage\\.(\\w+)$"
+  #   replacement = "heap_${1}"
+  #   # conflict = "keep"
+
+  # [[processors.regex.metric_rename]]
+  #   pattern = "^java_lang_(\\w+)$"
+  #   replacement = "jvm_${1}"
 `
 
 func NewRegex() *Regex {
@@ -66,6 +98,37 @@ func (r *Regex) Description() string {
 	return "Transforms tag and field values with regex pattern"
 }
 
+func (r *Regex) Init() error {
+	for _, converters := range [][]converter{r.TagRename, r.FieldRename} {
+		for _, c := range converters {
+			switch c.Conflict {
+			case "", conflictKeep, conflictOverwrite, conflictSuffix:
+			default:
+				return fmt.Errorf("invalid conflict policy %q", c.Conflict)
+			}
+		}
+	}
+	for _, c := range r.MetricRename {
+		if c.Conflict != "" {
+			return fmt.Errorf("conflict is not supported in metric_rename")
+		}
+	}
+
+	for _, converters := range [][]converter{r.Tags, r.Fields, r.TagRename, r.FieldRename, r.MetricRename} {
+		for _, c := range converters {
+			if _, ok := r.regexCache[c.Pattern]; ok {
+				continue
+			}
+			regex, err := regexp.Compile(c.Pattern)
+			if err != nil {
+				return fmt.Errorf("invalid pattern %q: %v", c.Pattern, err)
+			}
+			r.regexCache[c.Pattern] = regex
+		}
+	}
+	return nil
+}
+
 func (r *Regex) Apply(in ...telegraf.Metric) []telegraf.Metric {
 	for _, metric := range in {
 		for _, converter := range r.Tags {
@@ -91,17 +154,115 @@ func (r *Regex) Apply(in ...telegraf.Metric) []telegraf.Metric {
 				}
 			}
 		}
+
+		for _, converter := range r.TagRename {
+			r.renameTags(metric, converter)
+		}
+
+		for _, converter := range r.FieldRename {
+			r.renameFields(metric, converter)
+		}
+
+		for _, converter := range r.MetricRename {
+			regex := r.regex(converter.Pattern)
+			if regex.MatchString(metric.Name()) {
+				metric.SetName(regex.ReplaceAllString(metric.Name(), converter.Replacement))
+			}
+		}
 	}
 
 	return in
 }
 
-func (r *Regex) convert(c converter, src string) (string, string) {
-	regex, compiled := r.regexCache[c.Pattern]
+func (r *Regex) renameTags(metric telegraf.Metric, c converter) {
+	regex := r.regex(c.Pattern)
+
+	// rename a copy of the keys as the tags are changed
+	var keys []string
+	for _, tag := range metric.TagList() {
+		keys = append(keys, tag.Key)
+	}
+
+	for _, key := range keys {
+		if !regex.MatchString(key) {
+			continue
+		}
+		newKey := regex.ReplaceAllString(key, c.Replacement)
+		if newKey == key || newKey == "" {
+			continue
+		}
+
+		newKey, ok := resolveConflict(newKey, c.Conflict, metric.HasTag)
+		if !ok {
+			continue
+		}
+
+		value, _ := metric.GetTag(key)
+		metric.RemoveTag(key)
+		metric.AddTag(newKey, value)
+	}
+}
+
+func (r *Regex) renameFields(metric telegraf.Metric, c converter) {
+	regex := r.regex(c.Pattern)
+
+	var keys []string
+	for _, field := range metric.FieldList() {
+		keys = append(keys, field.Key)
+	}
+
+	for _, key := range keys {
+		if !regex.MatchString(key) {
+			continue
+		}
+		newKey := regex.ReplaceAllString(key, c.Replacement)
+		if newKey == key || newKey == "" {
+			continue
+		}
+
+		newKey, ok := resolveConflict(newKey, c.Conflict, metric.HasField)
+		if !ok {
+			continue
+		}
+
+		value, _ := metric.GetField(key)
+		metric.RemoveField(key)
+		metric.AddField(newKey, value)
+	}
+}
+
+// resolveConflict returns the key to rename to if key already exists, or
+// false if the rename should not be done.
+func resolveConflict(key string, policy string, exists func(string) bool) (string, bool) {
+	if !exists(key) {
+		return key, true
+	}
+
+	switch policy {
+	case conflictOverwrite:
+		return key, true
+	case conflictSuffix:
+		for i := 1; ; i++ {
+			k := key + "_" + strconv.Itoa(i)
+			if !exists(k) {
+				return k, true
+			}
+		}
+	}
+	return "", false
+}
+
+func (r *Regex) regex(pattern string) *regexp.Regexp {
+	regex, compiled := r.regexCache[pattern]
 	if !compiled {
-		regex = regexp.MustCompile(c.Pattern)
-		r.regexCache[c.Pattern] = regex
+		regex = regexp.MustCompile(pattern)
+		r.regexCache[pattern] = regex
 	}
+	return regex
+}
+
+func (r *Regex) convert(c converter, src string) (string, string) {
+	regex := r.regex(c.Pattern)
 
 	value := ""
 	if c.ResultKey == "" || regex.MatchString(src) {
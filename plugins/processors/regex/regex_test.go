@@ -261,6 +261,220 @@ func TestNoMatches(t *testing.T) {
 	}
 }
 
+func newJMXMetric() telegraf.Metric {
+	m, _ := metric.New("java_lang_Memory",
+		map[string]string{
+			"jolokia.agent": "http://localhost:8080",
+			"jolokia_agent": "existing",
+		},
+		map[string]interface{}{
+			"HeapMemoryUsage.used":      int64(100),
+			"HeapMemoryUsage.max":       int64(400),
+			"heap_used":                 int64(1),
+			"NonHeapMemoryUsage.used":   int64(50),
+			"ObjectPendingFinalization": int64(0),
+		},
+		time.Now(),
+	)
+	return m
+}
+
+func TestFieldRename(t *testing.T) {
+	tests := []struct {
+		message        string
+		converter      converter
+		expectedFields map[string]interface{}
+	}{
+		{
+			message: "Should keep key on conflict by default",
+			converter: converter{
+				Pattern:     "^HeapMemoryUsage\\.(\\w+)$",
+				Replacement: "heap_${1}",
+			},
+			expectedFields: map[string]interface{}{
+				"HeapMemoryUsage.used":      int64(100),
+				"heap_max":                  int64(400),
+				"heap_used":                 int64(1),
+				"NonHeapMemoryUsage.used":   int64(50),
+				"ObjectPendingFinalization": int64(0),
+			},
+		},
+		{
+			message: "Should overwrite existing key",
+			converter: converter{
+				Pattern:     "^HeapMemoryUsage\\.(\\w+)$",
+				Replacement: "heap_${1}",
+				Conflict:    "overwrite",
+			},
+			expectedFields: map[string]interface{}{
+				"heap_max":                  int64(400),
+				"heap_used":                 int64(100),
+				"NonHeapMemoryUsage.used":   int64(50),
+				"ObjectPendingFinalization": int64(0),
+			},
+		},
+		{
+			message: "Should append suffix to new key",
+			converter: converter{
+				Pattern:     "^HeapMemoryUsage\\.(\\w+)$",
+				Replacement: "heap_${1}",
+				Conflict:    "suffix",
+			},
+			expectedFields: map[string]interface{}{
+				"heap_max":                  int64(400),
+				"heap_used":                 int64(1),
+				"heap_used_1":               int64(100),
+				"NonHeapMemoryUsage.used":   int64(50),
+				"ObjectPendingFinalization": int64(0),
+			},
+		},
+		{
+			message: "Should replace all matches",
+			converter: converter{
+				Pattern:     "([a-z])([A-Z])",
+				Replacement: "${1}_${2}",
+			},
+			expectedFields: map[string]interface{}{
+				"Heap_Memory_Usage.used":      int64(100),
+				"Heap_Memory_Usage.max":       int64(400),
+				"heap_used":                   int64(1),
+				"Non_Heap_Memory_Usage.used":  int64(50),
+				"Object_Pending_Finalization": int64(0),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		regex := NewRegex()
+		regex.FieldRename = []converter{
+			test.converter,
+		}
+
+		processed := regex.Apply(newJMXMetric())
+
+		assert.Equal(t, test.expectedFields, processed[0].Fields(), test.message)
+	}
+}
+
+func TestTagRename(t *testing.T) {
+	tests := []struct {
+		message      string
+		converter    converter
+		expectedTags map[string]string
+	}{
+		{
+			message: "Should keep key on conflict by default",
+			converter: converter{
+				Pattern:     "\\.",
+				Replacement: "_",
+			},
+			expectedTags: map[string]string{
+				"jolokia.agent": "http://localhost:8080",
+				"jolokia_agent": "existing",
+			},
+		},
+		{
+			message: "Should overwrite existing key",
+			converter: converter{
+				Pattern:     "\\.",
+				Replacement: "_",
+				Conflict:    "overwrite",
+			},
+			expectedTags: map[string]string{
+				"jolokia_agent": "http://localhost:8080",
+			},
+		},
+		{
+			message: "Should append suffix to new key",
+			converter: converter{
+				Pattern:     "\\.",
+				Replacement: "_",
+				Conflict:    "suffix",
+			},
+			expectedTags: map[string]string{
+				"jolokia_agent":   "existing",
+				"jolokia_agent_1": "http://localhost:8080",
+			},
+		},
+		{
+			message: "Should use capture groups",
+			converter: converter{
+				Pattern:     "^jolokia\\.(\\w+)$",
+				Replacement: "${1}",
+			},
+			expectedTags: map[string]string{
+				"agent":         "http://localhost:8080",
+				"jolokia_agent": "existing",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		regex := NewRegex()
+		regex.TagRename = []converter{
+			test.converter,
+		}
+
+		processed := regex.Apply(newJMXMetric())
+
+		assert.Equal(t, test.expectedTags, processed[0].Tags(), test.message)
+	}
+}
+
+func TestInvalidConflict(t *testing.T) {
+	regex := NewRegex()
+	regex.FieldRename = []converter{
+		{
+			Pattern:     "\\.",
+			Replacement: "_",
+			Conflict:    "replace",
+		},
+	}
+	assert.Error(t, regex.Init())
+
+	regex = NewRegex()
+	regex.MetricRename = []converter{
+		{
+			Pattern:     "^java_lang_(\\w+)$",
+			Replacement: "jvm_${1}",
+			Conflict:    "overwrite",
+		},
+	}
+	assert.Error(t, regex.Init())
+}
+
+func TestInvalidPattern(t *testing.T) {
+	for _, modify := range []func(r *Regex, c []converter){
+		func(r *Regex, c []converter) { r.Tags = c },
+		func(r *Regex, c []converter) { r.Fields = c },
+		func(r *Regex, c []converter) { r.TagRename = c },
+		func(r *Regex, c []converter) { r.FieldRename = c },
+		func(r *Regex, c []converter) { r.MetricRename = c },
+	} {
+		regex := NewRegex()
+		modify(regex, []converter{{Key: "a", Pattern: "(", Replacement: "b"}})
+		assert.Error(t, regex.Init())
+	}
+}
+
+func TestMetricRename(t *testing.T) {
+	regex := NewRegex()
+	regex.MetricRename = []converter{
+		{
+			Pattern:     "^java_lang_(\\w+)$",
+			Replacement: "jvm_${1}",
+		},
+		{
+			Pattern:     "^not_matching$",
+			Replacement: "x",
+		},
+	}
+
+	processed := regex.Apply(newJMXMetric())
+
+	assert.Equal(t, "jvm_Memory", processed[0].Name())
+}
+
 func BenchmarkConversions(b *testing.B) {
 	regex := NewRegex()
 	regex.Tags = []converter{
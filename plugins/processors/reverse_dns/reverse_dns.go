@@ -0,0 +1,405 @@
+package reverse_dns
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/processors"
+	"github.com/influxdata/telegraf/selfstat"
+)
+
+var sampleConfig = `
+  ## Time a resolved name, or a failed lookup, is cached.
+  # cache_ttl = "24h"
+
+  ## Maximum time a lookup may take, it is cached as failed when it takes
+  ## longer.
+  # lookup_timeout = "3s"
+
+  ## Maximum number of concurrent lookups.
+  # max_parallel_lookups = 10
+
+  ## Maximum number of lookups waiting for a free worker, further addresses
+  ## are not resolved until the queue drains.
+  # max_pending_lookups = 1000
+
+  ## Hold the metrics with addresses being resolved, at most for
+  ## lookup_timeout, so that the names are added to the first metrics too.
+  ## The order of the metrics is kept.  When false the metrics are passed on
+  ## right away and the names are added to the following metrics.
+  # ordered = false
+
+  [[processors.reverse_dns.lookup]]
+    ## Tag or field containing the IP address, only one may be set.
+    tag = "source"
+    # field = ""
+
+    ## Tag or field to add the name as, it is a tag when the address is from
+    ## a tag and a field when the address is from a field.
+    dest = "source_name"
+`
+
+type lookup struct {
+	Tag   string `toml:"tag"`
+	Field string `toml:"field"`
+	Dest  string `toml:"dest"`
+}
+
+type resolver interface {
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+}
+
+// entry is a cached lookup, done is closed once name is set.  stale is the
+// name of the expired entry it replaces, used until the lookup is done.
+type entry struct {
+	name    string
+	stale   string
+	expires time.Time
+	done    chan struct{}
+}
+
+// heldMetric is a metric waiting for its lookups, entries has the entry
+// of each lookup or nil when the address is not resolved.
+type heldMetric struct {
+	metric   telegraf.Metric
+	entries  []*entry
+	deadline time.Time
+}
+
+type ReverseDNS struct {
+	Lookups            []lookup          `toml:"lookup"`
+	CacheTTL           internal.Duration `toml:"cache_ttl"`
+	LookupTimeout      internal.Duration `toml:"lookup_timeout"`
+	MaxParallelLookups int               `toml:"max_parallel_lookups"`
+	MaxPendingLookups  int               `toml:"max_pending_lookups"`
+	Ordered            bool              `toml:"ordered"`
+
+	sync.Mutex
+	cache       map[string]*entry
+	lastCleanup time.Time
+	requests    chan string
+	closed      bool
+	cancel      context.CancelFunc
+	wg          sync.WaitGroup
+
+	// metrics held in ordered mode, in order
+	held []*heldMetric
+
+	resolver resolver
+	now      func() time.Time
+
+	cacheHits     selfstat.Stat
+	cacheMisses   selfstat.Stat
+	lookupErrors  selfstat.Stat
+	lookupsQueued selfstat.Stat
+	queueFull     selfstat.Stat
+}
+
+func (r *ReverseDNS) SampleConfig() string {
+	return sampleConfig
+}
+
+func (r *ReverseDNS) Description() string {
+	return "Resolve IP addresses in tags or fields to host names."
+}
+
+func (r *ReverseDNS) Init() error {
+	for _, l := range r.Lookups {
+		if (l.Tag == "") == (l.Field == "") {
+			return fmt.Errorf("exactly one of tag or field must be set in lookup")
+		}
+		if l.Dest == "" {
+			return fmt.Errorf("dest must be set in lookup")
+		}
+	}
+	if r.MaxParallelLookups < 1 {
+		return fmt.Errorf("max_parallel_lookups must be at least 1")
+	}
+	if r.MaxPendingLookups < 0 {
+		return fmt.Errorf("max_pending_lookups must not be negative")
+	}
+
+	r.cache = make(map[string]*entry)
+	r.lastCleanup = r.now()
+	r.requests = make(chan string, r.MaxPendingLookups)
+
+	tags := map[string]string{}
+	r.cacheHits = selfstat.Register("reverse_dns", "cache_hits", tags)
+	r.cacheMisses = selfstat.Register("reverse_dns", "cache_misses", tags)
+	r.lookupErrors = selfstat.Register("reverse_dns", "lookup_errors", tags)
+	r.lookupsQueued = selfstat.Register("reverse_dns", "lookups_queued", tags)
+	r.queueFull = selfstat.Register("reverse_dns", "queue_full", tags)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	for i := 0; i < r.MaxParallelLookups; i++ {
+		r.wg.Add(1)
+		go func() {
+			defer r.wg.Done()
+			r.worker(ctx)
+		}()
+	}
+	return nil
+}
+
+// Close cancels the running lookups and stops the workers.
+func (r *ReverseDNS) Close() error {
+	r.Lock()
+	if r.closed {
+		r.Unlock()
+		return nil
+	}
+	r.closed = true
+	r.cancel()
+	close(r.requests)
+	r.Unlock()
+
+	r.wg.Wait()
+	return nil
+}
+
+// Apply adds the names of cached addresses, addresses without a cached name
+// are queued for lookup and the metric is passed on without the name.
+func (r *ReverseDNS) Apply(in ...telegraf.Metric) []telegraf.Metric {
+	r.cleanup()
+
+	for _, metric := range in {
+		r.addNames(metric, r.entries(metric))
+	}
+	return in
+}
+
+// Hold is Apply when not ordered, otherwise the metrics are held until
+// their lookups are done or lookup_timeout passed.
+func (r *ReverseDNS) Hold(in ...telegraf.Metric) []telegraf.Metric {
+	if !r.Ordered {
+		return r.Apply(in...)
+	}
+	r.cleanup()
+
+	deadline := r.now().Add(r.LookupTimeout.Duration)
+	for _, metric := range in {
+		r.held = append(r.held, &heldMetric{
+			metric:   metric,
+			entries:  r.entries(metric),
+			deadline: deadline,
+		})
+	}
+	return r.Release(false)
+}
+
+// Release returns the held metrics up to the first one still waiting for a
+// lookup, or all of them when flush is true.
+func (r *ReverseDNS) Release(flush bool) []telegraf.Metric {
+	now := r.now()
+
+	var out []telegraf.Metric
+	for len(r.held) > 0 {
+		h := r.held[0]
+		if !flush && !h.ready(now) {
+			break
+		}
+		r.addNames(h.metric, h.entries)
+		out = append(out, h.metric)
+		r.held[0] = nil
+		r.held = r.held[1:]
+	}
+	return out
+}
+
+func (h *heldMetric) ready(now time.Time) bool {
+	if !now.Before(h.deadline) {
+		return true
+	}
+	for _, e := range h.entries {
+		if e == nil {
+			continue
+		}
+		select {
+		case <-e.done:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// entries returns the cache entry of each lookup of the metric, nil for
+// lookups without an address.
+func (r *ReverseDNS) entries(metric telegraf.Metric) []*entry {
+	entries := make([]*entry, len(r.Lookups))
+	for i, l := range r.Lookups {
+		if addr, ok := address(metric, l); ok {
+			entries[i] = r.get(addr)
+		}
+	}
+	return entries
+}
+
+// addNames adds the names of the resolved entries, or the expired name of
+// the entries being resolved.
+func (r *ReverseDNS) addNames(metric telegraf.Metric, entries []*entry) {
+	for i, l := range r.Lookups {
+		e := entries[i]
+		if e == nil {
+			continue
+		}
+
+		name := e.stale
+		select {
+		case <-e.done:
+			name = e.name
+		default:
+		}
+		if name == "" {
+			continue
+		}
+
+		if l.Tag != "" {
+			metric.AddTag(l.Dest, name)
+		} else {
+			metric.AddField(l.Dest, name)
+		}
+	}
+}
+
+// address returns the IP address of the lookup l in the metric.
+func address(metric telegraf.Metric, l lookup) (string, bool) {
+	var addr string
+	if l.Tag != "" {
+		value, ok := metric.GetTag(l.Tag)
+		if !ok {
+			return "", false
+		}
+		addr = value
+	} else {
+		value, ok := metric.GetField(l.Field)
+		if !ok {
+			return "", false
+		}
+		s, ok := value.(string)
+		if !ok {
+			return "", false
+		}
+		addr = s
+	}
+
+	if net.ParseIP(addr) == nil {
+		return "", false
+	}
+	return addr, true
+}
+
+// get returns the cache entry of addr, queueing a lookup when there is no
+// valid one.  An expired entry is returned if the lookup queue is full or
+// the processor is closed, or nil if there is none.
+func (r *ReverseDNS) get(addr string) *entry {
+	r.Lock()
+	defer r.Unlock()
+
+	old, ok := r.cache[addr]
+	if ok {
+		select {
+		case <-old.done:
+			if r.now().Before(old.expires) {
+				r.cacheHits.Incr(1)
+				return old
+			}
+		default:
+			// lookup in progress
+			r.cacheHits.Incr(1)
+			return old
+		}
+	}
+	r.cacheMisses.Incr(1)
+
+	if r.closed {
+		return old
+	}
+	select {
+	case r.requests <- addr:
+	default:
+		r.queueFull.Incr(1)
+		return old
+	}
+
+	e := &entry{done: make(chan struct{})}
+	if old != nil {
+		e.stale = old.name
+	}
+	r.cache[addr] = e
+	r.lookupsQueued.Incr(1)
+	return e
+}
+
+func (r *ReverseDNS) worker(ctx context.Context) {
+	for addr := range r.requests {
+		ctx, cancel := context.WithTimeout(ctx, r.LookupTimeout.Duration)
+		names, err := r.resolver.LookupAddr(ctx, addr)
+		cancel()
+
+		var name string
+		if err != nil {
+			r.lookupErrors.Incr(1)
+			log.Printf("D! [processors.reverse_dns] Lookup of %s failed: %v", addr, err)
+		} else if len(names) > 0 {
+			name = strings.TrimSuffix(names[0], ".")
+		}
+
+		r.Lock()
+		e := r.cache[addr]
+		e.name = name
+		e.expires = r.now().Add(r.CacheTTL.Duration)
+		close(e.done)
+		r.lookupsQueued.Incr(-1)
+		r.Unlock()
+	}
+}
+
+// cleanup removes entries expired for more than a TTL from the cache, at
+// most once per TTL.  Expired entries are kept for a TTL to pass on their
+// name while they are resolved again.
+func (r *ReverseDNS) cleanup() {
+	r.Lock()
+	defer r.Unlock()
+
+	now := r.now()
+	if now.Sub(r.lastCleanup) < r.CacheTTL.Duration {
+		return
+	}
+	r.lastCleanup = now
+
+	for addr, e := range r.cache {
+		select {
+		case <-e.done:
+			if now.Sub(e.expires) >= r.CacheTTL.Duration {
+				delete(r.cache, addr)
+			}
+		default:
+		}
+	}
+}
+
+func newReverseDNS() *ReverseDNS {
+	return &ReverseDNS{
+		CacheTTL:           internal.Duration{Duration: 24 * time.Hour},
+		LookupTimeout:      internal.Duration{Duration: 3 * time.Second},
+		MaxParallelLookups: 10,
+		MaxPendingLookups:  1000,
+		resolver:           net.DefaultResolver,
+		now:                time.Now,
+	}
+}
+
+func init() {
+	processors.Add("reverse_dns", func() telegraf.Processor {
+		return newReverseDNS()
+	})
+}
@@ -0,0 +1,294 @@
+package reverse_dns
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeResolver struct {
+	sync.Mutex
+	names   map[string]string
+	block   chan struct{}
+	lookups int
+}
+
+func (f *fakeResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	f.Lock()
+	f.lookups++
+	name, ok := f.names[addr]
+	f.Unlock()
+
+	// a blocked lookup ignores the context like a hanging resolver
+	if f.block != nil {
+		<-f.block
+	}
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return []string{name}, nil
+}
+
+func (f *fakeResolver) count() int {
+	f.Lock()
+	defer f.Unlock()
+	return f.lookups
+}
+
+func newTestReverseDNS(t *testing.T, res *fakeResolver) *ReverseDNS {
+	r := newReverseDNS()
+	r.resolver = res
+	r.Lookups = []lookup{
+		{Tag: "source", Dest: "source_name"},
+		{Field: "dest_ip", Dest: "dest_name"},
+	}
+	require.NoError(t, r.Init())
+	return r
+}
+
+// waitLookups waits until all lookups queued by r are done.
+func waitLookups(t *testing.T, r *ReverseDNS) {
+	for i := 0; i < 100; i++ {
+		pending := 0
+		r.Lock()
+		for _, e := range r.cache {
+			select {
+			case <-e.done:
+			default:
+				pending++
+			}
+		}
+		r.Unlock()
+		if pending == 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("lookups not done")
+}
+
+func TestResolve(t *testing.T) {
+	res := &fakeResolver{names: map[string]string{
+		"127.0.0.1": "localhost.",
+		"10.0.0.1":  "router.example.com.",
+	}}
+	r := newTestReverseDNS(t, res)
+	defer r.Close()
+
+	metrics := func() []telegraf.Metric {
+		return []telegraf.Metric{
+			testutil.MustMetric("ping",
+				map[string]string{"source": "127.0.0.1"},
+				map[string]interface{}{"dest_ip": "10.0.0.1", "rtt": 1.5},
+				time.Unix(0, 0)),
+			testutil.MustMetric("ping",
+				map[string]string{"source": "10.0.0.2"},
+				map[string]interface{}{"dest_ip": "example.com", "rtt": 2.5},
+				time.Unix(1, 0)),
+		}
+	}
+
+	// the metrics are passed on while the addresses are resolved
+	out := r.Apply(metrics()...)
+	testutil.RequireMetricsEqual(t, metrics(), out)
+	waitLookups(t, r)
+
+	out = r.Apply(metrics()...)
+	expected := []telegraf.Metric{
+		testutil.MustMetric("ping",
+			map[string]string{"source": "127.0.0.1", "source_name": "localhost"},
+			map[string]interface{}{"dest_ip": "10.0.0.1", "dest_name": "router.example.com", "rtt": 1.5},
+			time.Unix(0, 0)),
+		testutil.MustMetric("ping",
+			map[string]string{"source": "10.0.0.2"},
+			map[string]interface{}{"dest_ip": "example.com", "rtt": 2.5},
+			time.Unix(1, 0)),
+	}
+	testutil.RequireMetricsEqual(t, expected, out)
+	require.Equal(t, 3, res.count())
+}
+
+func TestCache(t *testing.T) {
+	res := &fakeResolver{names: map[string]string{"127.0.0.1": "localhost"}}
+	r := newTestReverseDNS(t, res)
+	defer r.Close()
+	now := time.Now()
+	r.Lock()
+	r.now = func() time.Time { return now }
+	r.Unlock()
+
+	apply := func() string {
+		m := testutil.MustMetric("ping", map[string]string{"source": "127.0.0.1"},
+			map[string]interface{}{"rtt": 1.5}, time.Unix(0, 0))
+		r.Apply(m)
+		name, _ := m.GetTag("source_name")
+		return name
+	}
+
+	hits := r.cacheHits.Get()
+	misses := r.cacheMisses.Get()
+
+	require.Equal(t, "", apply())
+	waitLookups(t, r)
+	require.Equal(t, "localhost", apply())
+	require.Equal(t, 1, res.count())
+	require.Equal(t, hits+1, r.cacheHits.Get())
+	require.Equal(t, misses+1, r.cacheMisses.Get())
+
+	// the expired name is used until it is resolved again
+	r.Lock()
+	now = now.Add(24 * time.Hour)
+	r.Unlock()
+	require.Equal(t, "localhost", apply())
+	waitLookups(t, r)
+	require.Equal(t, "localhost", apply())
+	require.Equal(t, 2, res.count())
+}
+
+func TestSlowLookup(t *testing.T) {
+	res := &fakeResolver{
+		names: map[string]string{"127.0.0.1": "localhost"},
+		block: make(chan struct{}),
+	}
+	r := newReverseDNS()
+	r.resolver = res
+	r.Lookups = []lookup{{Tag: "source", Dest: "source_name"}}
+	require.NoError(t, r.Init())
+	defer r.Close()
+
+	m := testutil.MustMetric("ping", map[string]string{"source": "127.0.0.1"},
+		map[string]interface{}{"rtt": 1.5}, time.Unix(0, 0))
+	start := time.Now()
+	out := r.Apply(m)
+	require.True(t, time.Since(start) < time.Second)
+	require.Len(t, out, 1)
+	require.False(t, out[0].HasTag("source_name"))
+
+	// the result of the slow lookup is used by later metrics
+	close(res.block)
+	waitLookups(t, r)
+	m = testutil.MustMetric("ping", map[string]string{"source": "127.0.0.1"},
+		map[string]interface{}{"rtt": 1.5}, time.Unix(0, 0))
+	r.Apply(m)
+	require.True(t, m.HasTag("source_name"))
+	require.Equal(t, 1, res.count())
+}
+
+func TestQueueFull(t *testing.T) {
+	res := &fakeResolver{block: make(chan struct{})}
+	defer close(res.block)
+
+	r := newReverseDNS()
+	r.resolver = res
+	r.MaxParallelLookups = 1
+	r.MaxPendingLookups = 0
+	r.Lookups = []lookup{{Tag: "source", Dest: "source_name"}}
+	require.NoError(t, r.Init())
+
+	var in []telegraf.Metric
+	for _, addr := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"} {
+		in = append(in, testutil.MustMetric("ping", map[string]string{"source": addr},
+			map[string]interface{}{"rtt": 1.5}, time.Unix(0, 0)))
+	}
+
+	start := time.Now()
+	out := r.Apply(in...)
+	require.True(t, time.Since(start) < time.Second)
+	require.Len(t, out, 3)
+}
+
+func TestOrdered(t *testing.T) {
+	res := &fakeResolver{
+		names: map[string]string{"127.0.0.1": "localhost"},
+		block: make(chan struct{}),
+	}
+	r := newReverseDNS()
+	r.resolver = res
+	r.Ordered = true
+	r.Lookups = []lookup{{Tag: "source", Dest: "source_name"}}
+	require.NoError(t, r.Init())
+	defer r.Close()
+
+	first := testutil.MustMetric("ping", map[string]string{"source": "127.0.0.1"},
+		map[string]interface{}{"rtt": 1.5}, time.Unix(0, 0))
+	second := testutil.MustMetric("ping", map[string]string{},
+		map[string]interface{}{"rtt": 2.5}, time.Unix(1, 0))
+
+	// the second metric is held behind the first one
+	start := time.Now()
+	require.Empty(t, r.Hold(first, second))
+	require.True(t, time.Since(start) < time.Second)
+	require.Empty(t, r.Release(false))
+
+	close(res.block)
+	waitLookups(t, r)
+	expected := []telegraf.Metric{
+		testutil.MustMetric("ping", map[string]string{"source": "127.0.0.1", "source_name": "localhost"},
+			map[string]interface{}{"rtt": 1.5}, time.Unix(0, 0)),
+		testutil.MustMetric("ping", map[string]string{},
+			map[string]interface{}{"rtt": 2.5}, time.Unix(1, 0)),
+	}
+	testutil.RequireMetricsEqual(t, expected, r.Release(false))
+	require.Empty(t, r.Release(true))
+}
+
+func TestOrderedTimeout(t *testing.T) {
+	res := &fakeResolver{
+		names: map[string]string{"127.0.0.1": "localhost"},
+		block: make(chan struct{}),
+	}
+	defer close(res.block)
+
+	r := newReverseDNS()
+	r.resolver = res
+	r.Ordered = true
+	r.LookupTimeout.Duration = time.Second
+	r.Lookups = []lookup{{Tag: "source", Dest: "source_name"}}
+	require.NoError(t, r.Init())
+	now := time.Now()
+	r.now = func() time.Time { return now }
+
+	m := testutil.MustMetric("ping", map[string]string{"source": "127.0.0.1"},
+		map[string]interface{}{"rtt": 1.5}, time.Unix(0, 0))
+	require.Empty(t, r.Hold(m))
+
+	// the metric is passed on without the name after the lookup timeout
+	now = now.Add(time.Second)
+	out := r.Release(false)
+	require.Len(t, out, 1)
+	require.False(t, out[0].HasTag("source_name"))
+
+	// all held metrics are passed on when flushing
+	require.Empty(t, r.Hold(testutil.MustMetric("ping", map[string]string{"source": "10.0.0.1"},
+		map[string]interface{}{"rtt": 1.5}, time.Unix(0, 0))))
+	require.Len(t, r.Release(true), 1)
+}
+
+func TestClose(t *testing.T) {
+	res := &fakeResolver{names: map[string]string{"127.0.0.1": "localhost"}}
+	r := newTestReverseDNS(t, res)
+	require.NoError(t, r.Close())
+	require.NoError(t, r.Close())
+
+	// no lookups are queued after close
+	m := testutil.MustMetric("ping", map[string]string{"source": "127.0.0.1"},
+		map[string]interface{}{"rtt": 1.5}, time.Unix(0, 0))
+	r.Apply(m)
+	require.False(t, m.HasTag("source_name"))
+	require.Equal(t, 0, res.count())
+}
+
+func TestInvalidConfig(t *testing.T) {
+	r := newReverseDNS()
+	r.Lookups = []lookup{{Tag: "a", Field: "b", Dest: "c"}}
+	require.Error(t, r.Init())
+
+	r.Lookups = []lookup{{Tag: "a"}}
+	require.Error(t, r.Init())
+}
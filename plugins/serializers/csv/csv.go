@@ -0,0 +1,242 @@
+package csv
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/influxdata/telegraf"
+)
+
+const (
+	// TagsFirst orders the tag columns before the field columns.
+	TagsFirst = "tags_first"
+	// FieldsFirst orders the field columns before the tag columns.
+	FieldsFirst = "fields_first"
+	// Alphabetical orders all tag and field columns by name.
+	Alphabetical = "alphabetical"
+)
+
+type Config struct {
+	// Header writes a header row before the first row and whenever the
+	// columns change.
+	Header bool
+	// Delimiter is the field delimiter, defaults to a comma.
+	Delimiter string
+	// TimestampFormat is one of unix, unix_ms, unix_us, unix_ns or a Go time
+	// layout.
+	TimestampFormat string
+	// ColumnOrder is the order of the tag and field columns when Columns
+	// is not set.
+	ColumnOrder string
+	// Columns is a fixed list of tag or field keys to write.
+	Columns []string
+	// TagPrefix and FieldPrefix are prepended to the column names in the
+	// header.
+	TagPrefix   string
+	FieldPrefix string
+}
+
+type column struct {
+	key   string
+	isTag bool
+}
+
+type serializer struct {
+	header          bool
+	delimiter       rune
+	timestampFormat string
+	columnOrder     string
+	columns         []string
+	tagPrefix       string
+	fieldPrefix     string
+
+	// lastHeader is the header of the previous row, used to detect a change
+	// of columns.
+	lastHeader []string
+}
+
+func NewSerializer(config *Config) (*serializer, error) {
+	s := &serializer{
+		header:          config.Header,
+		delimiter:       ',',
+		timestampFormat: config.TimestampFormat,
+		columnOrder:     config.ColumnOrder,
+		columns:         config.Columns,
+		tagPrefix:       config.TagPrefix,
+		fieldPrefix:     config.FieldPrefix,
+	}
+
+	if config.Delimiter != "" {
+		runes := []rune(config.Delimiter)
+		if len(runes) != 1 {
+			return nil, fmt.Errorf("csv_delimiter must be a single character, got: %s", config.Delimiter)
+		}
+		s.delimiter = runes[0]
+	}
+
+	if s.timestampFormat == "" {
+		s.timestampFormat = "unix"
+	}
+
+	switch s.columnOrder {
+	case "":
+		s.columnOrder = TagsFirst
+	case TagsFirst, FieldsFirst, Alphabetical:
+	default:
+		return nil, fmt.Errorf("invalid csv_column_order: %s", s.columnOrder)
+	}
+
+	return s, nil
+}
+
+func (s *serializer) Serialize(metric telegraf.Metric) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Comma = s.delimiter
+
+	if err := s.write(w, metric); err != nil {
+		return nil, err
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+func (s *serializer) SerializeBatch(metrics []telegraf.Metric) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Comma = s.delimiter
+
+	for _, metric := range metrics {
+		if err := s.write(w, metric); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+func (s *serializer) write(w *csv.Writer, metric telegraf.Metric) error {
+	columns := s.metricColumns(metric)
+
+	if s.header {
+		header := make([]string, 0, len(columns)+2)
+		header = append(header, "timestamp", "measurement")
+		for _, c := range columns {
+			if c.isTag {
+				header = append(header, s.tagPrefix+c.key)
+			} else {
+				header = append(header, s.fieldPrefix+c.key)
+			}
+		}
+
+		if !equal(header, s.lastHeader) {
+			if err := w.Write(header); err != nil {
+				return err
+			}
+			s.lastHeader = header
+		}
+	}
+
+	row := make([]string, 0, len(columns)+2)
+	row = append(row, s.formatTime(metric.Time()), metric.Name())
+	for _, c := range columns {
+		if c.isTag {
+			value, _ := metric.GetTag(c.key)
+			row = append(row, value)
+			continue
+		}
+
+		value, ok := metric.GetField(c.key)
+		if !ok {
+			row = append(row, "")
+			continue
+		}
+		row = append(row, formatValue(value))
+	}
+	return w.Write(row)
+}
+
+// metricColumns returns the tag and field columns written for the metric.
+func (s *serializer) metricColumns(metric telegraf.Metric) []column {
+	if len(s.columns) > 0 {
+		columns := make([]column, 0, len(s.columns))
+		for _, key := range s.columns {
+			columns = append(columns, column{key: key, isTag: metric.HasTag(key)})
+		}
+		return columns
+	}
+
+	tags := make([]column, 0, len(metric.TagList()))
+	for _, tag := range metric.TagList() {
+		tags = append(tags, column{key: tag.Key, isTag: true})
+	}
+	fields := make([]column, 0, len(metric.FieldList()))
+	for _, field := range metric.FieldList() {
+		fields = append(fields, column{key: field.Key})
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].key < fields[j].key
+	})
+
+	var columns []column
+	switch s.columnOrder {
+	case FieldsFirst:
+		columns = append(fields, tags...)
+	case Alphabetical:
+		columns = append(tags, fields...)
+		sort.SliceStable(columns, func(i, j int) bool {
+			return columns[i].key < columns[j].key
+		})
+	default:
+		columns = append(tags, fields...)
+	}
+	return columns
+}
+
+func (s *serializer) formatTime(t time.Time) string {
+	switch s.timestampFormat {
+	case "unix":
+		return strconv.FormatInt(t.Unix(), 10)
+	case "unix_ms":
+		return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+	case "unix_us":
+		return strconv.FormatInt(t.UnixNano()/int64(time.Microsecond), 10)
+	case "unix_ns":
+		return strconv.FormatInt(t.UnixNano(), 10)
+	default:
+		return t.UTC().Format(s.timestampFormat)
+	}
+}
+
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,134 @@
+package csv
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func testMetrics() []telegraf.Metric {
+	return []telegraf.Metric{
+		testutil.MustMetric(
+			"cpu",
+			map[string]string{
+				"host": "server01",
+				"cpu":  "cpu0",
+			},
+			map[string]interface{}{
+				"usage_user": 1.5,
+				"count":      int64(42),
+			},
+			time.Unix(1556813561, 0),
+		),
+		testutil.MustMetric(
+			"cpu",
+			map[string]string{
+				"host": "server01",
+				"cpu":  "cpu1",
+			},
+			map[string]interface{}{
+				"usage_user": 2.5,
+				"count":      int64(43),
+			},
+			time.Unix(1556813561, 0),
+		),
+	}
+}
+
+func TestSerializeBatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   Config
+		expected string
+	}{
+		{
+			name:   "no header",
+			config: Config{},
+			expected: "1556813561,cpu,cpu0,server01,42,1.5\n" +
+				"1556813561,cpu,cpu1,server01,43,2.5\n",
+		},
+		{
+			name:   "header",
+			config: Config{Header: true},
+			expected: "timestamp,measurement,cpu,host,count,usage_user\n" +
+				"1556813561,cpu,cpu0,server01,42,1.5\n" +
+				"1556813561,cpu,cpu1,server01,43,2.5\n",
+		},
+		{
+			name:   "fields first with prefixes",
+			config: Config{Header: true, ColumnOrder: FieldsFirst, TagPrefix: "tag_", FieldPrefix: "field_"},
+			expected: "timestamp,measurement,field_count,field_usage_user,tag_cpu,tag_host\n" +
+				"1556813561,cpu,42,1.5,cpu0,server01\n" +
+				"1556813561,cpu,43,2.5,cpu1,server01\n",
+		},
+		{
+			name:   "alphabetical",
+			config: Config{Header: true, ColumnOrder: Alphabetical},
+			expected: "timestamp,measurement,count,cpu,host,usage_user\n" +
+				"1556813561,cpu,42,cpu0,server01,1.5\n" +
+				"1556813561,cpu,43,cpu1,server01,2.5\n",
+		},
+		{
+			name:   "fixed columns",
+			config: Config{Header: true, Columns: []string{"usage_user", "host", "missing"}},
+			expected: "timestamp,measurement,usage_user,host,missing\n" +
+				"1556813561,cpu,1.5,server01,\n" +
+				"1556813561,cpu,2.5,server01,\n",
+		},
+		{
+			name:   "delimiter and timestamp format",
+			config: Config{Delimiter: ";", TimestampFormat: time.RFC3339, Columns: []string{"count"}},
+			expected: "2019-05-02T16:12:41Z;cpu;42\n" +
+				"2019-05-02T16:12:41Z;cpu;43\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewSerializer(&tt.config)
+			require.NoError(t, err)
+
+			buf, err := s.SerializeBatch(testMetrics())
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, string(buf))
+		})
+	}
+}
+
+func TestSerializeHeaderOnColumnChange(t *testing.T) {
+	s, err := NewSerializer(&Config{Header: true})
+	require.NoError(t, err)
+
+	metrics := testMetrics()
+	buf, err := s.Serialize(metrics[0])
+	require.NoError(t, err)
+	require.Equal(t, "timestamp,measurement,cpu,host,count,usage_user\n1556813561,cpu,cpu0,server01,42,1.5\n", string(buf))
+
+	buf, err = s.Serialize(metrics[1])
+	require.NoError(t, err)
+	require.Equal(t, "1556813561,cpu,cpu1,server01,43,2.5\n", string(buf))
+
+	m := testutil.MustMetric(
+		"mem",
+		map[string]string{},
+		map[string]interface{}{
+			"used":   uint64(100),
+			"status": "ok, good",
+			"ok":     true,
+		},
+		time.Unix(1556813561, 0),
+	)
+	buf, err = s.Serialize(m)
+	require.NoError(t, err)
+	require.Equal(t, "timestamp,measurement,ok,status,used\n1556813561,mem,true,\"ok, good\",100\n", string(buf))
+}
+
+func TestInvalidConfig(t *testing.T) {
+	_, err := NewSerializer(&Config{Delimiter: ";;"})
+	require.Error(t, err)
+
+	_, err = NewSerializer(&Config{ColumnOrder: "random"})
+	require.Error(t, err)
+}
@@ -0,0 +1,190 @@
+package msgpack
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+
+	"github.com/influxdata/telegraf"
+)
+
+// serializer encodes each metric as a MessagePack array of
+// [name, time, tags, fields, type].  Metrics are written back to back without
+// additional framing since every MessagePack object is self delimiting.
+// Fields are written sorted by key.
+//
+// Integers are written as fixint or with the signed formats and unsigned
+// integers always with the unsigned formats, never as positive fixint, so the
+// field types are kept when decoding fixint as a signed integer.
+type serializer struct {
+	buf    []byte
+	fields []*telegraf.Field
+}
+
+func NewSerializer() (*serializer, error) {
+	return &serializer{}, nil
+}
+
+func (s *serializer) Serialize(metric telegraf.Metric) ([]byte, error) {
+	s.buf = s.buf[:0]
+	s.appendMetric(metric)
+
+	out := make([]byte, len(s.buf))
+	copy(out, s.buf)
+	return out, nil
+}
+
+func (s *serializer) SerializeBatch(metrics []telegraf.Metric) ([]byte, error) {
+	s.buf = s.buf[:0]
+	for _, metric := range metrics {
+		s.appendMetric(metric)
+	}
+
+	out := make([]byte, len(s.buf))
+	copy(out, s.buf)
+	return out, nil
+}
+
+func (s *serializer) appendMetric(metric telegraf.Metric) {
+	s.appendArrayHeader(5)
+	s.appendString(metric.Name())
+	s.appendInt(metric.Time().UnixNano())
+
+	tags := metric.TagList()
+	s.appendMapHeader(len(tags))
+	for _, tag := range tags {
+		s.appendString(tag.Key)
+		s.appendString(tag.Value)
+	}
+
+	// sort a copy, the field list is shared with other outputs
+	s.fields = append(s.fields[:0], metric.FieldList()...)
+	sort.Slice(s.fields, func(i, j int) bool {
+		return s.fields[i].Key < s.fields[j].Key
+	})
+	s.appendMapHeader(len(s.fields))
+	for _, field := range s.fields {
+		s.appendString(field.Key)
+		s.appendValue(field.Value)
+	}
+
+	s.appendInt(int64(metric.Type()))
+}
+
+func (s *serializer) appendValue(value interface{}) {
+	switch v := value.(type) {
+	case int64:
+		s.appendInt(v)
+	case uint64:
+		s.appendUint(v)
+	case float64:
+		s.buf = append(s.buf, 0xcb)
+		s.buf = appendUint64(s.buf, math.Float64bits(v))
+	case bool:
+		if v {
+			s.buf = append(s.buf, 0xc3)
+		} else {
+			s.buf = append(s.buf, 0xc2)
+		}
+	case string:
+		s.appendString(v)
+	default:
+		s.buf = append(s.buf, 0xc0)
+	}
+}
+
+func (s *serializer) appendInt(v int64) {
+	switch {
+	case v >= -32 && v <= 127:
+		// positive or negative fixint
+		s.buf = append(s.buf, byte(v))
+	case v >= math.MinInt8 && v <= math.MaxInt8:
+		s.buf = append(s.buf, 0xd0, byte(v))
+	case v >= math.MinInt16 && v <= math.MaxInt16:
+		s.buf = append(s.buf, 0xd1)
+		s.buf = appendUint16(s.buf, uint16(v))
+	case v >= math.MinInt32 && v <= math.MaxInt32:
+		s.buf = append(s.buf, 0xd2)
+		s.buf = appendUint32(s.buf, uint32(v))
+	default:
+		s.buf = append(s.buf, 0xd3)
+		s.buf = appendUint64(s.buf, uint64(v))
+	}
+}
+
+func (s *serializer) appendUint(v uint64) {
+	switch {
+	case v <= math.MaxUint8:
+		s.buf = append(s.buf, 0xcc, byte(v))
+	case v <= math.MaxUint16:
+		s.buf = append(s.buf, 0xcd)
+		s.buf = appendUint16(s.buf, uint16(v))
+	case v <= math.MaxUint32:
+		s.buf = append(s.buf, 0xce)
+		s.buf = appendUint32(s.buf, uint32(v))
+	default:
+		s.buf = append(s.buf, 0xcf)
+		s.buf = appendUint64(s.buf, v)
+	}
+}
+
+func (s *serializer) appendString(v string) {
+	n := len(v)
+	switch {
+	case n <= 31:
+		s.buf = append(s.buf, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		s.buf = append(s.buf, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		s.buf = append(s.buf, 0xda)
+		s.buf = appendUint16(s.buf, uint16(n))
+	default:
+		s.buf = append(s.buf, 0xdb)
+		s.buf = appendUint32(s.buf, uint32(n))
+	}
+	s.buf = append(s.buf, v...)
+}
+
+func (s *serializer) appendArrayHeader(n int) {
+	switch {
+	case n <= 15:
+		s.buf = append(s.buf, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		s.buf = append(s.buf, 0xdc)
+		s.buf = appendUint16(s.buf, uint16(n))
+	default:
+		s.buf = append(s.buf, 0xdd)
+		s.buf = appendUint32(s.buf, uint32(n))
+	}
+}
+
+func (s *serializer) appendMapHeader(n int) {
+	switch {
+	case n <= 15:
+		s.buf = append(s.buf, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		s.buf = append(s.buf, 0xde)
+		s.buf = appendUint16(s.buf, uint16(n))
+	default:
+		s.buf = append(s.buf, 0xdf)
+		s.buf = appendUint32(s.buf, uint32(n))
+	}
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	var tmp [2]byte
+	binary.BigEndian.PutUint16(tmp[:], v)
+	return append(b, tmp[:]...)
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	var tmp [4]byte
+	binary.BigEndian.PutUint32(tmp[:], v)
+	return append(b, tmp[:]...)
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	var tmp [8]byte
+	binary.BigEndian.PutUint64(tmp[:], v)
+	return append(b, tmp[:]...)
+}
@@ -0,0 +1,61 @@
+package msgpack
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSerialize(t *testing.T) {
+	m, err := metric.New(
+		"cpu",
+		map[string]string{"host": "a"},
+		map[string]interface{}{
+			"i": int64(-1),
+			"u": uint64(1),
+		},
+		time.Unix(0, 1),
+		telegraf.Gauge,
+	)
+	require.NoError(t, err)
+
+	s, err := NewSerializer()
+	require.NoError(t, err)
+
+	buf, err := s.Serialize(m)
+	require.NoError(t, err)
+	require.Equal(t, []byte{
+		0x95,
+		0xa3, 'c', 'p', 'u',
+		0x01,
+		0x81, 0xa4, 'h', 'o', 's', 't', 0xa1, 'a',
+		0x82, 0xa1, 'i', 0xff, 0xa1, 'u', 0xcc, 0x01,
+		0x02,
+	}, buf)
+}
+
+func TestSerializeIntegerFormats(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		expected []byte
+	}{
+		{int64(127), []byte{0x7f}},
+		{int64(-32), []byte{0xe0}},
+		{int64(-33), []byte{0xd0, 0xdf}},
+		{int64(128), []byte{0xd1, 0x00, 0x80}},
+		{int64(1 << 16), []byte{0xd2, 0x00, 0x01, 0x00, 0x00}},
+		{int64(1 << 32), []byte{0xd3, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00}},
+		{uint64(0), []byte{0xcc, 0x00}},
+		{uint64(256), []byte{0xcd, 0x01, 0x00}},
+		{uint64(1 << 16), []byte{0xce, 0x00, 0x01, 0x00, 0x00}},
+		{uint64(1 << 63), []byte{0xcf, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+	}
+	for _, tt := range tests {
+		s := &serializer{}
+		s.appendValue(tt.value)
+		require.Equal(t, tt.expected, s.buf, "%T %v", tt.value, tt.value)
+	}
+}
@@ -6,9 +6,11 @@ import (
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/plugins/serializers/carbon2"
+	"github.com/influxdata/telegraf/plugins/serializers/csv"
 	"github.com/influxdata/telegraf/plugins/serializers/graphite"
 	"github.com/influxdata/telegraf/plugins/serializers/influx"
 	"github.com/influxdata/telegraf/plugins/serializers/json"
+	"github.com/influxdata/telegraf/plugins/serializers/msgpack"
 	"github.com/influxdata/telegraf/plugins/serializers/nowmetric"
 	"github.com/influxdata/telegraf/plugins/serializers/splunkmetric"
 	"github.com/influxdata/telegraf/plugins/serializers/wavefront"
@@ -79,6 +81,28 @@ type Config struct {
 	// Use Strict rules to sanitize metric and tag names from invalid characters for Wavefront
 	// When enabled forward slash (/) and comma (,) will be accepted
 	WavefrontUseStrict bool
+
+	// Write a header row; csv format only
+	CSVHeader bool
+
+	// Column delimiter; csv format only
+	CSVDelimiter string
+
+	// Timestamp format, unix, unix_ms, unix_us, unix_ns or a Go time layout;
+	// csv format only
+	CSVTimestampFormat string
+
+	// Order of the tag and field columns, tags_first, fields_first or
+	// alphabetical; csv format only
+	CSVColumnOrder string
+
+	// Fixed list of tag and field keys to write as columns; csv format only
+	CSVColumns []string
+
+	// Prefixes added to tag and field column names in the header; csv format
+	// only
+	CSVTagPrefix   string
+	CSVFieldPrefix string
 }
 
 // NewSerializer a Serializer interface based on the given config.
@@ -98,6 +122,10 @@ func NewSerializer(config *Config) (Serializer, error) {
 		serializer, err = NewNowSerializer()
 	case "carbon2":
 		serializer, err = NewCarbon2Serializer()
+	case "csv":
+		serializer, err = NewCSVSerializer(config)
+	case "msgpack":
+		serializer, err = NewMsgpackSerializer()
 	case "wavefront":
 		serializer, err = NewWavefrontSerializer(config.Prefix, config.WavefrontUseStrict, config.WavefrontSourceOverride)
 	default:
@@ -114,6 +142,22 @@ func NewJsonSerializer(timestampUnits time.Duration) (Serializer, error) {
 	return json.NewSerializer(timestampUnits)
 }
 
+func NewCSVSerializer(config *Config) (Serializer, error) {
+	return csv.NewSerializer(&csv.Config{
+		Header:          config.CSVHeader,
+		Delimiter:       config.CSVDelimiter,
+		TimestampFormat: config.CSVTimestampFormat,
+		ColumnOrder:     config.CSVColumnOrder,
+		Columns:         config.CSVColumns,
+		TagPrefix:       config.CSVTagPrefix,
+		FieldPrefix:     config.CSVFieldPrefix,
+	})
+}
+
+func NewMsgpackSerializer() (Serializer, error) {
+	return msgpack.NewSerializer()
+}
+
 func NewCarbon2Serializer() (Serializer, error) {
 	return carbon2.NewSerializer()
 }
@@ -10,3 +10,19 @@ type Processor interface {
 	// Apply the filter to the given metric.
 	Apply(in ...Metric) []Metric
 }
+
+// HoldingProcessor is a Processor that may hold back metrics, such as until
+// a lookup is done, without delaying the others.  It is used on the metrics
+// of the inputs, while Apply is used on the metrics of the aggregators.
+type HoldingProcessor interface {
+	Processor
+
+	// Hold applies the processor to the given metrics and returns the
+	// metrics that are ready, in order; the others are returned by a later
+	// Hold or Release.
+	Hold(in ...Metric) []Metric
+
+	// Release returns the held metrics that are ready, in order, or all of
+	// them when flush is true.
+	Release(flush bool) []Metric
+}
@@ -120,6 +120,28 @@ func Metrics() []telegraf.Metric {
 	return metrics
 }
 
+// Stats returns the stats registered for the measurement, by field name for
+// each set of tags.  Unlike Metrics the stats are not read, which would reset
+// timing stats.
+func Stats(measurement string) []map[string]Stat {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	var result []map[string]Stat
+	for _, stats := range registry.stats {
+		fields := make(map[string]Stat, len(stats))
+		for fieldname, stat := range stats {
+			if stat.Name() == "internal_"+measurement {
+				fields[fieldname] = stat
+			}
+		}
+		if len(fields) > 0 {
+			result = append(result, fields)
+		}
+	}
+	return result
+}
+
 type rgstry struct {
 	stats map[uint64]map[string]Stat
 	mu    sync.Mutex
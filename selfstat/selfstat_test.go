@@ -219,3 +219,26 @@ func TestRegisterMetricsAndVerify(t *testing.T) {
 		},
 	)
 }
+
+func TestStats(t *testing.T) {
+	testLock.Lock()
+	defer testCleanup()
+
+	s1 := Register("test", "test_field1", map[string]string{"test": "foo"})
+	s1.Set(101)
+	s2 := Register("test", "test_field2", map[string]string{"test": "foo"})
+	Register("test", "test_field1", map[string]string{"test": "bar"})
+	Register("other", "test_field1", map[string]string{"test": "foo"})
+
+	stats := Stats("test")
+	assert.Len(t, stats, 2)
+	for _, fields := range stats {
+		if fields["test_field1"].Tags()["test"] == "foo" {
+			assert.Equal(t, map[string]Stat{"test_field1": s1, "test_field2": s2}, fields)
+			assert.Equal(t, int64(101), fields["test_field1"].Get())
+		} else {
+			assert.Len(t, fields, 1)
+		}
+	}
+	assert.Empty(t, Stats("missing"))
+}